@@ -0,0 +1,15 @@
+// Package assets holds the files compiled into the term-rex binary.
+package assets
+
+import "embed"
+
+// Sounds contains the sound effects under sounds/
+//
+//go:embed sounds/*.mp3
+var Sounds embed.FS
+
+// Skins contains the sprite packs bundled with the game, one directory
+// per skin under skins/
+//
+//go:embed skins
+var Skins embed.FS
@@ -1,10 +1,12 @@
 package game
 
 import (
-	"fmt"
-	"os/exec"
+	"bytes"
+	"github.com/jianongHe/term-rex/assets"
+	"os"
 	"path/filepath"
-	"runtime"
+	"sort"
+	"strings"
 )
 
 // 音效类型常量
@@ -15,10 +17,22 @@ const (
 	SoundDrop      = "drop" // 新增：快速下降音效
 )
 
+// soundManifest maps every sound to its file in assets/sounds
+var soundManifest = map[string]string{
+	SoundJump:      "jump.mp3",
+	SoundCollision: "collison.mp3", // 文件名原本就是这样拼写的
+	SoundScore:     "score.mp3",
+	SoundDrop:      "drop.mp3",
+}
+
 // AudioManager 管理游戏音效
 type AudioManager struct {
 	enabled   bool
-	soundsDir string // Path to sounds directory
+	soundsDir string       // directory with sound files on disk, "" to use the embedded ones
+	backend   AudioBackend // plays the sounds, chosen by Initialize unless set
+	preferred string       // backend asked for with --audio, "" or "auto" to probe
+	problems  []string     // why better backends or some sounds are not available
+	music     *chiptune    // background music, nil if the backend can't play it
 }
 
 var (
@@ -30,174 +44,178 @@ func GetAudioManager() *AudioManager {
 	if audioManager == nil {
 		audioManager = &AudioManager{
 			enabled:   AudioEnabled, // Use config value
-			soundsDir: "assets/sounds",
+			soundsDir: findSoundsDir(),
 		}
 	}
 	return audioManager
 }
 
-// Initialize 初始化音频系统并加载所有音效
+// Initialize 初始化音频系统：探测可用的音频后端，并把每个音效交给它加载（只解码一次）。
+// 磁盘上的音效文件优先（可以替换成自己的音效），否则使用内嵌的文件。
+// 遇到的问题会记录下来显示在开始界面上。
 func (am *AudioManager) Initialize() error {
-	// For terminal-based audio, we don't need to preload files
-	// We'll play them on demand
-	return nil
+	if am.backend == nil {
+		am.backend, am.problems = probeAudio(am.preferred)
+	}
+	if mb, ok := am.backend.(musicBackend); ok {
+		am.music = newChiptune()
+		mb.SetMusic(am.music)
+	}
+	names := make([]string, 0, len(soundManifest))
+	for name := range soundManifest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var firstErr error
+	for _, name := range names {
+		file := soundManifest[name]
+		data, err := am.soundData(file)
+		if err == nil {
+			err = am.backend.Load(Sound{Name: name, File: file, Data: data})
+		}
+		if err != nil {
+			am.problems = append(am.problems, err.Error())
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
 }
 
-// PlaySound 播放指定的音效
-func (am *AudioManager) PlaySound(name string) {
-	if !am.enabled {
-		return
+// soundData returns the contents of a sound file, from the sounds
+// directory if it has the file and from the binary otherwise
+func (am *AudioManager) soundData(file string) ([]byte, error) {
+	if am.soundsDir != "" {
+		if data, err := os.ReadFile(filepath.Join(am.soundsDir, file)); err == nil {
+			return data, nil
+		}
 	}
+	return assets.Sounds.ReadFile("sounds/" + file)
+}
 
-	// Use custom audio files for jump and drop, system sounds for others
-	switch name {
-	case SoundJump:
-		am.playCustomSound("jump.mp3")
-	case SoundDrop:
-		am.playCustomSound("drop.mp3")
-	case SoundCollision:
-		am.playSystemSound("collision")
-	case SoundScore:
-		am.playSystemSound("score")
+// cacheFile writes data to name below the user cache directory, unless an
+// identical copy is already there, and returns its path
+func cacheFile(name string, data []byte) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
 	}
+	path := filepath.Join(cacheDir, appDirName, name)
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return path, nil
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
 }
 
-// playCustomSound plays a custom audio file from the sounds directory
-func (am *AudioManager) playCustomSound(filename string) {
-	soundPath := filepath.Join(am.soundsDir, filename)
-
-	switch runtime.GOOS {
-	case "darwin": // macOS
-		exec.Command("afplay", soundPath).Start()
-	case "linux":
-		am.tryLinuxAudioCommands(soundPath)
-	case "windows":
-		// For Windows, try different media players
-		am.tryWindowsAudioCommands(soundPath)
-	default:
-		// Fallback to terminal bell for unsupported systems
-		fmt.Print("\a")
+// PlaySound 播放指定的音效，音量由 audioSettings 决定
+func (am *AudioManager) PlaySound(name string) {
+	if !am.enabled || am.backend == nil {
+		return
+	}
+	if volume := audioSettings.volume(name); volume > 0 {
+		am.backend.Play(name, volume)
 	}
 }
 
-// tryLinuxAudioCommands tries different Linux audio players for custom files
-func (am *AudioManager) tryLinuxAudioCommands(soundPath string) {
-	commands := []string{
-		"paplay " + soundPath,
-		"aplay " + soundPath,
-		"mpg123 -q " + soundPath,
-		"ffplay -nodisp -autoexit -v quiet " + soundPath,
-		"cvlc --play-and-exit --intf dummy " + soundPath,
+// UpdateMusic makes the background music follow the run: it plays while a
+// run is going, at a tempo that follows the obstacle speed, is ducked while
+// the run is paused and fades out at game over
+func (am *AudioManager) UpdateMusic(st State) {
+	if am.music == nil {
+		return
 	}
-
-	for _, cmdStr := range commands {
-		if am.executeCommand(cmdStr) {
-			return
+	if !st.Started || st.GameOver {
+		if am.music.Playing() {
+			am.music.Stop()
 		}
+		return
 	}
+	am.music.Start()
+	volume := 0.0
+	if am.IsEnabled() && audioSettings.Music {
+		volume = float64(audioSettings.Volume) / 100 * float64(audioSettings.MusicVolume) / 100
+	}
+	am.music.Set(musicTempo(st.Speed), st.Stage, volume, st.Paused)
+}
 
-	// Fallback to terminal bell
-	fmt.Print("\a")
+// Audible reports whether a sound can be heard right now
+func (am *AudioManager) Audible(name string) bool {
+	if !am.IsEnabled() || audioSettings.volume(name) == 0 {
+		return false
+	}
+	switch am.backend.(type) {
+	case nil, nullBackend:
+		return false
+	case bellBackend:
+		return name == SoundCollision || name == SoundScore
+	}
+	return true
 }
 
-// tryWindowsAudioCommands tries different Windows audio players for custom files
-func (am *AudioManager) tryWindowsAudioCommands(soundPath string) {
-	commands := []string{
-		"powershell -c \"(New-Object Media.SoundPlayer '" + soundPath + "').PlaySync()\"",
-		"start /min wmplayer /close " + soundPath,
+// HasMusic reports whether the backend can play the background music
+func (am *AudioManager) HasMusic() bool {
+	return am.music != nil
+}
+
+// volume returns how loud a sound plays, from 0 to 1
+func (a audioConfig) volume(name string) float64 {
+	if a.MilestonesOnly && name != SoundScore {
+		return 0
+	}
+	category, muted := a.EventsVolume, a.EventsMuted
+	if name == SoundJump || name == SoundDrop {
+		category, muted = a.EffectsVolume, a.EffectsMuted
 	}
+	if muted {
+		return 0
+	}
+	return float64(a.Volume) / 100 * float64(category) / 100
+}
 
-	for _, cmdStr := range commands {
-		if am.executeCommand(cmdStr) {
-			return
-		}
+// Close releases the audio device
+func (am *AudioManager) Close() error {
+	if am.backend == nil {
+		return nil
 	}
+	return am.backend.Close()
+}
 
-	// Fallback to system beep
-	exec.Command("rundll32", "user32.dll,MessageBeep", "0x00000040").Start()
-}
-
-// playSystemSound plays a system sound based on the OS
-func (am *AudioManager) playSystemSound(soundType string) {
-	switch runtime.GOOS {
-	case "darwin": // macOS
-		am.playMacSound(soundType)
-	case "linux":
-		am.playLinuxSound(soundType)
-	case "windows":
-		am.playWindowsSound(soundType)
-	default:
-		// Fallback to terminal bell
-		fmt.Print("\a")
-	}
-}
-
-// playMacSound plays sounds on macOS using afplay or say commands
-func (am *AudioManager) playMacSound(soundType string) {
-	switch soundType {
-	case "collision":
-		// Use system sound for collision
-		exec.Command("afplay", "/System/Library/Sounds/Sosumi.aiff").Start()
-	case "score":
-		// Use system sound for score
-		exec.Command("afplay", "/System/Library/Sounds/Glass.aiff").Start()
-	default:
-		// Fallback to terminal bell
-		fmt.Print("\a")
-	}
-}
-
-// playLinuxSound plays sounds on Linux using paplay, aplay, or beep
-func (am *AudioManager) playLinuxSound(soundType string) {
-	// Try different Linux sound approaches
-	switch soundType {
-	case "collision":
-		am.tryLinuxSystemCommands([]string{
-			"paplay /usr/share/sounds/alsa/Side_Left.wav",
-			"aplay /usr/share/sounds/alsa/Side_Left.wav",
-			"beep -f 200 -l 300",
-		})
-	case "score":
-		am.tryLinuxSystemCommands([]string{
-			"paplay /usr/share/sounds/alsa/Front_Right.wav",
-			"aplay /usr/share/sounds/alsa/Front_Right.wav",
-			"beep -f 1000 -l 200",
-		})
-	default:
-		fmt.Print("\a")
-	}
-}
-
-// playWindowsSound plays sounds on Windows
-func (am *AudioManager) playWindowsSound(soundType string) {
-	switch soundType {
-	case "collision":
-		exec.Command("rundll32", "user32.dll,MessageBeep", "0x00000010").Start()
-	case "score":
-		exec.Command("rundll32", "user32.dll,MessageBeep", "0x00000000").Start()
-	default:
-		fmt.Print("\a")
-	}
-}
-
-// tryLinuxSystemCommands tries multiple Linux sound commands until one works
-func (am *AudioManager) tryLinuxSystemCommands(commands []string) {
-	for _, cmdStr := range commands {
-		if am.executeCommand(cmdStr) {
-			return
-		}
+// SetBackend replaces the audio backend. It must be called before Initialize.
+func (am *AudioManager) SetBackend(b AudioBackend) {
+	am.backend = b
+}
+
+// SetPreferredBackend makes Initialize use the backend with the given name
+// (one of AudioBackendNames) instead of probing; "auto" probes
+func (am *AudioManager) SetPreferredBackend(name string) {
+	am.preferred = name
+}
+
+// Available reports whether sounds can be heard at all
+func (am *AudioManager) Available() bool {
+	if am.backend == nil {
+		return false
 	}
-	// Fallback to terminal bell
-	fmt.Print("\a")
+	_, silent := am.backend.(nullBackend)
+	return !silent
 }
 
-// executeCommand executes a shell command and returns true if successful
-func (am *AudioManager) executeCommand(cmdStr string) bool {
-	cmd := exec.Command("sh", "-c", cmdStr)
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-	err := cmd.Start()
-	return err == nil
+// Status describes the backend in use and the problems found while
+// choosing it, for the start screen
+func (am *AudioManager) Status() string {
+	if am.backend == nil {
+		return "Audio: not initialized"
+	}
+	status := "Audio: " + am.backend.Name()
+	if len(am.problems) > 0 {
+		status += " - " + strings.Join(am.problems, "; ")
+	}
+	return status
 }
 
 // SetEnabled 启用或禁用音效
@@ -205,9 +223,9 @@ func (am *AudioManager) SetEnabled(enabled bool) {
 	am.enabled = enabled
 }
 
-// IsEnabled 返回音效是否启用
+// IsEnabled 返回音效是否启用（没有可用的音频输出时总是 false）
 func (am *AudioManager) IsEnabled() bool {
-	return am.enabled
+	return am.enabled && am.Available()
 }
 
 // ToggleEnabled 切换音效启用状态
@@ -215,32 +233,8 @@ func (am *AudioManager) ToggleEnabled() {
 	am.enabled = !am.enabled
 }
 
-// SetSoundsDirectory sets the directory where sound files are located
+// SetSoundsDirectory sets the directory where sound files are located.
+// It must be called before Initialize.
 func (am *AudioManager) SetSoundsDirectory(dir string) {
 	am.soundsDir = dir
 }
-
-// checkSoundAvailability checks if sound commands are available on the system
-func (am *AudioManager) checkSoundAvailability() bool {
-	switch runtime.GOOS {
-	case "darwin":
-		// Check if afplay is available
-		_, err := exec.LookPath("afplay")
-		return err == nil
-	case "linux":
-		// Check if any Linux sound command is available
-		commands := []string{"paplay", "aplay", "beep", "mpg123", "ffplay", "cvlc"}
-		for _, cmd := range commands {
-			if _, err := exec.LookPath(cmd); err == nil {
-				return true
-			}
-		}
-		return false
-	case "windows":
-		// PowerShell and wmplayer should be available on Windows
-		return true
-	default:
-		// For other systems, assume terminal bell is available
-		return true
-	}
-}
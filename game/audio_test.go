@@ -0,0 +1,230 @@
+package game
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestSimulationSounds checks which sound event every game event produces
+func TestSimulationSounds(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(s *Simulation) // brings the simulation just before the event
+		event []Action
+		want  string
+	}{
+		{"jump", func(s *Simulation) {}, []Action{ActionJump}, SoundJump},
+		{"fast drop", func(s *Simulation) {
+			s.Step([]Action{ActionJump})
+			s.Step(nil)
+		}, []Action{ActionDuck}, SoundDrop},
+		{"score milestone", func(s *Simulation) {
+			s.Step([]Action{ActionJump})
+			s.score = ScoreMilestone - 1
+			s.frameCounter = 0
+		}, nil, SoundScore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSimulation(1)
+			tt.setup(s)
+			if st := s.Step(tt.event); !slices.Contains(st.Sounds, tt.want) {
+				t.Errorf("sounds %q, want %q", st.Sounds, tt.want)
+			}
+		})
+	}
+
+	t.Run("collision", func(t *testing.T) {
+		s := NewSimulation(1)
+		st := s.Step([]Action{ActionJump})
+		for i := 0; i < 10000 && !st.GameOver; i++ {
+			st = s.Step(nil)
+		}
+		if !st.GameOver {
+			t.Fatal("the dino never hit an obstacle")
+		}
+		if !slices.Contains(st.Sounds, SoundCollision) {
+			t.Errorf("sounds %q on the collision tick, want %q", st.Sounds, SoundCollision)
+		}
+		if st = s.Step(nil); len(st.Sounds) != 0 {
+			t.Errorf("sounds %q after the collision, want none", st.Sounds)
+		}
+	})
+}
+
+// TestAudioVolume checks the volume categories, mutes and the
+// milestones-only setting
+func TestAudioVolume(t *testing.T) {
+	full := audioConfig{Volume: 100, EffectsVolume: 100, EventsVolume: 100}
+	tests := []struct {
+		name   string
+		config func(a *audioConfig)
+		sound  string
+		want   float64
+	}{
+		{"full volume", func(a *audioConfig) {}, SoundJump, 1},
+		{"master volume", func(a *audioConfig) { a.Volume = 50 }, SoundCollision, 0.5},
+		{"jump is an effect", func(a *audioConfig) { a.EffectsVolume = 40 }, SoundJump, 0.4},
+		{"drop is an effect", func(a *audioConfig) { a.EffectsVolume = 40 }, SoundDrop, 0.4},
+		{"events keep their volume", func(a *audioConfig) { a.EffectsVolume = 40 }, SoundScore, 1},
+		{"both volumes", func(a *audioConfig) { a.Volume = 50; a.EventsVolume = 50 }, SoundCollision, 0.25},
+		{"effects muted", func(a *audioConfig) { a.EffectsMuted = true }, SoundJump, 0},
+		{"events muted", func(a *audioConfig) { a.EventsMuted = true }, SoundScore, 0},
+		{"events muted, effects play", func(a *audioConfig) { a.EventsMuted = true }, SoundJump, 1},
+		{"milestones only, score", func(a *audioConfig) { a.MilestonesOnly = true }, SoundScore, 1},
+		{"milestones only, collision", func(a *audioConfig) { a.MilestonesOnly = true }, SoundCollision, 0},
+		{"milestones only, jump", func(a *audioConfig) { a.MilestonesOnly = true }, SoundJump, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := full
+			tt.config(&a)
+			if got := a.volume(tt.sound); got != tt.want {
+				t.Errorf("volume(%q) = %v, want %v", tt.sound, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFileAudioBackend plays the sounds of a run through the file backend
+// and checks what ends up in the recording
+func TestFileAudioBackend(t *testing.T) {
+	saved := audioSettings
+	t.Cleanup(func() { audioSettings = saved })
+
+	tests := []struct {
+		name    string
+		enabled bool
+		config  func(a *audioConfig)
+		sound   string
+		audible bool
+	}{
+		{"jump", true, func(a *audioConfig) {}, SoundJump, true},
+		{"drop", true, func(a *audioConfig) {}, SoundDrop, true},
+		{"collision", true, func(a *audioConfig) {}, SoundCollision, true},
+		{"score", true, func(a *audioConfig) {}, SoundScore, true},
+		{"sound off", false, func(a *audioConfig) {}, SoundJump, false},
+		{"effects muted", true, func(a *audioConfig) { a.EffectsMuted = true }, SoundJump, false},
+		{"events at zero", true, func(a *audioConfig) { a.EventsVolume = 0 }, SoundCollision, false},
+		{"milestones only, score", true, func(a *audioConfig) { a.MilestonesOnly = true }, SoundScore, true},
+		{"milestones only, drop", true, func(a *audioConfig) { a.MilestonesOnly = true }, SoundDrop, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audioSettings = audioConfig{Volume: 100, EffectsVolume: 100, EventsVolume: 100}
+			tt.config(&audioSettings)
+
+			path := filepath.Join(t.TempDir(), "session.wav")
+			b, err := NewFileAudioBackend(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			am := &AudioManager{enabled: tt.enabled}
+			am.SetBackend(b)
+			if err := am.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+			am.PlaySound(tt.sound)
+			time.Sleep(200 * time.Millisecond)
+			if err := am.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			peak := wavPeak(t, path)
+			if heard := peak > 0; heard != tt.audible {
+				t.Errorf("recording peaks at %d, audible %v, want %v", peak, heard, tt.audible)
+			}
+		})
+	}
+}
+
+// TestWindowsPlayerQuoting checks that a path with quotes stays one
+// PowerShell string
+func TestWindowsPlayerQuoting(t *testing.T) {
+	args := players["windows"][0].args(`C:\Users\O'Brien\jump.wav`, 1)
+	want := `(New-Object Media.SoundPlayer 'C:\Users\O''Brien\jump.wav').PlaySync()`
+	if got := args[len(args)-1]; got != want {
+		t.Errorf("command %q, want %q", got, want)
+	}
+}
+
+// TestProbeAudio checks the backends --audio picks, with stand-in
+// players on $PATH
+func TestProbeAudio(t *testing.T) {
+	if runtime.GOOS == "windows" || len(players[runtime.GOOS]) == 0 {
+		t.Skip("the stand-in players are shell scripts")
+	}
+	name := players[runtime.GOOS][0].name
+	tests := []struct {
+		name     string
+		choice   string
+		script   string // the stand-in player, "" for none
+		want     string
+		problems []string
+	}{
+		{name: "bell", choice: "bell", want: "bell"},
+		{name: "silent", choice: "silent", want: "silent"},
+		{name: "working player", choice: name, script: "exit 0", want: name},
+		{name: "broken player", choice: name, script: "echo 'Connection refused' >&2\nexit 1", want: "silent", problems: []string{name + ": Connection refused"}},
+		{name: "player not installed", choice: name, want: "silent", problems: []string{name + ": not installed"}},
+		{name: "unknown backend", choice: "gramophone", want: "silent", problems: []string{`unknown audio backend "gramophone"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin := t.TempDir()
+			t.Setenv("PATH", bin)
+			t.Setenv("HOME", t.TempDir())
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			if tt.script != "" {
+				script := "#!/bin/sh\n" + tt.script + "\n"
+				if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			b, problems := probeAudio(tt.choice)
+			defer b.Close()
+			if b.Name() != tt.want {
+				t.Errorf("backend %q, want %q", b.Name(), tt.want)
+			}
+			if !reflect.DeepEqual(problems, tt.problems) {
+				t.Errorf("problems %q, want %q", problems, tt.problems)
+			}
+		})
+	}
+}
+
+// wavPeak checks the header of a WAV file written by the file backend and
+// returns its loudest sample
+func wavPeak(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) < 44 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		t.Fatalf("%s is not a WAV file", path)
+	}
+	samples := data[44:]
+	if size := binary.LittleEndian.Uint32(data[40:]); int(size) != len(samples) {
+		t.Errorf("header says %d bytes of samples, the file has %d", size, len(samples))
+	}
+	if len(samples) == 0 {
+		t.Fatal("nothing was recorded")
+	}
+	peak := 0
+	for i := 0; i+1 < len(samples); i += 2 {
+		v := int(int16(binary.LittleEndian.Uint16(samples[i:])))
+		if v < 0 {
+			v = -v
+		}
+		peak = max(peak, v)
+	}
+	return peak
+}
@@ -0,0 +1,346 @@
+package game
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sound is a sound effect handed to a backend
+type Sound struct {
+	Name string // Sound* constant
+	File string // file name, its extension tells the format
+	Data []byte // file contents
+}
+
+// AudioBackend plays sound effects. Load is called once for every sound
+// before the game starts; Play must not block the game loop. Volume goes
+// from 0 to 1, backends that can't change it play at full volume.
+type AudioBackend interface {
+	Name() string
+	Load(s Sound) error
+	Play(name string, volume float64)
+	Close() error
+}
+
+// musicBackend is implemented by backends that can mix the background
+// music into their output. Player commands and the bell can't.
+type musicBackend interface {
+	SetMusic(c *chiptune)
+}
+
+// AudioBackendNames lists the backends --audio accepts on this system, in
+// the order they are probed
+func AudioBackendNames() []string {
+	names := []string{"native"}
+	for _, p := range players[runtime.GOOS] {
+		names = append(names, p.name)
+	}
+	return append(names, "bell", "silent")
+}
+
+// probeAudio returns the first backend that works, starting with the
+// in-process mixer, or the backend called choice. It also returns why the
+// better backends could not be used. The backends are tried one after
+// another, and all of them together get at most playerProbeTimeout.
+func probeAudio(choice string) (AudioBackend, []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), playerProbeTimeout)
+	defer cancel()
+
+	var problems []string
+	for _, name := range AudioBackendNames() {
+		if choice != "" && choice != "auto" && name != choice {
+			continue
+		}
+		b, err := openAudioBackend(ctx, name)
+		if err == nil {
+			return b, problems
+		}
+		// 没安装的播放器不算问题，除非用户指定了它
+		switch {
+		case !errors.Is(err, exec.ErrNotFound):
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		case choice == name:
+			problems = append(problems, name+": not installed")
+		}
+	}
+	if choice != "" && choice != "auto" && len(problems) == 0 {
+		problems = append(problems, fmt.Sprintf("unknown audio backend %q", choice))
+	}
+	return nullBackend{}, problems
+}
+
+// openAudioBackend opens the backend with the given name; ctx limits how
+// long a player may take to prove it works
+func openAudioBackend(ctx context.Context, name string) (AudioBackend, error) {
+	switch name {
+	case "native":
+		return newMixerBackend()
+	case "bell":
+		return bellBackend{}, nil
+	case "silent":
+		return nullBackend{}, nil
+	}
+	for _, p := range players[runtime.GOOS] {
+		if p.name == name {
+			return newCommandBackend(ctx, p)
+		}
+	}
+	return nil, fmt.Errorf("unknown audio backend %q", name)
+}
+
+// nullBackend plays nothing
+type nullBackend struct{}
+
+func (nullBackend) Name() string                     { return "silent" }
+func (nullBackend) Load(s Sound) error               { return nil }
+func (nullBackend) Play(name string, volume float64) {}
+func (nullBackend) Close() error                     { return nil }
+
+// bellBackend rings the terminal bell for collisions and score milestones.
+// Jumps and drops stay quiet, a bell on every key press is too much.
+type bellBackend struct{}
+
+func (bellBackend) Name() string       { return "bell" }
+func (bellBackend) Load(s Sound) error { return nil }
+func (bellBackend) Close() error       { return nil }
+
+func (bellBackend) Play(name string, volume float64) {
+	if volume > 0 && (name == SoundCollision || name == SoundScore) {
+		ringBell()
+	}
+}
+
+// mixerBackend mixes the sounds in-process and streams the result to the
+// native audio device
+type mixerBackend struct {
+	*mixer
+	device string
+	close  func()
+}
+
+func newMixerBackend() (*mixerBackend, error) {
+	m := newMixer()
+	device, closeDevice, err := openAudioDevice(m)
+	if err != nil {
+		return nil, err
+	}
+	return &mixerBackend{mixer: m, device: device, close: closeDevice}, nil
+}
+
+func (b *mixerBackend) Name() string { return "native (" + b.device + ")" }
+
+func (b *mixerBackend) Close() error {
+	b.close()
+	return nil
+}
+
+// fileBackend mixes the sounds like the native backend but writes the
+// stream to a WAV file in real time, which makes the audio of a session
+// testable without a sound card
+type fileBackend struct {
+	*mixer
+	file   *os.File
+	frames int64
+	stop   chan struct{}
+	done   sync.WaitGroup
+	err    error
+}
+
+// NewFileAudioBackend returns a backend that records everything it plays
+// to the WAV file at path
+func NewFileAudioBackend(path string) (AudioBackend, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &fileBackend{mixer: newMixer(), file: f, stop: make(chan struct{})}
+	if _, err := f.Write(wavHeader(0)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	b.done.Add(1)
+	go b.run()
+	return b, nil
+}
+
+func (b *fileBackend) Name() string { return "file (" + b.file.Name() + ")" }
+
+// run pulls as many frames from the mixer as a sound card would
+func (b *fileBackend) run() {
+	defer b.done.Done()
+	start := time.Now()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+		due := int64(time.Since(start)) * mixRate / int64(time.Second)
+		if due <= b.frames {
+			continue
+		}
+		buf := make([]byte, (due-b.frames)*mixChannels*2)
+		n, _ := b.mixer.Read(buf)
+		if _, err := b.file.Write(buf[:n]); err != nil {
+			b.err = err
+			return
+		}
+		b.frames = due
+	}
+}
+
+// Close stops recording and fills in the sizes in the WAV header
+func (b *fileBackend) Close() error {
+	close(b.stop)
+	b.done.Wait()
+	if _, err := b.file.WriteAt(wavHeader(b.frames*mixChannels*2), 0); err != nil && b.err == nil {
+		b.err = err
+	}
+	if err := b.file.Close(); err != nil && b.err == nil {
+		b.err = err
+	}
+	return b.err
+}
+
+// wavHeader returns the header of a 16 bit stereo WAV file at mixRate with
+// dataSize bytes of samples
+func wavHeader(dataSize int64) []byte {
+	h := make([]byte, 44)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], uint32(36+dataSize))
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(h[22:], mixChannels)
+	binary.LittleEndian.PutUint32(h[24:], mixRate)
+	binary.LittleEndian.PutUint32(h[28:], mixRate*mixChannels*2)
+	binary.LittleEndian.PutUint16(h[32:], mixChannels*2)
+	binary.LittleEndian.PutUint16(h[34:], 16)
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], uint32(dataSize))
+	return h
+}
+
+// playerProbeTimeout limits how long probing the backends may take
+const playerProbeTimeout = 3 * time.Second
+
+// player is an external command that plays a WAV file
+type player struct {
+	name string
+	args func(path string, volume float64) []string
+}
+
+// players lists the sound players for each system in order of preference
+var players = map[string][]player{
+	"darwin": {
+		{"afplay", func(path string, volume float64) []string {
+			return []string{"-v", strconv.FormatFloat(volume, 'f', 2, 64), path}
+		}},
+	},
+	"linux": {
+		{"paplay", func(path string, volume float64) []string {
+			return []string{"--volume=" + strconv.Itoa(int(volume*65536)), path}
+		}},
+		{"aplay", func(path string, volume float64) []string { return []string{"-q", path} }},
+		{"ffplay", func(path string, volume float64) []string {
+			return []string{"-nodisp", "-autoexit", "-v", "quiet", "-volume", strconv.Itoa(int(volume * 100)), path}
+		}},
+	},
+	"windows": {
+		{"powershell", func(path string, volume float64) []string {
+			// PowerShell 单引号字符串中的 ' 需要写成 ''
+			quoted := "'" + strings.ReplaceAll(path, "'", "''") + "'"
+			return []string{"-NoProfile", "-c", "(New-Object Media.SoundPlayer " + quoted + ").PlaySync()"}
+		}},
+	},
+}
+
+// commandBackend starts a player process for every sound. The sounds are
+// decoded and written to the cache directory as WAV files first, which
+// every player understands.
+type commandBackend struct {
+	player player
+	path   string // resolved executable
+	files  map[string]string
+}
+
+// newCommandBackend returns a backend for player after checking that it is
+// installed and can play a short silence before ctx is done
+func newCommandBackend(ctx context.Context, p player) (*commandBackend, error) {
+	path, err := exec.LookPath(p.name)
+	if err != nil {
+		return nil, exec.ErrNotFound
+	}
+	if ctx.Err() != nil {
+		// 前面的播放器已经用完了时间
+		return nil, fmt.Errorf("not tried, probing took longer than %v", playerProbeTimeout)
+	}
+	b := &commandBackend{player: p, path: path, files: make(map[string]string)}
+	silence := make([]byte, mixRate/50*mixChannels*2) // 20ms
+	probe, err := cacheFile(filepath.Join("sounds", "probe.wav"), append(wavHeader(int64(len(silence))), silence...))
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, p.args(probe, 1)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("did not finish playing within %v", playerProbeTimeout)
+		}
+		// 播放器的错误信息比退出码更有用，例如 "Connection refused"
+		if line, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n"); line != "" {
+			return nil, errors.New(line)
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *commandBackend) Name() string { return b.player.name }
+
+func (b *commandBackend) Load(s Sound) error {
+	samples, err := decodeSound(s.File, s.Data)
+	if err != nil {
+		return err
+	}
+	data := wavHeader(int64(2 * len(samples)))
+	for _, v := range samples {
+		data = binary.LittleEndian.AppendUint16(data, uint16(v))
+	}
+	path, err := cacheFile(filepath.Join("sounds", s.Name+".wav"), data)
+	if err != nil {
+		return err
+	}
+	b.files[s.Name] = path
+	return nil
+}
+
+// Play starts the player without waiting for it; the process is reaped in
+// the background
+func (b *commandBackend) Play(name string, volume float64) {
+	path, ok := b.files[name]
+	if !ok || volume <= 0 {
+		return
+	}
+	cmd := exec.Command(b.path, b.player.args(path, volume)...)
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	go cmd.Wait()
+}
+
+func (b *commandBackend) Close() error { return nil }
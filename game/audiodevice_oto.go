@@ -0,0 +1,37 @@
+//go:build darwin || windows
+
+package game
+
+import (
+	"github.com/ebitengine/oto/v3"
+	"runtime"
+	"time"
+)
+
+// audioLatency is the buffer between the mixer and the sound card
+const audioLatency = 40 * time.Millisecond
+
+// openAudioDevice streams the mixer to Core Audio or WASAPI
+func openAudioDevice(m *mixer) (string, func(), error) {
+	ctx, ready, err := oto.NewContext(&oto.NewContextOptions{
+		SampleRate:   mixRate,
+		ChannelCount: mixChannels,
+		Format:       oto.FormatSignedInt16LE,
+		BufferSize:   audioLatency,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	<-ready
+	p := ctx.NewPlayer(m)
+	p.SetBufferSize(int(int64(mixRate) * mixChannels * 2 * int64(audioLatency) / int64(time.Second)))
+	p.Play()
+	name := "coreaudio"
+	if runtime.GOOS == "windows" {
+		name = "windows"
+	}
+	return name, func() {
+		p.Close()
+		ctx.Suspend()
+	}, nil
+}
@@ -0,0 +1,38 @@
+//go:build !darwin && !windows
+
+package game
+
+import (
+	"errors"
+	"github.com/jfreymuth/pulse"
+	"net"
+)
+
+// openAudioDevice connects to the PulseAudio server (or PipeWire's
+// PulseAudio service) and streams the mixer to it
+func openAudioDevice(m *mixer) (string, func(), error) {
+	client, err := pulse.NewClient(pulse.ClientApplicationName("term-rex"))
+	if err != nil {
+		var netErr *net.OpError
+		if errors.As(err, &netErr) {
+			return "", nil, errors.New("no PulseAudio server")
+		}
+		return "", nil, err
+	}
+	stream, err := client.NewPlayback(
+		pulse.Int16Reader(func(buf []int16) (int, error) { return m.mix(buf), nil }),
+		pulse.PlaybackStereo,
+		pulse.PlaybackSampleRate(mixRate),
+		pulse.PlaybackLatency(0.04),
+		pulse.PlaybackMediaName("term-rex"),
+	)
+	if err != nil {
+		client.Close()
+		return "", nil, err
+	}
+	stream.Start()
+	return "pulseaudio", func() {
+		stream.Close()
+		client.Close()
+	}, nil
+}
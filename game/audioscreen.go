@@ -0,0 +1,179 @@
+package game
+
+import (
+	"fmt"
+	"github.com/nsf/termbox-go"
+	"strings"
+)
+
+// 音频设置界面的各行
+const (
+	audioRowVolume     = iota // master volume; Enter turns sound on/off
+	audioRowEffects           // jump and drop; Enter mutes
+	audioRowEvents            // score and collision; Enter mutes
+	audioRowMusic             // background music; Enter turns it on/off
+	audioRowMilestones        // only play the score milestone sound
+	audioRowCount
+)
+
+// volumeStep is how much Left/Right change a volume, in percent
+const volumeStep = 10
+
+// audioScreen is the state of the audio settings screen
+type audioScreen struct {
+	selected int    // row under the cursor
+	status   string // result of the last change
+}
+
+// openAudioScreen shows the audio settings screen
+func (g *Game) openAudioScreen() {
+	g.audioScreen = &audioScreen{}
+}
+
+// handleAudioEvent handles a key press on the audio settings screen
+func (g *Game) handleAudioEvent(ev termbox.Event) {
+	as := g.audioScreen
+	a := &audioSettings
+	switch {
+	case ev.Key == termbox.KeyArrowUp && ev.Ch == 0:
+		as.selected = (as.selected + audioRowCount - 1) % audioRowCount
+		return
+	case ev.Key == termbox.KeyArrowDown && ev.Ch == 0, ev.Key == termbox.KeyTab && ev.Ch == 0:
+		as.selected = (as.selected + 1) % audioRowCount
+		return
+	case ev.Key == termbox.KeyEsc && ev.Ch == 0:
+		g.audioScreen = nil
+		return
+	case ev.Key == termbox.KeyArrowLeft && ev.Ch == 0, ev.Ch == '-':
+		g.changeVolume(-volumeStep)
+	case ev.Key == termbox.KeyArrowRight && ev.Ch == 0, ev.Ch == '+', ev.Ch == '=':
+		g.changeVolume(volumeStep)
+	case (ev.Key == termbox.KeyEnter || ev.Key == termbox.KeySpace) && ev.Ch == 0:
+		switch as.selected {
+		case audioRowVolume:
+			am := GetAudioManager()
+			am.SetEnabled(!am.IsEnabled())
+			return // 音效开关不写入配置文件，和 M 键一样
+		case audioRowEffects:
+			a.EffectsMuted = !a.EffectsMuted
+		case audioRowEvents:
+			a.EventsMuted = !a.EventsMuted
+		case audioRowMusic:
+			a.Music = !a.Music
+		case audioRowMilestones:
+			a.MilestonesOnly = !a.MilestonesOnly
+		}
+	default:
+		return
+	}
+	as.status = ""
+	g.saveAudioSettings()
+}
+
+// changeVolume changes the volume of the selected row by delta percent and
+// plays a sound at the new volume (the music is heard while paused)
+func (g *Game) changeVolume(delta int) {
+	a := &audioSettings
+	var level *int
+	preview := SoundScore
+	switch g.audioScreen.selected {
+	case audioRowVolume:
+		level = &a.Volume
+	case audioRowEffects:
+		level, preview = &a.EffectsVolume, SoundJump
+	case audioRowEvents:
+		level = &a.EventsVolume
+	case audioRowMusic:
+		level, preview = &a.MusicVolume, ""
+	default:
+		return
+	}
+	*level += delta
+	if *level < 0 {
+		*level = 0
+	}
+	if *level > 100 {
+		*level = 100
+	}
+	if preview != "" {
+		GetAudioManager().PlaySound(preview)
+	}
+}
+
+// saveAudioSettings stores the audio settings in the config file and
+// reports failures in the status line
+func (g *Game) saveAudioSettings() {
+	as := g.audioScreen
+	if g.configPath == "" {
+		as.status = "(not saved)"
+		return
+	}
+	if err := SaveAudioSettings(g.configPath); err != nil {
+		as.status = fmt.Sprintf("Could not save: %v", err)
+	}
+}
+
+// volumeBar draws a volume as ten cells, or the reason it is silent
+func volumeBar(level int, silent string) string {
+	if silent != "" {
+		return fmt.Sprintf("[%s] %s", strings.Repeat("-", 10), silent)
+	}
+	filled := level / 10
+	return fmt.Sprintf("[%s%s] %d%%", strings.Repeat("#", filled), strings.Repeat("-", 10-filled), level)
+}
+
+// onOff renders a switch
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// mutedIf returns reason if cond holds
+func mutedIf(cond bool, reason string) string {
+	if cond {
+		return reason
+	}
+	return ""
+}
+
+// drawAudioScreen renders the audio settings screen
+func (g *Game) drawAudioScreen() {
+	as := g.audioScreen
+	a := audioSettings
+	r := g.renderer
+	PrintCenterAt(r, "AUDIO", 1)
+
+	am := GetAudioManager()
+	music := mutedIf(!a.Music, "off")
+	if !am.HasMusic() {
+		music = "n/a" // 播放器命令和终端铃声无法播放音乐
+	}
+	rows := [audioRowCount]string{
+		audioRowVolume:     fmt.Sprintf("%-16s%s", "Volume", volumeBar(a.Volume, mutedIf(!am.IsEnabled(), "muted"))),
+		audioRowEffects:    fmt.Sprintf("%-16s%s", "Jump, drop", volumeBar(a.EffectsVolume, mutedIf(a.EffectsMuted, "muted"))),
+		audioRowEvents:     fmt.Sprintf("%-16s%s", "Score, crash", volumeBar(a.EventsVolume, mutedIf(a.EventsMuted, "muted"))),
+		audioRowMusic:      fmt.Sprintf("%-16s%s", "Music", volumeBar(a.MusicVolume, music)),
+		audioRowMilestones: fmt.Sprintf("%-16s%s", "Milestones only", onOff(a.MilestonesOnly)),
+	}
+	x := (width - 36) / 2
+	if x < 1 {
+		x = 1
+	}
+	for i, row := range rows {
+		cursor := "  "
+		if i == as.selected {
+			cursor = "> "
+		}
+		PrintAt(r, x, 3+i, cursor+row)
+	}
+
+	row := 4 + audioRowCount
+	if as.status != "" {
+		PrintCenterAt(r, as.status, row)
+	}
+	PrintCenterAt(r, clipText(am.Status(), width), row+1)
+	PrintCenterAt(r, "Up/Down select, Left/Right volume", row+3)
+	PrintCenterAt(r, "Enter mute/switch, Esc done", row+4)
+}
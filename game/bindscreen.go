@@ -0,0 +1,113 @@
+package game
+
+import (
+	"fmt"
+	"github.com/nsf/termbox-go"
+)
+
+// bindScreen is the state of the "press a key to bind" screen
+type bindScreen struct {
+	selected InputAction // action under the cursor
+	waiting  bool        // the next key press is bound to selected
+	status   string      // result of the last change
+}
+
+// openBindScreen shows the key binding screen
+func (g *Game) openBindScreen() {
+	g.bindScreen = &bindScreen{}
+}
+
+// handleBindEvent handles a key press on the key binding screen
+func (g *Game) handleBindEvent(ev termbox.Event) {
+	bs := g.bindScreen
+	if bs.waiting {
+		bs.waiting = false
+		if ev.Key == termbox.KeyEsc && ev.Ch == 0 {
+			bs.status = ""
+			return
+		}
+		b := keyEvent(ev)
+		if !b.named() {
+			bs.status = "That key cannot be bound"
+			return
+		}
+		if !g.bindKey(bs.selected, b) {
+			return
+		}
+		bs.status = fmt.Sprintf("%s bound to %s", b.Label(), bs.selected.Title())
+		g.saveKeyMap()
+		return
+	}
+
+	switch {
+	case ev.Key == termbox.KeyArrowUp && ev.Ch == 0:
+		bs.selected = (bs.selected + inputActionCount - 1) % inputActionCount
+	case ev.Key == termbox.KeyArrowDown && ev.Ch == 0, ev.Key == termbox.KeyTab && ev.Ch == 0:
+		bs.selected = (bs.selected + 1) % inputActionCount
+	case ev.Key == termbox.KeyEnter && ev.Ch == 0:
+		bs.waiting = true
+		bs.status = ""
+	case (ev.Key == termbox.KeyBackspace || ev.Key == termbox.KeyBackspace2 || ev.Key == termbox.KeyDelete) && ev.Ch == 0:
+		// 恢复默认按键
+		keyMap[bs.selected] = nil
+		for _, b := range DefaultKeyMap()[bs.selected] {
+			g.bindKey(bs.selected, b)
+		}
+		bs.status = fmt.Sprintf("%s reset to %s", bs.selected.Title(), keyMap.Describe(bs.selected, ", "))
+		g.saveKeyMap()
+	case ev.Key == termbox.KeyEsc && ev.Ch == 0:
+		g.bindScreen = nil
+	}
+}
+
+// bindKey binds b to a unless that would leave a required action without
+// keys; the reason is shown in the status line
+func (g *Game) bindKey(a InputAction, b KeyBinding) bool {
+	if other, ok := keyMap.Lookup(b); ok && other != a && other.required() && len(keyMap[other]) == 1 {
+		g.bindScreen.status = fmt.Sprintf("%s is the only key for %s", b.Label(), other.Title())
+		return false
+	}
+	keyMap.Bind(a, b)
+	return true
+}
+
+// saveKeyMap stores the bindings in the config file and reports the result
+// in the status line
+func (g *Game) saveKeyMap() {
+	bs := g.bindScreen
+	if g.configPath == "" {
+		bs.status += " (not saved)"
+		return
+	}
+	if err := SaveKeyMap(g.configPath); err != nil {
+		bs.status = fmt.Sprintf("Could not save: %v", err)
+	}
+}
+
+// drawBindScreen renders the key binding screen
+func (g *Game) drawBindScreen() {
+	bs := g.bindScreen
+	r := g.renderer
+	PrintCenterAt(r, "KEY BINDINGS", 1)
+
+	x := (width - 40) / 2
+	if x < 1 {
+		x = 1
+	}
+	for a := InputAction(0); a < inputActionCount; a++ {
+		cursor := "  "
+		if a == bs.selected {
+			cursor = "> "
+		}
+		PrintAt(r, x, 3+int(a), fmt.Sprintf("%s%-14s%s", cursor, a.Title(), keyMap.Describe(a, ", ")))
+	}
+
+	row := 3 + int(inputActionCount)
+	if bs.waiting {
+		PrintCenterAt(r, fmt.Sprintf("Press a key for %s (Esc cancels)", bs.selected.Title()), row)
+	} else if bs.status != "" {
+		PrintCenterAt(r, bs.status, row)
+	}
+	PrintCenterAt(r, "Up/Down select, Enter add key", row+2)
+	PrintCenterAt(r, "Backspace reset, Esc done", row+3)
+}
@@ -0,0 +1,287 @@
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+	"math"
+	"unicode"
+)
+
+// 盲文点阵渲染：每个字符格是 2x4 个点，精灵按点绘制，
+// 障碍物、云和跳跃中的恐龙可以停在两个字符格之间，移动更平滑。
+
+// Render modes for --render and the render setting in [display]
+const (
+	renderText    = "text"    // one glyph per cell
+	renderBraille = "braille" // sprites drawn with braille dots, 2x4 per cell
+)
+
+// Dots per cell in the braille mode
+const (
+	dotsX = 2
+	dotsY = 4
+)
+
+// brailleBlank is the braille pattern without dots; a pattern adds the
+// bits of its dots to it
+const brailleBlank = 0x2800
+
+// brailleBits are the bits of the dots of a braille pattern, by row and
+// column
+var brailleBits = [dotsY][dotsX]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// renderSetting is the render mode from the config file
+var renderSetting = renderText
+
+// RenderModes returns the accepted render modes
+func RenderModes() []string {
+	return []string{renderText, renderBraille}
+}
+
+// validRenderMode reports whether mode is one of RenderModes
+func validRenderMode(mode string) bool {
+	for _, m := range RenderModes() {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// PixelArt is a sprite drawn dot by dot: every character is one dot
+// holding a ColorSprite mask key for its colour ('b', 'l', 'd' or 'e',
+// uppercase for bold). ' ' and '.' are no dot.
+type PixelArt []string
+
+// validPixelKey reports whether PixelArt may hold key
+func validPixelKey(key rune) bool {
+	if key == ' ' || key == maskTransparent {
+		return true
+	}
+	_, ok := maskTones[unicode.ToLower(key)]
+	return ok
+}
+
+// each calls dot for every dot of the art, with the colour it is drawn in
+// when the sprite's colour is fg. The art is shaded from top to bottom
+// like a ColorSprite.
+func (p PixelArt) each(fg termbox.Attribute, dot func(col, row int, fg termbox.Attribute)) {
+	h := len(p)
+	for row, line := range p {
+		light := 0.0
+		if h > 1 {
+			light = spriteShading * (1 - 2*float64(row)/float64(h-1))
+		}
+		for col, key := range []rune(line) {
+			if key == ' ' || key == maskTransparent {
+				continue
+			}
+			var attrs termbox.Attribute
+			if unicode.IsUpper(key) {
+				attrs |= termbox.AttrBold
+			}
+			dot(col, row, palette.shade(fg, light+maskTones[unicode.ToLower(key)])|attrs)
+		}
+	}
+}
+
+// glyphDots are the dots a glyph is rasterized to, '#' for a dot. Glyphs
+// missing here fill their cell, and so do opaque spaces.
+var glyphDots = map[rune][dotsY]string{
+	'|':  {"#.", "#.", "#.", "#."},
+	'/':  {".#", ".#", "#.", "#."},
+	'\\': {"#.", "#.", ".#", ".#"},
+	'-':  {"..", "..", "##", ".."},
+	'_':  {"..", "..", "..", "##"},
+	'=':  {"..", "##", "##", ".."},
+	'.':  {"..", "..", "..", "#."},
+	',':  {"..", "..", "#.", "#."},
+	'\'': {"#.", "#.", "..", ".."},
+	'`':  {"#.", "#.", "..", ".."},
+	'"':  {"##", "##", "..", ".."},
+	':':  {"..", "#.", "..", "#."},
+	';':  {"..", "#.", "..", "#."},
+	'(':  {".#", "#.", "#.", ".#"},
+	')':  {"#.", ".#", ".#", "#."},
+	'[':  {"##", "#.", "#.", "##"},
+	']':  {"##", ".#", ".#", "##"},
+	'<':  {"..", ".#", "#.", ".#"},
+	'>':  {"..", "#.", ".#", "#."},
+	'o':  {"..", "##", "##", ".."},
+	'w':  {"..", "..", "##", "##"},
+	'd':  {"..", "..", "##", "##"},
+	'b':  {"..", "..", "##", "##"},
+}
+
+// pixels returns the sprite as pixel art: its own if it has some, else
+// its glyphs rasterized, each cell in the colour of its mask key
+func (s ColorSprite) pixels() PixelArt {
+	if s.Pixels != nil {
+		return s.Pixels
+	}
+	rows := make([][]rune, len(s.Glyphs)*dotsY)
+	for row, line := range s.Glyphs {
+		mask := s.maskRow(row)
+		for col, ch := range []rune(line) {
+			key := maskKey(mask, col)
+			if key == maskTransparent || key == maskDefault && ch == ' ' {
+				continue
+			}
+			if key == maskDefault || key == maskUnderline {
+				key = 'b'
+			}
+			dots, ok := glyphDots[ch]
+			if !ok {
+				dots = [dotsY]string{"##", "##", "##", "##"}
+			}
+			for dy, dotRow := range dots {
+				y := row*dotsY + dy
+				for len(rows[y]) < (col+1)*dotsX {
+					rows[y] = append(rows[y], ' ')
+				}
+				for dx, d := range dotRow {
+					if d == '#' {
+						rows[y][col*dotsX+dx] = key
+					}
+				}
+			}
+		}
+	}
+	art := make(PixelArt, len(rows))
+	for i, row := range rows {
+		art[i] = string(row)
+	}
+	return art
+}
+
+// PixelRenderer is a Renderer that also draws pixel art. Sprites go
+// through DrawPixels when the renderer has it, so they can be drawn
+// between cells.
+type PixelRenderer interface {
+	Renderer
+	// DrawPixels draws art with its top left corner at the cell position
+	// (x, y), which may fall between cells
+	DrawPixels(art PixelArt, x, y float64, fg, bg termbox.Attribute)
+}
+
+// brailleDot is a dot of a BrailleRenderer
+type brailleDot struct {
+	on bool
+	fg termbox.Attribute
+	bg termbox.Attribute
+}
+
+// BrailleRenderer wraps a Renderer and draws pixel art in braille
+// patterns. The dots are turned into cells when the frame is flushed; a
+// cell set afterwards, like text on top of a sprite, replaces the dots
+// in it. A cell shows one colour, that of most of its dots.
+type BrailleRenderer struct {
+	Renderer
+	w, h int // size in cells
+	dots []brailleDot
+}
+
+// NewBrailleRenderer returns a BrailleRenderer drawing into r
+func NewBrailleRenderer(r Renderer) *BrailleRenderer {
+	return &BrailleRenderer{Renderer: r}
+}
+
+// Clear clears the screen and the dots, sized to the play field
+func (b *BrailleRenderer) Clear() {
+	b.Renderer.Clear()
+	b.w, b.h = width, height+1
+	if n := b.w * b.h * dotsX * dotsY; len(b.dots) != n {
+		b.dots = make([]brailleDot, n)
+	}
+	clear(b.dots)
+}
+
+// SetCell sets a cell, removing the dots in it
+func (b *BrailleRenderer) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	if x >= 0 && y >= 0 && x < b.w && y < b.h {
+		for dy := 0; dy < dotsY; dy++ {
+			for dx := 0; dx < dotsX; dx++ {
+				b.dots[b.dot(x*dotsX+dx, y*dotsY+dy)].on = false
+			}
+		}
+	}
+	b.Renderer.SetCell(x, y, ch, fg, bg)
+}
+
+// DrawPixels draws art to the nearest dot of (x, y)
+func (b *BrailleRenderer) DrawPixels(art PixelArt, x, y float64, fg, bg termbox.Attribute) {
+	left := int(math.Round(x * dotsX))
+	top := int(math.Round(y * dotsY))
+	art.each(fg, func(col, row int, fg termbox.Attribute) {
+		dx, dy := left+col, top+row
+		if dx < 0 || dy < 0 || dx >= b.w*dotsX || dy >= b.h*dotsY {
+			return
+		}
+		b.dots[b.dot(dx, dy)] = brailleDot{on: true, fg: fg, bg: bg}
+	})
+}
+
+// Flush turns the dots into cells and shows the frame
+func (b *BrailleRenderer) Flush() {
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			if ch, fg, bg, ok := b.cell(x, y); ok {
+				b.Renderer.SetCell(x, y, ch, fg, bg)
+			}
+		}
+	}
+	clear(b.dots)
+	b.Renderer.Flush()
+}
+
+// cell returns the braille pattern of a cell, in the colour of most of
+// its dots, or false if it has none
+func (b *BrailleRenderer) cell(x, y int) (rune, termbox.Attribute, termbox.Attribute, bool) {
+	ch := rune(brailleBlank)
+	var on []brailleDot
+	for dy := 0; dy < dotsY; dy++ {
+		for dx := 0; dx < dotsX; dx++ {
+			if d := b.dots[b.dot(x*dotsX+dx, y*dotsY+dy)]; d.on {
+				ch += brailleBits[dy][dx]
+				on = append(on, d)
+			}
+		}
+	}
+	if len(on) == 0 {
+		return 0, 0, 0, false
+	}
+	// 点数相同时取第一个点的颜色，颜色不会来回跳
+	fg, most := on[0].fg, 0
+	for _, d := range on {
+		n := 0
+		for _, e := range on {
+			if e.fg == d.fg {
+				n++
+			}
+		}
+		if n > most {
+			fg, most = d.fg, n
+		}
+	}
+	return ch, fg, on[0].bg, true
+}
+
+// dot returns the index of a dot
+func (b *BrailleRenderer) dot(x, y int) int {
+	return y*b.w*dotsX + x
+}
+
+// drawSprite draws s in fg with its top left corner at the cell (x, y),
+// or at (px, py), the exact position it may have between cells, when r
+// draws pixels
+func drawSprite(r Renderer, s ColorSprite, x, y int, px, py float64, fg, bg termbox.Attribute) {
+	if pr, ok := r.(PixelRenderer); ok {
+		pr.DrawPixels(s.pixels(), px, py, fg, bg)
+		return
+	}
+	s.Draw(r, x, y, fg, bg)
+}
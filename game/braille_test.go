@@ -0,0 +1,185 @@
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// colorRenderer is a Renderer that keeps the runes and colours of the
+// cells set
+type colorRenderer struct {
+	cells map[[2]int]rune
+	fg    map[[2]int]termbox.Attribute
+}
+
+func (r *colorRenderer) Clear() {
+	r.cells, r.fg = map[[2]int]rune{}, map[[2]int]termbox.Attribute{}
+}
+
+func (r *colorRenderer) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	r.cells[[2]int{x, y}], r.fg[[2]int{x, y}] = ch, fg
+}
+
+func (r *colorRenderer) Flush() {}
+
+// useBraille returns a cleared BrailleRenderer drawing into a
+// colorRenderer, in 8 colours so pixel art keeps the colour it is drawn in
+func useBraille(t *testing.T) (*BrailleRenderer, *colorRenderer) {
+	useColorDepth(t, colors8)
+	cr := &colorRenderer{}
+	b := NewBrailleRenderer(cr)
+	b.Clear()
+	return b, cr
+}
+
+func TestBrailleRenderer(t *testing.T) {
+	tests := []struct {
+		name string
+		art  PixelArt
+		x, y float64
+		want map[[2]int]rune
+	}{
+		{"one dot", PixelArt{"b"}, 0, 0, map[[2]int]rune{{0, 0}: 0x2801}},
+		{"full cell", PixelArt{"bb", "bb", "bb", "bb"}, 0, 0, map[[2]int]rune{{0, 0}: 0x28ff}},
+		{"transparent dots", PixelArt{". ", " b", "  ", "b."}, 0, 0, map[[2]int]rune{{0, 0}: 0x2800 + 0x10 + 0x40}},
+		{"half a cell right", PixelArt{"b"}, 0.5, 0, map[[2]int]rune{{0, 0}: 0x2808}},
+		{"between two cells", PixelArt{"bb"}, 1.5, 0, map[[2]int]rune{{1, 0}: 0x2808, {2, 0}: 0x2801}},
+		{"a quarter down", PixelArt{"b"}, 0, 1.25, map[[2]int]rune{{0, 1}: 0x2802}},
+		{"nearest dot", PixelArt{"b"}, 0.2, 0.1, map[[2]int]rune{{0, 0}: 0x2801}},
+		{"off the field", PixelArt{"bb"}, -0.5, 0, map[[2]int]rune{{0, 0}: 0x2801}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, cr := useBraille(t)
+			b.DrawPixels(tt.art, tt.x, tt.y, termbox.ColorGreen, termbox.ColorDefault)
+			b.Flush()
+			if !reflect.DeepEqual(cr.cells, tt.want) {
+				t.Errorf("cells %U, want %U", cr.cells, tt.want)
+			}
+		})
+	}
+
+	t.Run("text replaces dots", func(t *testing.T) {
+		b, cr := useBraille(t)
+		b.DrawPixels(PixelArt{"bbbb"}, 0, 0, termbox.ColorGreen, termbox.ColorDefault)
+		b.SetCell(1, 0, 'A', termbox.ColorWhite, termbox.ColorDefault)
+		b.Flush()
+		want := map[[2]int]rune{{0, 0}: 0x2809, {1, 0}: 'A'}
+		if !reflect.DeepEqual(cr.cells, want) {
+			t.Errorf("cells %U, want %U", cr.cells, want)
+		}
+	})
+
+	t.Run("most dots pick the colour", func(t *testing.T) {
+		b, cr := useBraille(t)
+		b.DrawPixels(PixelArt{"b", "b", "b"}, 0, 0, termbox.ColorGreen, termbox.ColorDefault)
+		b.DrawPixels(PixelArt{"b"}, 0.5, 0, termbox.ColorRed, termbox.ColorDefault)
+		b.DrawPixels(PixelArt{"bb"}, 1, 0, termbox.ColorRed, termbox.ColorDefault)
+		b.DrawPixels(PixelArt{"bb"}, 1, 0.25, termbox.ColorGreen, termbox.ColorDefault)
+		b.Flush()
+		if fg := cr.fg[[2]int{0, 0}]; fg != termbox.ColorGreen {
+			t.Errorf("cell 0 is %v, want green", fg)
+		}
+		// 点数相同时用第一个点的颜色
+		if fg := cr.fg[[2]int{1, 0}]; fg != termbox.ColorRed {
+			t.Errorf("cell 1 is %v, want red", fg)
+		}
+	})
+
+	t.Run("dots last one frame", func(t *testing.T) {
+		b, cr := useBraille(t)
+		b.DrawPixels(PixelArt{"b"}, 0, 0, termbox.ColorGreen, termbox.ColorDefault)
+		b.Flush()
+		cr.Clear()
+		b.Flush()
+		if len(cr.cells) != 0 {
+			t.Errorf("cells %U after an empty frame", cr.cells)
+		}
+	})
+}
+
+func TestColorSpritePixels(t *testing.T) {
+	tests := []struct {
+		name string
+		s    ColorSprite
+		want PixelArt
+	}{
+		{
+			name: "own pixel art",
+			s:    ColorSprite{Glyphs: Sprite{"#"}, Pixels: PixelArt{"d."}},
+			want: PixelArt{"d."},
+		},
+		{
+			name: "known glyphs",
+			s:    ColorSprite{Glyphs: Sprite{"|_"}},
+			want: PixelArt{"b   ", "b   ", "b   ", "b bb"},
+		},
+		{
+			name: "other glyphs fill their cell",
+			s:    ColorSprite{Glyphs: Sprite{"█"}},
+			want: PixelArt{"bb", "bb", "bb", "bb"},
+		},
+		{
+			name: "mask colours and transparency",
+			s:    ColorSprite{Glyphs: Sprite{"#a ", "# #"}, Mask: Sprite{"d.", "_bL"}},
+			want: PixelArt{"dd", "dd", "dd", "dd", "bbbbLL", "bbbbLL", "bbbbLL", "bbbbLL"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.pixels(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pixels %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBuiltinPixelArt checks that the pixel art of the built-in sprites
+// fits their glyphs and only uses known keys
+func TestBuiltinPixelArt(t *testing.T) {
+	sprites := append(append([]ColorSprite{moonSprite}, dinoStandFrames...), dinoDuckFrames...)
+	for _, frames := range ObstacleFrames {
+		sprites = append(sprites, frames...)
+	}
+	sprites = append(sprites, cloudSprites...)
+	for i, s := range sprites {
+		if len(s.Pixels) > len(s.Glyphs)*dotsY {
+			t.Errorf("sprite %d: %d pixel rows for %d glyph rows", i, len(s.Pixels), len(s.Glyphs))
+		}
+		for row, line := range s.Pixels {
+			if n := len([]rune(line)); n > s.Glyphs.Width()*dotsX {
+				t.Errorf("sprite %d row %d: %d dots for %d columns", i, row, n, s.Glyphs.Width())
+			}
+			for _, k := range line {
+				if !validPixelKey(k) {
+					t.Errorf("sprite %d row %d: unknown pixel key %q", i, row, k)
+				}
+			}
+		}
+	}
+}
+
+func TestDrawSprite(t *testing.T) {
+	s := ColorSprite{Glyphs: Sprite{"##"}}
+
+	useColorDepth(t, colors8)
+	text := NewBufferRenderer(4, 2)
+	drawSprite(text, s, 1, 0, 0.5, 0, termbox.ColorGreen, termbox.ColorDefault)
+	if got := text.String(); got != " ##\n\n" {
+		t.Errorf("text mode drew %q", got)
+	}
+
+	b, cr := useBraille(t)
+	drawSprite(b, s, 1, 0, 0.5, 0, termbox.ColorGreen, termbox.ColorDefault)
+	b.Flush()
+	var got strings.Builder
+	for x := 0; x < 3; x++ {
+		got.WriteRune(cr.cells[[2]int{x, 0}])
+	}
+	// 半格的位置：第一格只有右半边的点
+	if want := "⢸⣿⡇"; got.String() != want {
+		t.Errorf("braille mode drew %q, want %q", got.String(), want)
+	}
+}
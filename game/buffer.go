@@ -0,0 +1,57 @@
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+	"strings"
+)
+
+// BufferRenderer is an in-memory Renderer for headless frontends.
+// Cells outside the buffer are silently dropped.
+type BufferRenderer struct {
+	Width  int
+	Height int
+	cells  []rune
+}
+
+// NewBufferRenderer creates a blank buffer of the given size
+func NewBufferRenderer(w, h int) *BufferRenderer {
+	b := &BufferRenderer{Width: w, Height: h, cells: make([]rune, w*h)}
+	b.Clear()
+	return b
+}
+
+// Clear fills the buffer with spaces
+func (b *BufferRenderer) Clear() {
+	for i := range b.cells {
+		b.cells[i] = ' '
+	}
+}
+
+// SetCell stores a rune; colours are ignored
+func (b *BufferRenderer) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height {
+		return
+	}
+	b.cells[y*b.Width+x] = ch
+}
+
+// Flush is a no-op for the in-memory buffer
+func (b *BufferRenderer) Flush() {}
+
+// Cell returns the rune at (x,y), or a space when out of bounds
+func (b *BufferRenderer) Cell(x, y int) rune {
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height {
+		return ' '
+	}
+	return b.cells[y*b.Width+x]
+}
+
+// String returns the buffer contents as newline separated rows
+func (b *BufferRenderer) String() string {
+	var sb strings.Builder
+	for y := 0; y < b.Height; y++ {
+		sb.WriteString(strings.TrimRight(string(b.cells[y*b.Width:(y+1)*b.Width]), " "))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
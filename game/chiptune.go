@@ -0,0 +1,215 @@
+package game
+
+import (
+	"math"
+	"sync"
+)
+
+// 背景音乐：一个简单的芯片音乐音序器，由方波主旋律、方波低音和噪声踩镲组成，
+// 全部在混音器里实时合成，不需要音频文件。
+
+const (
+	musicMinTempo   = 110.0 // beats per minute at the first stage's speed
+	musicMaxTempo   = 180.0
+	musicDuckGain   = 0.25   // gain while the run is paused
+	musicGainSmooth = 0.0005 // per sample, about 50ms to follow a gain change
+	stepsPerBeat    = 4      // the sequencer plays sixteenth notes
+	stepsPerBar     = 16
+)
+
+// musicChords is the progression, one chord per bar, as MIDI notes of the
+// triad (Am F C G)
+var musicChords = [][3]int{
+	{57, 60, 64},
+	{53, 57, 60},
+	{60, 64, 67},
+	{55, 59, 62},
+}
+
+// musicArps are the lead patterns, one per stage (later stages reuse the
+// last ones). Numbers index the chord tones, 3-5 are the tones an octave
+// up and -1 is a rest.
+var musicArps = [][stepsPerBar]int{
+	{0, -1, 1, -1, 2, -1, 1, -1, 0, -1, 1, -1, 2, -1, 1, -1},
+	{0, 1, 2, 1, 0, 1, 2, 1, 0, 1, 2, 3, 2, 1, 2, 1},
+	{0, 2, 3, 2, 1, 3, 4, 3, 2, 4, 5, 4, 3, 2, 1, 2},
+	{3, 2, 1, 0, 4, 3, 2, 1, 5, 4, 3, 2, 3, -1, 5, -1},
+}
+
+// chiptune generates the background music. The game sets the tempo, the
+// pattern and the volume; the mixer pulls samples from it.
+type chiptune struct {
+	mu        sync.Mutex
+	playing   bool
+	stopping  bool    // fading out, playing stops at silence
+	tempo     float64 // beats per minute
+	variation int     // index into musicArps
+	volume    float64 // music volume from the settings, 0-1
+	ducked    bool
+	gain      float64 // current gain, follows the target smoothly
+
+	step      int     // sixteenth note within the song
+	stepPos   int     // samples into the current step
+	lead      float64 // oscillator phases, 0-1
+	bass      float64
+	leadFreq  float64
+	bassFreq  float64
+	bassStart int // step the current bass note started on
+	noise     uint16
+}
+
+func newChiptune() *chiptune {
+	return &chiptune{tempo: musicMinTempo, noise: 0xace1}
+}
+
+// Start plays the song from the beginning unless it is already playing
+func (c *chiptune) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.playing && !c.stopping {
+		return
+	}
+	c.playing, c.stopping, c.ducked = true, false, false
+	c.step, c.stepPos, c.gain = 0, 0, 0
+	c.leadFreq, c.bassFreq = 0, 0
+}
+
+// Stop fades the music out
+func (c *chiptune) Stop() {
+	c.mu.Lock()
+	c.stopping = true
+	c.mu.Unlock()
+}
+
+// Playing reports whether the music plays and isn't fading out
+func (c *chiptune) Playing() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.playing && !c.stopping
+}
+
+// Set changes the tempo in beats per minute, the lead pattern, the volume
+// (0-1) and whether the music is ducked
+func (c *chiptune) Set(tempo float64, variation int, volume float64, ducked bool) {
+	c.mu.Lock()
+	c.tempo = tempo
+	c.variation = variation % len(musicArps)
+	c.volume = volume
+	c.ducked = ducked
+	c.mu.Unlock()
+}
+
+// render adds the next len(acc)/mixChannels frames of music to acc
+func (c *chiptune) render(acc []int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.playing {
+		return
+	}
+	target := c.volume
+	if c.ducked {
+		target *= musicDuckGain
+	}
+	if c.stopping {
+		target = 0
+	}
+	stepLen := int(mixRate * 60 / (c.tempo * stepsPerBeat))
+
+	for i := 0; i+1 < len(acc); i += mixChannels {
+		if c.stepPos == 0 {
+			c.startStep()
+		}
+		progress := float64(c.stepPos) / float64(stepLen)
+
+		var s float64
+		if c.leadFreq > 0 {
+			s += 0.12 * square(c.lead, 0.25) * (1 - 0.7*progress)
+			c.lead = math.Mod(c.lead+c.leadFreq/mixRate, 1)
+		}
+		if c.bassFreq > 0 {
+			held := float64(c.step-c.bassStart) + progress
+			if env := 1 - held/4; env > 0 {
+				s += 0.15 * square(c.bass, 0.5) * env
+			}
+			c.bass = math.Mod(c.bass+c.bassFreq/mixRate, 1)
+		}
+		if c.hatOn() && progress < 0.25 {
+			s += 0.05 * c.nextNoise() * (1 - 4*progress)
+		}
+
+		c.gain += (target - c.gain) * musicGainSmooth
+		v := int32(s * c.gain * 32767)
+		for ch := 0; ch < mixChannels; ch++ {
+			acc[i+ch] += v
+		}
+
+		c.stepPos++
+		if c.stepPos >= stepLen {
+			c.stepPos = 0
+			c.step = (c.step + 1) % (stepsPerBar * len(musicChords))
+		}
+	}
+	if c.stopping && c.gain < 1e-4 {
+		c.playing = false
+	}
+}
+
+// startStep picks the notes for the step that begins now
+func (c *chiptune) startStep() {
+	chord := musicChords[c.step/stepsPerBar]
+	beat := c.step % stepsPerBar
+
+	c.leadFreq = 0
+	if tone := musicArps[c.variation][beat]; tone >= 0 {
+		c.leadFreq = midiFreq(chord[tone%3] + 12*(tone/3) + 12)
+	}
+	// 低音在每拍响起，后面的阶段加上切分音
+	if beat%4 == 0 || (c.variation >= 2 && beat%8 == 6) {
+		c.bassFreq = midiFreq(chord[0] - 24)
+		c.bassStart = c.step
+	}
+}
+
+// hatOn reports whether the hi-hat plays on the current step
+func (c *chiptune) hatOn() bool {
+	beat := c.step % stepsPerBar
+	if c.variation >= 1 {
+		return beat%2 == 1
+	}
+	return beat%4 == 2
+}
+
+// nextNoise returns white noise from a 16 bit LFSR, like the noise channel
+// of old sound chips
+func (c *chiptune) nextNoise() float64 {
+	bit := (c.noise ^ c.noise>>2 ^ c.noise>>3 ^ c.noise>>5) & 1
+	c.noise = c.noise>>1 | bit<<15
+	if c.noise&1 != 0 {
+		return 1
+	}
+	return -1
+}
+
+// square is a pulse wave with the given duty cycle at phase 0-1
+func square(phase, duty float64) float64 {
+	if phase < duty {
+		return 1
+	}
+	return -1
+}
+
+// midiFreq returns the frequency of a MIDI note
+func midiFreq(note int) float64 {
+	return 440 * math.Pow(2, float64(note-69)/12)
+}
+
+// musicTempo maps the obstacle speed to a tempo: the first stage plays at
+// musicMinTempo and faster stages speed the music up to musicMaxTempo
+func musicTempo(speed float64) float64 {
+	base := stageConfigs[0].Speed * speedFactor
+	if base <= 0 {
+		return musicMinTempo
+	}
+	tempo := musicMinTempo + 40*(speed/base-1)
+	return math.Max(musicMinTempo, math.Min(musicMaxTempo, tempo))
+}
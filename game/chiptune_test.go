@@ -0,0 +1,121 @@
+package game
+
+import (
+	"math"
+	"testing"
+)
+
+// renderMusic renders seconds of music and returns the RMS level of each
+// half second
+func renderMusic(c *chiptune, seconds float64) []float64 {
+	var levels []float64
+	buf := make([]int32, mixRate/2*mixChannels)
+	for i := 0; i < int(seconds*2); i++ {
+		clear(buf)
+		c.render(buf)
+		var sum float64
+		for _, v := range buf {
+			sum += float64(v) * float64(v)
+		}
+		levels = append(levels, math.Sqrt(sum/float64(len(buf))))
+	}
+	return levels
+}
+
+func TestChiptune(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   bool
+		volume  float64
+		ducked  bool
+		audible bool
+	}{
+		{"not started", false, 1, false, false},
+		{"playing", true, 1, false, true},
+		{"ducked", true, 1, true, true},
+		{"volume zero", true, 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newChiptune()
+			if tt.start {
+				c.Start()
+			}
+			c.Set(musicMinTempo, 0, tt.volume, tt.ducked)
+			levels := renderMusic(c, 2)
+			if heard := levels[len(levels)-1] > 0; heard != tt.audible {
+				t.Errorf("levels %.0f, audible %v, want %v", levels, heard, tt.audible)
+			}
+		})
+	}
+
+	t.Run("ducking is quieter", func(t *testing.T) {
+		loud, quiet := newChiptune(), newChiptune()
+		loud.Start()
+		quiet.Start()
+		loud.Set(musicMinTempo, 0, 1, false)
+		quiet.Set(musicMinTempo, 0, 1, true)
+		l, q := renderMusic(loud, 2), renderMusic(quiet, 2)
+		if last := len(l) - 1; q[last] >= l[last]/2 {
+			t.Errorf("ducked level %.0f, full level %.0f", q[last], l[last])
+		}
+	})
+
+	t.Run("same song every time", func(t *testing.T) {
+		a, b := newChiptune(), newChiptune()
+		for _, c := range []*chiptune{a, b} {
+			c.Start()
+			c.Set(150, 2, 1, false)
+		}
+		if la, lb := renderMusic(a, 1), renderMusic(b, 1); la[0] != lb[0] || la[1] != lb[1] {
+			t.Errorf("levels %.0f and %.0f", la, lb)
+		}
+	})
+
+	t.Run("stop fades out", func(t *testing.T) {
+		c := newChiptune()
+		c.Start()
+		c.Set(musicMinTempo, 1, 1, false)
+		renderMusic(c, 1)
+		c.Stop()
+		if c.Playing() {
+			t.Error("still playing after Stop")
+		}
+		levels := renderMusic(c, 2)
+		if levels[0] == 0 {
+			t.Error("stopped at once instead of fading out")
+		}
+		if last := levels[len(levels)-1]; last != 0 {
+			t.Errorf("level %.0f two seconds after Stop", last)
+		}
+	})
+}
+
+func TestMusicTempo(t *testing.T) {
+	base := stageConfigs[0].Speed * speedFactor
+	tests := []struct {
+		name  string
+		speed float64
+		want  float64
+	}{
+		{"first stage", base, musicMinTempo},
+		{"slower than the first stage", base / 2, musicMinTempo},
+		{"half again as fast", base * 1.5, musicMinTempo + 20},
+		{"very fast", base * 10, musicMaxTempo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := musicTempo(tt.speed); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("musicTempo(%v) = %v, want %v", tt.speed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMidiFreq(t *testing.T) {
+	for note, want := range map[int]float64{69: 440, 81: 880, 57: 220, 60: 261.6256} {
+		if got := midiFreq(note); math.Abs(got-want) > 1e-3 {
+			t.Errorf("midiFreq(%d) = %v, want %v", note, got, want)
+		}
+	}
+}
@@ -5,6 +5,17 @@ import (
 	"math/rand"
 )
 
+// cloudShades is how many shades clouds are picked from; the theme maps
+// them to its cloud colours.
+const cloudShades = 4
+
+// 月亮和星星：比云飘得更慢，星空由位置决定，不消耗随机数
+const (
+	moonSpeed  = 0.05 // cells per frame at the original frame rate
+	starSpeed  = 0.02
+	starRarity = 23 // about one sky cell in starRarity has a star
+)
+
 // Cloud represents a decorative cloud in the sky
 type Cloud struct {
 	x         int
@@ -13,21 +24,25 @@ type Cloud struct {
 	speed     float64
 	posX      float64
 	cloudType int
-	color     termbox.Attribute
+	shade     int // index into the theme's cloud colours
 }
 
 // CloudManager manages multiple clouds in the sky
 type CloudManager struct {
 	clouds    []*Cloud
 	maxClouds int
-	colors    []termbox.Attribute
+	moonX     float64
+	starShift float64 // how far the stars have drifted left
+	rng       *rand.Rand
 }
 
-// NewCloudManager creates a new cloud manager with initial clouds
-func NewCloudManager() *CloudManager {
+// NewCloudManager creates a new cloud manager with initial clouds,
+// drawing all randomness from rng
+func NewCloudManager(rng *rand.Rand) *CloudManager {
 	cm := &CloudManager{
-		maxClouds: cloudMinCount + rand.Intn(cloudMaxCount-cloudMinCount+1),
-		colors:    []termbox.Attribute{termbox.ColorWhite},
+		maxClouds: cloudMinCount + rng.Intn(cloudMaxCount-cloudMinCount+1),
+		rng:       rng,
+		moonX:     float64(width * 3 / 4),
 	}
 
 	// Create initial clouds with good spacing
@@ -38,13 +53,13 @@ func NewCloudManager() *CloudManager {
 
 	for i := 0; i < cm.maxClouds; i++ {
 		// Find a position that doesn't overlap with existing clouds
-		cloudType := rand.Intn(len(cloudSprites))
-		cloudWidth := len(cloudSprites[cloudType][0])
+		cloudType := cm.rng.Intn(len(cloudSprites))
+		cloudWidth := cloudSprites[cloudType].Glyphs.Width()
 
 		// Try to find a position with enough space
 		var startPos int
 		for attempts := 0; attempts < 20; attempts++ { // Limit attempts to avoid infinite loop
-			startPos = rand.Intn(width)
+			startPos = cm.rng.Intn(width)
 			hasSpace := true
 
 			// Check if there's enough space (with buffer)
@@ -68,15 +83,15 @@ func NewCloudManager() *CloudManager {
 			}
 		}
 
-		// Create cloud at this position with random color
+		// Create cloud at this position with a random shade
 		cm.clouds = append(cm.clouds, &Cloud{
 			posX:      float64(startPos),
 			x:         startPos, // Explicitly set x to match posX initially
-			y:         cloudMinHeight + rand.Intn(cloudMaxHeight-cloudMinHeight+1),
+			y:         cloudMinHeight + cm.rng.Intn(cloudMaxHeight-cloudMinHeight+1),
 			width:     cloudWidth,
-			speed:     cloudMinSpeed + rand.Float64()*(cloudMaxSpeed-cloudMinSpeed),
+			speed:     cloudMinSpeed + cm.rng.Float64()*(cloudMaxSpeed-cloudMinSpeed),
 			cloudType: cloudType,
-			color:     cm.colors[rand.Intn(len(cm.colors))],
+			shade:     cm.rng.Intn(cloudShades),
 		})
 	}
 
@@ -85,8 +100,8 @@ func NewCloudManager() *CloudManager {
 
 // createNewCloud creates a new cloud at the right edge with proper spacing
 func (cm *CloudManager) createNewCloud() *Cloud {
-	cloudType := rand.Intn(len(cloudSprites))
-	cloudWidth := len(cloudSprites[cloudType][0])
+	cloudType := cm.rng.Intn(len(cloudSprites))
+	cloudWidth := cloudSprites[cloudType].Glyphs.Width()
 
 	// Check if there's already a cloud near the right edge
 	hasNearbyCloud := false
@@ -101,7 +116,7 @@ func (cm *CloudManager) createNewCloud() *Cloud {
 	// If there's a cloud near the right edge, add extra spacing
 	extraSpace := 0
 	if hasNearbyCloud {
-		extraSpace = cloudMinExtraSpace + rand.Intn(cloudMaxExtraSpace-cloudMinExtraSpace+1)
+		extraSpace = cloudMinExtraSpace + cm.rng.Intn(cloudMaxExtraSpace-cloudMinExtraSpace+1)
 	}
 
 	// Position the new cloud completely off-screen to the right
@@ -110,16 +125,22 @@ func (cm *CloudManager) createNewCloud() *Cloud {
 	return &Cloud{
 		posX:      float64(newX),
 		x:         newX, // Explicitly set x to match posX initially
-		y:         cloudMinHeight + rand.Intn(cloudMaxHeight-cloudMinHeight+1),
+		y:         cloudMinHeight + cm.rng.Intn(cloudMaxHeight-cloudMinHeight+1),
 		width:     cloudWidth,
-		speed:     (cloudMinSpeed + rand.Float64()*(cloudMaxSpeed-cloudMinSpeed)),
+		speed:     (cloudMinSpeed + cm.rng.Float64()*(cloudMaxSpeed-cloudMinSpeed)),
 		cloudType: cloudType,
-		color:     cm.colors[rand.Intn(len(cm.colors))],
+		shade:     cm.rng.Intn(cloudShades),
 	}
 }
 
 // Update moves all clouds and cycles them when they move off-screen
 func (cm *CloudManager) Update() {
+	cm.moonX -= moonSpeed * speedFactor
+	if cm.moonX < -float64(moonSprite.Glyphs.Width()) {
+		cm.moonX = float64(width)
+	}
+	cm.starShift += starSpeed * speedFactor
+
 	for i, cloud := range cm.clouds {
 		// 使用速度因子调整云朵移动速度，保持与原始帧率下相同的视觉速度
 		cloud.posX -= cloud.speed * speedFactor
@@ -133,8 +154,32 @@ func (cm *CloudManager) Update() {
 	}
 }
 
-// Draw renders all clouds on the screen
-func (cm *CloudManager) Draw() {
+// Resize pulls clouds beyond the new right edge back in after the screen
+// shrank from oldWidth, keeping their distance from the edge
+func (cm *CloudManager) Resize(oldWidth int) {
+	if cm.moonX > float64(width) {
+		cm.moonX = float64(width)
+	}
+	for _, cloud := range cm.clouds {
+		if cloud.x < width {
+			continue
+		}
+		offscreen := cloud.posX - float64(oldWidth)
+		if offscreen < 0 {
+			offscreen = 0
+		}
+		cloud.posX = float64(width) + offscreen
+		cloud.x = int(cloud.posX)
+	}
+}
+
+// Draw renders all clouds on the screen, in front of the moon and stars
+// when it is dark enough to see them
+func (cm *CloudManager) Draw(r Renderer, darkness float64) {
+	if skyVisible(darkness) {
+		cm.drawSky(r)
+	}
+
 	for _, cloud := range cm.clouds {
 		// Skip drawing if the cloud is completely off-screen
 		if cloud.x+cloud.width < 0 || cloud.x > width {
@@ -143,13 +188,49 @@ func (cm *CloudManager) Draw() {
 
 		// Draw all clouds regardless of game state or ground extension
 		sprite := cloudSprites[cloud.cloudType]
-		for y, line := range sprite {
-			for x, ch := range line {
-				// Only draw non-space characters that are within screen bounds
-				if ch != ' ' && cloud.x+x >= 0 && cloud.x+x < width {
-					termbox.SetCell(cloud.x+x, cloud.y+y, ch, termbox.ColorWhite, termbox.ColorDefault)
-				}
+		if pr, ok := r.(PixelRenderer); ok {
+			pr.DrawPixels(sprite.pixels(), cloud.posX, float64(cloud.y), palette.cloud(cloud.shade), palette.Background)
+			continue
+		}
+		sprite.each(palette.cloud(cloud.shade), func(x, y int, ch rune, fg termbox.Attribute) {
+			// Only draw cells that are within screen bounds
+			if cloud.x+x >= 0 && cloud.x+x < width {
+				r.SetCell(cloud.x+x, cloud.y+y, ch, fg, palette.Background)
+			}
+		})
+	}
+}
+
+// drawSky draws the stars and the moon
+func (cm *CloudManager) drawSky(r Renderer) {
+	shift := int(cm.starShift)
+	for y := 1; y <= cloudMaxHeight+1; y++ {
+		for x := 0; x < width; x++ {
+			if ch := starAt(x+shift, y); ch != 0 {
+				r.SetCell(x, y, ch, palette.Star, palette.Background)
 			}
 		}
 	}
+
+	if pr, ok := r.(PixelRenderer); ok {
+		pr.DrawPixels(moonSprite.pixels(), cm.moonX, float64(cloudMinHeight), palette.Moon, palette.Background)
+		return
+	}
+	moonX := int(cm.moonX)
+	moonSprite.each(palette.Moon, func(x, y int, ch rune, fg termbox.Attribute) {
+		if moonX+x >= 0 && moonX+x < width {
+			r.SetCell(moonX+x, cloudMinHeight+y, ch, fg, palette.Background)
+		}
+	})
+}
+
+// starAt returns the star at column col of the endless sky and row y, or 0
+// if there is none. A hash picks them, so every night has the same sky.
+func starAt(col, y int) rune {
+	h := uint32(col)*2654435761 ^ uint32(y)*2246822519
+	h ^= h >> 15
+	if h%starRarity != 0 {
+		return 0
+	}
+	return []rune{'.', '.', '+', '*'}[h>>8%4]
 }
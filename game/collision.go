@@ -5,10 +5,10 @@ import (
 )
 
 // checkCollision detects if the dino has collided with an obstacle
-func (g *Game) checkCollision() bool {
+func (s *Simulation) checkCollision() bool {
 	// 检查与所有障碍物的碰撞
-	for _, obstacle := range g.obstacleManager.GetObstacles() {
-		if checkSingleCollision(g.dino, obstacle) {
+	for _, obstacle := range s.obstacleManager.GetObstacles() {
+		if checkSingleCollision(s.dino, obstacle) {
 			return true
 		}
 	}
@@ -20,9 +20,9 @@ func checkSingleCollision(dino *Dino, obstacle IObstacle) bool {
 	// get dino sprite based on state
 	var dinoSprite Sprite
 	if dino.IsDucking() {
-		dinoSprite = dinoDuckFrames[dino.animFrame]
+		dinoSprite = dino.frame(dinoDuckFrames).Shape()
 	} else {
-		dinoSprite = dinoStandFrames[dino.animFrame]
+		dinoSprite = dino.frame(dinoStandFrames).Shape()
 	}
 
 	// get obstacle sprite
@@ -38,8 +38,8 @@ func checkSingleCollision(dino *Dino, obstacle IObstacle) bool {
 	obstacleXInt := int(math.Round(obstacleX))
 
 	// check for overlap in x and y dimensions
-	dinoWidth := getMaxWidth(dinoSprite)
-	obstacleWidth := getMaxWidth(obstacleSprite)
+	dinoWidth := dinoSprite.Width()
+	obstacleWidth := obstacleSprite.Width()
 	dinoHeight := len(dinoSprite)
 	obstacleHeight := len(obstacleSprite)
 
@@ -53,12 +53,14 @@ func checkSingleCollision(dino *Dino, obstacle IObstacle) bool {
 		return false
 	}
 
-	// check for character-level collision
+	// check for character-level collision, one rune per cell
+	dinoCells := dinoSprite.cells()
+	obstacleCells := obstacleSprite.cells()
 	for dy := 0; dy < dinoHeight; dy++ {
 		for dx := 0; dx < dinoWidth; dx++ {
 			dinoRow := dy
 			dinoCol := dx
-			if dinoRow >= len(dinoSprite) || dinoCol >= len(dinoSprite[dinoRow]) {
+			if dinoRow >= len(dinoCells) || dinoCol >= len(dinoCells[dinoRow]) {
 				continue
 			}
 
@@ -66,13 +68,13 @@ func checkSingleCollision(dino *Dino, obstacle IObstacle) bool {
 			obstacleRow := dy + dinoY - obstacleY
 			obstacleCol := dx + dinoX - int(math.Round(obstacleX))
 
-			if obstacleRow < 0 || obstacleRow >= len(obstacleSprite) ||
-				obstacleCol < 0 || obstacleCol >= len(obstacleSprite[obstacleRow]) {
+			if obstacleRow < 0 || obstacleRow >= len(obstacleCells) ||
+				obstacleCol < 0 || obstacleCol >= len(obstacleCells[obstacleRow]) {
 				continue
 			}
 
-			dinoChar := dinoSprite[dinoRow][dinoCol]
-			obstacleChar := obstacleSprite[obstacleRow][obstacleCol]
+			dinoChar := dinoCells[dinoRow][dinoCol]
+			obstacleChar := obstacleCells[obstacleRow][obstacleCol]
 			if dinoChar != ' ' && obstacleChar != ' ' {
 				return true
 			}
@@ -82,13 +84,11 @@ func checkSingleCollision(dino *Dino, obstacle IObstacle) bool {
 	return false
 }
 
-// getMaxWidth returns the maximum width of a sprite
-func getMaxWidth(s Sprite) int {
-	maxWidth := 0
-	for _, row := range s {
-		if len(row) > maxWidth {
-			maxWidth = len(row)
-		}
+// cells returns the rows of a sprite as runes
+func (s Sprite) cells() [][]rune {
+	cells := make([][]rune, len(s))
+	for i, line := range s {
+		cells[i] = []rune(line)
 	}
-	return maxWidth
+	return cells
 }
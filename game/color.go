@@ -0,0 +1,222 @@
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+	"math"
+	"os"
+	"strings"
+)
+
+// 颜色模式：8 色、256 色或真彩色。主题只定义 8 种基本颜色，
+// 终端支持更多颜色时再换算成 256 色或 RGB，用来混合昼夜颜色和给精灵加明暗。
+
+// Colour modes for --color and the color setting in [display]
+const (
+	colorAuto = "auto"
+	color8    = "8"
+	color256  = "256"
+	colorTrue = "truecolor"
+)
+
+// Colour depths
+const (
+	colors8    = 8
+	colors256  = 256
+	colorsTrue = 1 << 24
+)
+
+// colorDepth is how many colours the terminal shows. With more than 8 the
+// day/night fades blend smoothly and sprites are shaded; with 8 the palette
+// switches halfway through a fade.
+var colorDepth = colors8
+
+// colorSetting is the colour mode from the config file
+var colorSetting = colorAuto
+
+// attrMask selects bold, underline and the other attributes of a cell,
+// leaving out its colour
+const attrMask = termbox.AttrBold | termbox.AttrBlink | termbox.AttrHidden | termbox.AttrDim |
+	termbox.AttrUnderline | termbox.AttrCursive | termbox.AttrReverse
+
+// rgb is a colour with 0-255 components
+type rgb [3]float64
+
+// ansiColors are the usual RGB values of the 8 terminal colours, from
+// ColorBlack to ColorWhite, and brightColors those of their bright variants
+var (
+	ansiColors = [8]rgb{
+		{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+		{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	}
+	brightColors = [8]rgb{
+		{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+		{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+	}
+)
+
+// cubeLevels are the component values of the 6x6x6 colour cube of the 256
+// colour palette
+var cubeLevels = [6]float64{0, 95, 135, 175, 215, 255}
+
+// ColorModes returns the accepted colour modes
+func ColorModes() []string {
+	return []string{colorAuto, color8, color256, colorTrue}
+}
+
+// validColorMode reports whether mode is one of ColorModes
+func validColorMode(mode string) bool {
+	for _, m := range ColorModes() {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// detectColorMode guesses the colour mode from COLORTERM and TERM
+func detectColorMode() string {
+	colorterm := os.Getenv("COLORTERM")
+	switch {
+	case colorterm == "truecolor" || colorterm == "24bit":
+		return colorTrue
+	case strings.Contains(os.Getenv("TERM"), "256color"):
+		return color256
+	}
+	return color8
+}
+
+// initColors puts termbox into the given colour mode, detecting it for
+// "auto". A mode termbox can't use on this platform (the Windows console)
+// falls back to 8 colours. termbox must be initialised.
+func initColors(mode string) {
+	if mode == colorAuto || mode == "" {
+		mode = detectColorMode()
+	}
+	colorDepth = colors8
+	switch mode {
+	case colorTrue:
+		if termbox.SetOutputMode(termbox.OutputRGB) == termbox.OutputRGB {
+			colorDepth = colorsTrue
+			return
+		}
+	case color256:
+		if termbox.SetOutputMode(termbox.Output256) == termbox.Output256 {
+			colorDepth = colors256
+			return
+		}
+	}
+	termbox.SetOutputMode(termbox.OutputNormal)
+}
+
+// encode returns the attribute that shows c in the current colour mode
+func encode(c rgb) termbox.Attribute {
+	var v [3]uint8
+	for i, x := range c {
+		v[i] = uint8(math.Round(math.Max(0, math.Min(255, x))))
+	}
+	switch colorDepth {
+	case colorsTrue:
+		return termbox.RGBToAttribute(v[0], v[1], v[2])
+	case colors256:
+		return color256Of(c)
+	}
+	best := 0
+	for i, a := range ansiColors {
+		if distance(a, c) < distance(ansiColors[best], c) {
+			best = i
+		}
+	}
+	return termbox.ColorBlack + termbox.Attribute(best)
+}
+
+// decode returns the RGB value of a colour in the current colour mode.
+// The 8 basic colours look like the theme says.
+func (t Theme) decode(c termbox.Attribute) rgb {
+	if c >= termbox.ColorBlack && c <= termbox.ColorWhite {
+		return t.rgb(c, true)
+	}
+	if colorDepth == colorsTrue {
+		r, g, b := termbox.AttributeToRGB(c)
+		return rgb{float64(r), float64(g), float64(b)}
+	}
+	index := int(c) - 1
+	switch {
+	case index >= 8 && index < 16:
+		return brightColors[index-8]
+	case index >= 16 && index < 232:
+		index -= 16
+		return rgb{cubeLevels[index/36], cubeLevels[index/6%6], cubeLevels[index%6]}
+	case index >= 232 && index < 256:
+		grey := float64(8 + 10*(index-232))
+		return rgb{grey, grey, grey}
+	}
+	return t.rgb(termbox.ColorDefault, true)
+}
+
+// shade lightens a palette colour towards white (amount > 0) or darkens it
+// towards black (amount < 0), by amount up to 1. With 8 colours, and for
+// the terminal's default colour, it returns a unchanged.
+func (t Theme) shade(a termbox.Attribute, amount float64) termbox.Attribute {
+	c := a &^ attrMask
+	if colorDepth == colors8 || c == termbox.ColorDefault || amount == 0 {
+		return a
+	}
+	amount = math.Max(-1, math.Min(1, amount))
+	v := t.decode(c)
+	target := 255.0
+	if amount < 0 {
+		target, amount = 0, -amount
+	}
+	for i := range v {
+		v[i] += (target - v[i]) * amount
+	}
+	return encode(v) | a&attrMask
+}
+
+// termboxColor prepares a cell colour for termbox. In truecolor mode
+// termbox reads every attribute other than a bare ColorDefault as an RGB
+// colour, so ColorDefault with bold or reverse video would show black;
+// the palette's guess of the default colour is used instead then.
+func termboxColor(a termbox.Attribute, fg bool) termbox.Attribute {
+	if colorDepth != colorsTrue || a == termbox.ColorDefault || a&^attrMask != termbox.ColorDefault {
+		return a
+	}
+	return encode(palette.rgb(termbox.ColorDefault, fg)) | a&attrMask
+}
+
+// color256Of returns the closest colour of the 256 colour palette, from the
+// 6x6x6 colour cube or the grey ramp
+func color256Of(c rgb) termbox.Attribute {
+	var cube rgb
+	var index int
+	for i, v := range c {
+		best := 0
+		for l := range cubeLevels {
+			if math.Abs(cubeLevels[l]-v) < math.Abs(cubeLevels[best]-v) {
+				best = l
+			}
+		}
+		cube[i] = cubeLevels[best]
+		index = 6*index + best
+	}
+	index += 16
+
+	// 灰阶 232-255：8, 18, ..., 238
+	step := math.Round(((c[0]+c[1]+c[2])/3 - 8) / 10)
+	step = math.Max(0, math.Min(23, step))
+	level := 8 + 10*step
+	if distance(rgb{level, level, level}, c) < distance(cube, c) {
+		index = 232 + int(step)
+	}
+	// termbox 的 256 色属性从 1 开始，0 是默认颜色
+	return termbox.Attribute(index + 1)
+}
+
+// distance is the squared distance between two colours
+func distance(a, b rgb) float64 {
+	var d float64
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return d
+}
@@ -0,0 +1,158 @@
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth int
+		c     rgb
+		want  termbox.Attribute
+	}{
+		{"8 colours, red", colors8, rgb{200, 10, 10}, termbox.ColorRed},
+		{"8 colours, dark grey is black", colors8, rgb{40, 40, 40}, termbox.ColorBlack},
+		{"8 colours, light grey is white", colors8, rgb{200, 200, 200}, termbox.ColorWhite},
+		{"256 colours, cube", colors256, rgb{255, 0, 0}, 16 + 36*5 + 1},
+		{"256 colours, near a cube level", colors256, rgb{100, 130, 170}, 16 + 36*1 + 6*2 + 3 + 1},
+		{"256 colours, grey ramp", colors256, rgb{128, 128, 128}, 232 + 12 + 1},
+		{"truecolor", colorsTrue, rgb{12, 34, 56}, termbox.RGBToAttribute(12, 34, 56)},
+		{"truecolor clamps", colorsTrue, rgb{-20, 300, 127.6}, termbox.RGBToAttribute(0, 255, 128)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useColorDepth(t, tt.depth)
+			if got := encode(tt.c); got != tt.want {
+				t.Errorf("encode(%v) = %#x, want %#x", tt.c, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDecode checks that colours come back from their attributes in the
+// 256 colour and truecolor modes
+func TestDecode(t *testing.T) {
+	var th Theme
+	for _, depth := range []int{colors256, colorsTrue} {
+		useColorDepth(t, depth)
+		for _, c := range []rgb{{0, 0, 0}, {255, 255, 255}, {95, 135, 215}, {128, 128, 128}} {
+			if got := th.decode(encode(c)); distance(got, c) > 3*5*5 {
+				t.Errorf("%d colours: %v comes back as %v", depth, c, got)
+			}
+		}
+		if got, want := th.decode(termbox.ColorRed), ansiColors[1]; got != want {
+			t.Errorf("%d colours: red is %v, want %v", depth, got, want)
+		}
+	}
+}
+
+func TestShade(t *testing.T) {
+	var th Theme
+	red := termbox.ColorRed | termbox.AttrBold
+	tests := []struct {
+		name   string
+		depth  int
+		a      termbox.Attribute
+		amount float64
+		want   termbox.Attribute
+	}{
+		{"8 colours stay", colors8, red, 0.5, red},
+		{"default colour stays", colorsTrue, termbox.ColorDefault | termbox.AttrBold, 0.5, termbox.ColorDefault | termbox.AttrBold},
+		{"no shading", colorsTrue, red, 0, red},
+		{"lighter", colorsTrue, red, 0.5, termbox.RGBToAttribute(230, 128, 128) | termbox.AttrBold},
+		{"darker", colorsTrue, red, -0.5, termbox.RGBToAttribute(103, 0, 0) | termbox.AttrBold},
+		{"white", colorsTrue, red, 1, termbox.RGBToAttribute(255, 255, 255) | termbox.AttrBold},
+		{"256 colours", colors256, termbox.ColorRed, -0.5, color256Of(rgb{102.5, 0, 0})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useColorDepth(t, tt.depth)
+			if got := th.shade(tt.a, tt.amount); got != tt.want {
+				t.Errorf("shade(%#x, %v) = %#x, want %#x", tt.a, tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTermboxColor checks that no colour termbox gets in truecolor mode
+// turns into black by accident
+func TestTermboxColor(t *testing.T) {
+	saved := palette
+	t.Cleanup(func() { palette = saved })
+	palette = themes[0]
+	grey := termbox.RGBToAttribute(229, 229, 229)
+	tests := []struct {
+		name  string
+		depth int
+		a     termbox.Attribute
+		fg    bool
+		want  termbox.Attribute
+	}{
+		{"default", colorsTrue, termbox.ColorDefault, true, termbox.ColorDefault},
+		{"default bold", colorsTrue, termbox.ColorDefault | termbox.AttrBold, true, grey | termbox.AttrBold},
+		{"default reverse", colorsTrue, termbox.ColorDefault | termbox.AttrReverse, true, grey | termbox.AttrReverse},
+		{"default background", colorsTrue, termbox.ColorDefault | termbox.AttrBold, false, termbox.RGBToAttribute(0, 0, 0) | termbox.AttrBold},
+		{"rgb bold", colorsTrue, termbox.RGBToAttribute(1, 2, 3) | termbox.AttrBold, true, termbox.RGBToAttribute(1, 2, 3) | termbox.AttrBold},
+		{"256 colours", colors256, termbox.ColorDefault | termbox.AttrBold, true, termbox.ColorDefault | termbox.AttrBold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useColorDepth(t, tt.depth)
+			if got := termboxColor(tt.a, tt.fg); got != tt.want {
+				t.Errorf("termboxColor(%#x) = %#x, want %#x", tt.a, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("light theme", func(t *testing.T) {
+		useColorDepth(t, colorsTrue)
+		palette = Theme{Light: true}
+		if got, want := termboxColor(termbox.ColorDefault|termbox.AttrBold, true), termbox.RGBToAttribute(0, 0, 0)|termbox.AttrBold; got != want {
+			t.Errorf("bold text %#x, want %#x", got, want)
+		}
+	})
+}
+
+// TestTruecolorPalettes checks every colour of every theme, by day and by
+// night, as termbox gets it in truecolor mode: bold or reverse video must
+// never sit on ColorDefault, which termbox would read as black
+func TestTruecolorPalettes(t *testing.T) {
+	saved := palette
+	t.Cleanup(func() { palette = saved })
+	useColorDepth(t, colorsTrue)
+
+	for _, th := range themes {
+		for _, darkness := range []float64{0, 0.5, 1} {
+			palette = th.at(darkness)
+			colors := []termbox.Attribute{palette.Dino, palette.Cactus, palette.Text, palette.Alert, palette.Moon, palette.Pulse[0], palette.Pulse[1], palette.Text | termbox.AttrReverse}
+			for _, a := range colors {
+				got := termboxColor(a, true)
+				if got&attrMask != 0 && got&^attrMask == termbox.ColorDefault {
+					t.Errorf("%s at %v: %#x has attributes on the default colour", th.Name, darkness, got)
+				}
+			}
+		}
+	}
+}
+
+func TestDetectColorMode(t *testing.T) {
+	tests := []struct {
+		term, colorterm string
+		want            string
+	}{
+		{"xterm", "", color8},
+		{"xterm-256color", "", color256},
+		{"xterm-256color", "truecolor", colorTrue},
+		{"screen", "24bit", colorTrue},
+		{"", "", color8},
+	}
+	for _, tt := range tests {
+		t.Setenv("TERM", tt.term)
+		t.Setenv("COLORTERM", tt.colorterm)
+		if got := detectColorMode(); got != tt.want {
+			t.Errorf("TERM=%q COLORTERM=%q: %q, want %q", tt.term, tt.colorterm, got, tt.want)
+		}
+	}
+}
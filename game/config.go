@@ -1,7 +1,6 @@
 package game
 
 import (
-	"github.com/nsf/termbox-go"
 	"time"
 )
 
@@ -13,23 +12,44 @@ var width = 80
 // 最大有效游戏宽度（超过这个宽度，障碍物不会从更远处生成）
 const maxEffectiveWidth = 120
 
-// 固定游戏高度（行数）
-const height = 15
+// 最小游戏宽度（终端更窄时显示“终端太小”提示）
+const minWidth = 40
 
-// 帧率（FPS）
-const fps = 60 // 从24提高到60，使游戏更流畅
+// 默认游戏高度（行数），也是最小高度；可通过 --height 修改
+const defaultHeight = 15
+
+// 最大游戏高度（行数）
+const maxHeight = 40
+
+// 游戏高度（行数），由 SetHeight 设置
+var height = defaultHeight
+
+// 缩小窗口后被拉回屏幕内的障碍物之间的最小间距
+const resizeObstacleSpacing = 20
+
+// 模拟频率（每秒 tick 数）：物理和计分都以固定步长推进，与渲染帧率无关
+const tickRate = 60 // 从24提高到60，使游戏更流畅
+
+// 默认渲染帧率（可通过 --fps 修改，不影响游戏速度）
+const defaultFPS = 60
+
+// 渲染落后时最多追赶的时间，避免长时间卡顿后一次性模拟过多 tick
+const maxFrameLag = 250 * time.Millisecond
 
 // 原始帧率（用于计算速度调整因子）
 const originalFps = 24
 
 // 速度调整因子（保持与原始24FPS相同的游戏速度）
-const speedFactor = float64(originalFps) / float64(fps)
+const speedFactor = float64(originalFps) / float64(tickRate)
+
+// 默认高度下的跳跃高度（行数）
+var baseJumpHeight = 5
 
-// 跳跃高度（行数）
-const jumpHeight = 5
+// 跳跃高度（行数），随游戏高度缩放
+var jumpHeight = baseJumpHeight
 
 // 跳跃持续帧数（根据新帧率调整）
-const jumpDuration = fps / 4
+const jumpDuration = tickRate / 4
 
 // initial jump velocity (calculated to reach jumpHeight in jumpDuration frames)
 var jumpVelocity = -2 * float64(jumpHeight) / float64(jumpDuration)
@@ -38,10 +58,7 @@ var jumpVelocity = -2 * float64(jumpHeight) / float64(jumpDuration)
 var gravity = -jumpVelocity / float64(jumpDuration)
 
 // hang time at apex in frames
-const hangDuration = 2
-
-// 障碍物每帧移动的格数（根据速度因子调整）
-var obstacleSpeed float64 = 1.0 * speedFactor
+var hangDuration = 2
 
 // ground extension speed in cells per frame（根据速度因子调整）
 var groundExtendSpeed float64 = 3 * speedFactor
@@ -50,10 +67,10 @@ var groundExtendSpeed float64 = 3 * speedFactor
 const initialGroundLength = 24
 
 // duck hold duration in frames
-var duckHoldDuration = fps/2 + 1
+var duckHoldDuration = tickRate/2 + 1
 
-// 每帧间隔
-var tickDuration = time.Second / time.Duration(fps)
+// 每个模拟 tick 的间隔
+var tickDuration = time.Second / time.Duration(tickRate)
 
 // 音效相关配置
 const (
@@ -61,6 +78,24 @@ const (
 	ScoreMilestone = 100  // 每得到100分播放一次得分音效
 )
 
+// dayNightInterval is how many points a day or a night lasts, from the
+// [display] section of the config file; 0 turns the cycle off
+var dayNightInterval = 700
+
+// audioSettings are the volumes and mutes, from the [audio] section of
+// the config file
+var audioSettings = audioConfig{Volume: 100, EffectsVolume: 100, EventsVolume: 100, MusicVolume: 60}
+
+// feedbackSettings map sounds to visual cues, from the [feedback] section
+// of the config file
+var feedbackSettings = feedbackConfig{
+	Mode:      feedbackAuto,
+	Jump:      effectNone,
+	Drop:      effectNone,
+	Score:     effectPulse,
+	Collision: effectDino,
+}
+
 // 分数闪烁相关配置
 const (
 	ScoreBlinkDuration = 1500 * time.Millisecond // 分数闪烁持续时间（1.5秒）
@@ -68,41 +103,155 @@ const (
 )
 
 // Animation frames for standing Dino
-var dinoStandFrames = []Sprite{
+var dinoStandFrames = []ColorSprite{
 	{
-		"       ++++ ",
-		"++    ++Q+++",
-		" + +++++_ww ",
-		"  ++++++    ",
-		"   |   |    ",
+		Glyphs: Sprite{
+			"       ++++ ",
+			"++    ++Q+++",
+			" + +++++_ww ",
+			"  ++++++    ",
+			"   |   |    ",
+		},
+		Mask: Sprite{
+			"       llll ",
+			"dd    bbEbbb",
+			" d bbbbbddd ",
+			"  bbllbb    ",
+			"   d   d    ",
+		},
+		Pixels: PixelArt{
+			"..............bbbbbbb",
+			".............bbbbbbbbb",
+			".............bb.bbbbbb",
+			".............bbbbbbbbb",
+			".............bbbbbbbbb",
+			"b............bbbbb",
+			"b............bbbbbbbb",
+			"bb..........bbbbb",
+			"bb.........bbbbbb",
+			"bbb.......bbbbbbbb",
+			"bbbb....bbbbbbbb.b",
+			".bbbbbbbbbbbbbb",
+			"..bbbbbbbbbbbbb",
+			"...bbbbbbbbbbb",
+			"....bbbbbbbbb",
+			".....bbbbbbb",
+			"......dd..dd",
+			"......d....d",
+			"......d....dd",
+			"......dd",
+		},
 	},
 	{
-		"       ++++ ",
-		" +    ++Q+++",
-		" + +++++_ww ",
-		"  ++++++    ",
-		"   /   /    ",
+		Glyphs: Sprite{
+			"       ++++ ",
+			" +    ++Q+++",
+			" + +++++_ww ",
+			"  ++++++    ",
+			"   /   /    ",
+		},
+		Mask: Sprite{
+			"       llll ",
+			" d    bbEbbb",
+			" d bbbbbddd ",
+			"  bbllbb    ",
+			"   d   d    ",
+		},
+		Pixels: PixelArt{
+			"..............bbbbbbb",
+			".............bbbbbbbbb",
+			".............bb.bbbbbb",
+			".............bbbbbbbbb",
+			".............bbbbbbbbb",
+			"b............bbbbb",
+			"b............bbbbbbbb",
+			"bb..........bbbbb",
+			"bb.........bbbbbb",
+			"bbb.......bbbbbbbb",
+			"bbbb....bbbbbbbb.b",
+			".bbbbbbbbbbbbbb",
+			"..bbbbbbbbbbbbb",
+			"...bbbbbbbbbbb",
+			"....bbbbbbbbb",
+			".....bbbbbbb",
+			"......dd..dd",
+			"......d...d",
+			"......dd..d",
+			"..........dd",
+		},
 	},
 }
 
 // Animation frames for ducking Dino
-var dinoDuckFrames = []Sprite{
+var dinoDuckFrames = []ColorSprite{
 	{
-		"       ++++ ",
-		" -    ++@+++",
-		"  ++++++__w ",
-		"   :   :    ",
+		Glyphs: Sprite{
+			"       ++++ ",
+			" -    ++@+++",
+			"  ++++++__w ",
+			"   :   :    ",
+		},
+		Mask: Sprite{
+			"       llll ",
+			" d    bbEbbb",
+			"  bbllbbddd ",
+			"   d   d    ",
+		},
+		Pixels: PixelArt{
+			"",
+			"",
+			"",
+			"",
+			"...............bbbbbbbb",
+			"b.............bb.bbbbbbb",
+			"bb...........bbbbbbbbb",
+			"bbbb.......bbbbbbbbbbb",
+			"bbbbbbbbbbbbbbbbbbbbbb",
+			".bbbbbbbbbbbbbbbbbbbbb",
+			"..bbbbbbbbbbbbbb.bb",
+			"...bbbbbbbbbbbb",
+			"....bbbbbbbbbb",
+			".....dd...dd",
+			".....d.....d",
+			".....dd....dd",
+		},
 	},
 	{
-		"       ++++ ",
-		" +    ++@+++",
-		"  ++++++__w ",
-		"   ;   ;    ",
+		Glyphs: Sprite{
+			"       ++++ ",
+			" +    ++@+++",
+			"  ++++++__w ",
+			"   ;   ;    ",
+		},
+		Mask: Sprite{
+			"       llll ",
+			" d    bbEbbb",
+			"  bbllbbddd ",
+			"   d   d    ",
+		},
+		Pixels: PixelArt{
+			"",
+			"",
+			"",
+			"",
+			"...............bbbbbbbb",
+			"b.............bb.bbbbbbb",
+			"bb...........bbbbbbbbb",
+			"bbbb.......bbbbbbbbbbb",
+			"bbbbbbbbbbbbbbbbbbbbbb",
+			".bbbbbbbbbbbbbbbbbbbbb",
+			"..bbbbbbbbbbbbbb.bb",
+			"...bbbbbbbbbbbb",
+			"....bbbbbbbbbb",
+			".....dd...dd",
+			"......d...d",
+			"......dd..dd",
+		},
 	},
 }
 
 // frames between animation switches (adjusted for new FPS)
-const animPeriod = fps / 12
+const animPeriod = tickRate / 12
 
 // ObstacleType represents the type of obstacle
 type ObstacleType int
@@ -116,148 +265,262 @@ const (
 )
 
 // ObstacleFrames stores all obstacle animation frames by type
-var ObstacleFrames = map[ObstacleType][]Sprite{
+var ObstacleFrames = map[ObstacleType][]ColorSprite{
 	SingleCactusType: {
 		{
-			" | ",
-			"/|\\",
-			" | ",
+			Glyphs: Sprite{
+				" | ",
+				"/|\\",
+				" | ",
+			},
+			Mask: Sprite{
+				" l ",
+				"dld",
+				" b ",
+			},
 		},
 		{
-			" | ",
-			"\\|/",
-			" | ",
+			Glyphs: Sprite{
+				" | ",
+				"\\|/",
+				" | ",
+			},
+			Mask: Sprite{
+				" l ",
+				"dld",
+				" b ",
+			},
 		},
 	},
 	ShortCactusType: {
 		{
-			"/:\\/:\\",
-			" | |",
+			Glyphs: Sprite{
+				"/:\\/:\\",
+				" | |",
+			},
+			Mask: Sprite{
+				"dlddld",
+				" b b",
+			},
 		},
 		{
-			"/|\\/|\\",
-			" | |",
+			Glyphs: Sprite{
+				"/|\\/|\\",
+				" | |",
+			},
+			Mask: Sprite{
+				"dlddld",
+				" b b",
+			},
 		},
 	},
 	GroupCactusType: {
 		{
-			"    |  ",
-			"/|\\/|\\",
-			" |  |",
+			Glyphs: Sprite{
+				"    |  ",
+				"/|\\/|\\",
+				" |  |",
+			},
+			Mask: Sprite{
+				"    l  ",
+				"dlddld",
+				" b  b",
+			},
 		},
 		{
-			"    |  ",
-			"\\|/\\|/",
-			" |  |",
+			Glyphs: Sprite{
+				"    |  ",
+				"\\|/\\|/",
+				" |  |",
+			},
+			Mask: Sprite{
+				"    l  ",
+				"dlddld",
+				" b  b",
+			},
 		},
 	},
 	BirdType: {
 		{
-			" |   ",
-			"<o=- ",
-			" |   ",
+			Glyphs: Sprite{
+				" |   ",
+				"<o=- ",
+				" |   ",
+			},
+			Mask: Sprite{
+				" d   ",
+				"dEbl ",
+				" d   ",
+			},
 		},
 		{
-			" /   ",
-			"<O=- ",
-			" \\   ",
+			Glyphs: Sprite{
+				" /   ",
+				"<O=- ",
+				" \\   ",
+			},
+			Mask: Sprite{
+				" d   ",
+				"dEbl ",
+				" d   ",
+			},
 		},
 	},
 	BigBirdType: {
 		{
-			"  /\\    ",
-			" /  \\   ",
-			"<ooo=-- ",
-			" \\__/   ",
+			Glyphs: Sprite{
+				"  /\\    ",
+				" /  \\   ",
+				"<ooo=-- ",
+				" \\__/   ",
+			},
+			Mask: Sprite{
+				"  ll    ",
+				" lbbl   ",
+				"dlllbdd ",
+				" dddd   ",
+			},
 		},
 		{
-			"  /\\    ",
-			" /  \\   ",
-			"<OOO=-- ",
-			" \\__/   ",
+			Glyphs: Sprite{
+				"  /\\    ",
+				" /  \\   ",
+				"<OOO=-- ",
+				" \\__/   ",
+			},
+			Mask: Sprite{
+				"  ll    ",
+				" lbbl   ",
+				"dlllbdd ",
+				" dddd   ",
+			},
 		},
 	},
 }
 
-// Cloud sprites with different shapes
-var cloudSprites = []Sprite{
+// Cloud sprites with different shapes; the inside of a cloud hides what is
+// behind it
+var cloudSprites = []ColorSprite{
 	{
-		"   .--.    ",
-		" .(    ).  ",
-		"(___.__)  ",
+		Glyphs: Sprite{
+			"   .--.    ",
+			" .(    ).  ",
+			"(___.__)  ",
+		},
+		Mask: Sprite{
+			"   llll    ",
+			" lbbbbbbl  ",
+			"dddddddd  ",
+		},
 	},
 	{
-		"  .-.      ",
-		" (   ).    ",
-		"(___(__)  ",
+		Glyphs: Sprite{
+			"  .-.      ",
+			" (   ).    ",
+			"(___(__)  ",
+		},
+		Mask: Sprite{
+			"  lll      ",
+			" bbbbbl    ",
+			"dddddddd  ",
+		},
 	},
 	{
-		"    .--.   ",
-		".-(    )-. ",
-		"(________) ",
+		Glyphs: Sprite{
+			"    .--.   ",
+			".-(    )-. ",
+			"(________) ",
+		},
+		Mask: Sprite{
+			"    llll   ",
+			"lbbbbbbbbl ",
+			"dddddddddd ",
+		},
+	},
+}
+
+// moonSprite rises at night, with the stars; it hides the stars behind it
+var moonSprite = ColorSprite{
+	Glyphs: Sprite{
+		" .-.",
+		"(  (",
+		" `-'",
+	},
+	Mask: Sprite{
+		" LLL",
+		"LbbL",
+		" LLL",
 	},
 }
 
 // bird flight heights (row index) above bottom of screen
-// Small birds can appear at two different heights for variety
+// Small birds can appear at two different heights for variety.
+// These depend on the dino sprite size, so SetHeight keeps them at the
+// same distance from the ground.
 var birdFlightRows = []int{
-	12, // High position - requires ducking
-	9,  // Low position - requires jumping
+	height - 3, // High position - requires ducking
+	height - 6, // Low position - requires jumping
 }
 
+// 默认高度下大鸟离地面（恐龙所在行）的行数，随跳跃高度缩放
+const baseBigBirdClearance = 6
+
 // big bird flight height (row index) above bottom of screen
-const bigBirdFlightRow = 7
+var bigBirdFlightRow = height - 2 - baseBigBirdClearance
 
 // —— 云朵配置参数 ——
 
 // 云朵最小高度（行号，从上往下计数）
-const cloudMinHeight = 1
+var cloudMinHeight = 1
 
-// 云朵最大高度（行号，从上往下计数）
-const cloudMaxHeight = 3
+// 默认高度下云朵的最大高度（行号，从上往下计数）
+var baseCloudMaxHeight = 3
+
+// 云朵最大高度（行号，从上往下计数），随游戏高度缩放
+var cloudMaxHeight = baseCloudMaxHeight
 
 // 云朵最小移动速度
-const cloudMinSpeed = 0.2
+var cloudMinSpeed = 0.2
 
 // 云朵最大移动速度
-const cloudMaxSpeed = 0.5
+var cloudMaxSpeed = 0.5
 
 // 初始云朵最小数量
-const cloudMinCount = 3
+var cloudMinCount = 3
 
 // 初始云朵最大数量
-const cloudMaxCount = 3
+var cloudMaxCount = 3
 
 // 云朵右侧边缘缓冲区大小
-const cloudRightEdgeBuffer = 15
+var cloudRightEdgeBuffer = 15
 
 // 云朵最小额外间距
-const cloudMinExtraSpace = 10
+var cloudMinExtraSpace = 10
 
 // 云朵最大额外间距
-const cloudMaxExtraSpace = 25
+var cloudMaxExtraSpace = 25
 
 // 云朵之间的最小缓冲区大小
-const cloudBufferSpace = 5
+var cloudBufferSpace = 5
 
 // StageConfig defines dynamic game parameters per stage based on score.
 type StageConfig struct {
-	ScoreThreshold int     // minimum score to enter this stage
-	Speed          float64 // obstacleSpeed for this stage
-	CactusProb     float64 // 仙人掌类别的总概率 (0-1.0)
-	BirdProb       float64 // 鸟类别的总概率 (= 1.0 - CactusProb)
+	ScoreThreshold int     `toml:"score_threshold" json:"score_threshold"` // minimum score to enter this stage
+	Speed          float64 `toml:"speed" json:"speed"`                     // obstacleSpeed for this stage
+	CactusProb     float64 `toml:"cactus_prob" json:"cactus_prob"`         // 仙人掌类别的总概率 (0-1.0)
+	BirdProb       float64 `toml:"-" json:"-"`                             // 鸟类别的总概率 (= 1.0 - CactusProb)
 
 	// 仙人掌类别内部的概率分布 (这些值加起来应该等于1.0)
-	SingleCactusRatio float64 // 单个仙人掌在仙人掌类别中的占比
-	ShortCactusRatio  float64 // 矮仙人掌在仙人掌类别中的占比
-	GroupCactusRatio  float64 // 组合仙人掌在仙人掌类别中的占比
+	SingleCactusRatio float64 `toml:"single_cactus_ratio" json:"single_cactus_ratio"` // 单个仙人掌在仙人掌类别中的占比
+	ShortCactusRatio  float64 `toml:"short_cactus_ratio" json:"short_cactus_ratio"`   // 矮仙人掌在仙人掌类别中的占比
+	GroupCactusRatio  float64 `toml:"group_cactus_ratio" json:"group_cactus_ratio"`   // 组合仙人掌在仙人掌类别中的占比
 
 	// 鸟类别内部的概率分布 (这些值加起来应该等于1.0)
-	SmallBirdRatio float64 // 小鸟在鸟类别中的占比
-	BigBirdRatio   float64 // 大鸟在鸟类别中的占比
+	SmallBirdRatio float64 `toml:"small_bird_ratio" json:"small_bird_ratio"` // 小鸟在鸟类别中的占比
+	BigBirdRatio   float64 `toml:"big_bird_ratio" json:"big_bird_ratio"`     // 大鸟在鸟类别中的占比
 
-	MinGap int // 障碍物之间的最小间距（屏幕单位）
-	MaxGap int // 障碍物之间的最大间距（屏幕单位）
+	MinGap int `toml:"min_gap" json:"min_gap"` // 障碍物之间的最小间距（屏幕单位）
+	MaxGap int `toml:"max_gap" json:"max_gap"` // 障碍物之间的最大间距（屏幕单位）
 }
 
 // stageConfigs lists the stages in ascending order of score threshold.
@@ -387,21 +650,9 @@ var stageConfigs = []StageConfig{
 // duration of smooth transition between stages
 var stageTransitionDuration = 3000 * time.Millisecond
 
-// Key bindings
-const (
-	KeyJump    = termbox.KeySpace     // jump action
-	KeyJumpAlt = termbox.KeyArrowUp   // alternate jump action
-	KeyDuck    = termbox.KeyArrowDown // duck action
-	KeyQuit    = termbox.KeyEsc       // quit action
-	KeyRelease = termbox.KeyArrowDown // 用于检测下键释放（实际上是同一个键）
-)
-
-// Character key bindings
-const (
-	KeyQuitRune    = 'q' // alternate quit
-	KeyRestartRune = 'r' // restart game
-	KeyPauseRune   = 'p' // pause/resume game
-)
+// Key bindings for every input action (can be replaced from the config file
+// or on the key binding screen)
+var keyMap = DefaultKeyMap()
 
 // 障碍物组合配置
 type ObstacleCombination struct {
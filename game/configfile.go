@@ -0,0 +1,379 @@
+package game
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// physicsConfig is the [physics] section of the config file
+type physicsConfig struct {
+	JumpHeight       int `toml:"jump_height" json:"jump_height"`               // jump height at the default play-field height
+	HangDuration     int `toml:"hang_duration" json:"hang_duration"`           // hang time at apex in ticks
+	DuckHoldDuration int `toml:"duck_hold_duration" json:"duck_hold_duration"` // how long a single duck lasts in ticks
+}
+
+// cloudConfig is the [clouds] section of the config file
+type cloudConfig struct {
+	MinHeight       int     `toml:"min_height" json:"min_height"`
+	MaxHeight       int     `toml:"max_height" json:"max_height"`
+	MinSpeed        float64 `toml:"min_speed" json:"min_speed"`
+	MaxSpeed        float64 `toml:"max_speed" json:"max_speed"`
+	MinCount        int     `toml:"min_count" json:"min_count"`
+	MaxCount        int     `toml:"max_count" json:"max_count"`
+	RightEdgeBuffer int     `toml:"right_edge_buffer" json:"right_edge_buffer"`
+	MinExtraSpace   int     `toml:"min_extra_space" json:"min_extra_space"`
+	MaxExtraSpace   int     `toml:"max_extra_space" json:"max_extra_space"`
+	BufferSpace     int     `toml:"buffer_space" json:"buffer_space"`
+}
+
+// audioConfig is the [audio] section of the config file. Volumes are in
+// percent; effects are the jump and drop sounds, events the score and
+// collision sounds. The background music is off unless Music is set.
+type audioConfig struct {
+	Volume         int  `toml:"volume" json:"volume"`
+	EffectsVolume  int  `toml:"effects_volume" json:"effects_volume"`
+	EventsVolume   int  `toml:"events_volume" json:"events_volume"`
+	EffectsMuted   bool `toml:"effects_muted" json:"effects_muted"`
+	EventsMuted    bool `toml:"events_muted" json:"events_muted"`
+	MilestonesOnly bool `toml:"milestones_only" json:"milestones_only"` // only the score milestone sound plays
+	Music          bool `toml:"music" json:"music"`                     // play the background music during runs
+	MusicVolume    int  `toml:"music_volume" json:"music_volume"`
+}
+
+// feedbackConfig is the [feedback] section of the config file: what to
+// show for each sound when it can't be heard (mode "auto"), always ("on")
+// or never ("off"). Effects are flash, pulse, dino, bell or none.
+type feedbackConfig struct {
+	Mode      string `toml:"mode" json:"mode"`
+	Jump      string `toml:"jump" json:"jump"`
+	Drop      string `toml:"drop" json:"drop"`
+	Score     string `toml:"score" json:"score"`
+	Collision string `toml:"collision" json:"collision"`
+}
+
+// displayConfig is the [display] section of the config file
+type displayConfig struct {
+	Theme            string `toml:"theme" json:"theme"`                           // colour theme, see ThemeNames
+	DayNightInterval int    `toml:"day_night_interval" json:"day_night_interval"` // points between day and night, 0 stays day
+	Color            string `toml:"color" json:"color"`                           // colour mode, see ColorModes
+	Render           string `toml:"render" json:"render"`                         // render mode, see RenderModes
+}
+
+// fileConfig is the layout of config.toml (or config.json). Every field is
+// optional; missing values keep their built-in defaults. A [[stages]] list
+// replaces all built-in stages. [keys] maps action names (see InputAction)
+// to lists of key names accepted by ParseKeyBinding.
+type fileConfig struct {
+	Physics  physicsConfig       `toml:"physics" json:"physics"`
+	Clouds   cloudConfig         `toml:"clouds" json:"clouds"`
+	Audio    audioConfig         `toml:"audio" json:"audio"`
+	Feedback feedbackConfig      `toml:"feedback" json:"feedback"`
+	Display  displayConfig       `toml:"display" json:"display"`
+	Keys     map[string][]string `toml:"keys" json:"keys"`
+	Stages   []StageConfig       `toml:"stages" json:"stages"`
+}
+
+// DefaultConfigPath returns config.toml in ConfigDir, or "" if there is
+// no home directory
+func DefaultConfigPath() string {
+	dir, err := ConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "config.toml")
+}
+
+// currentConfig returns the values currently in effect
+func currentConfig() fileConfig {
+	return fileConfig{
+		Physics: physicsConfig{
+			JumpHeight:       baseJumpHeight,
+			HangDuration:     hangDuration,
+			DuckHoldDuration: duckHoldDuration,
+		},
+		Clouds: cloudConfig{
+			MinHeight:       cloudMinHeight,
+			MaxHeight:       baseCloudMaxHeight,
+			MinSpeed:        cloudMinSpeed,
+			MaxSpeed:        cloudMaxSpeed,
+			MinCount:        cloudMinCount,
+			MaxCount:        cloudMaxCount,
+			RightEdgeBuffer: cloudRightEdgeBuffer,
+			MinExtraSpace:   cloudMinExtraSpace,
+			MaxExtraSpace:   cloudMaxExtraSpace,
+			BufferSpace:     cloudBufferSpace,
+		},
+		Audio:    audioSettings,
+		Feedback: feedbackSettings,
+		Display:  displayConfig{Theme: theme.Name, DayNightInterval: dayNightInterval, Color: colorSetting, Render: renderSetting},
+	}
+}
+
+// LoadConfig reads a TOML (or, with a .json extension, JSON) config file
+// and replaces the built-in tunables with its values. Nothing is changed
+// unless the whole file is valid.
+func LoadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	cfg := currentConfig()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	} else {
+		md, err := toml.Decode(string(data), &cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%s: unknown setting %q", path, undecoded[0].String())
+		}
+	}
+
+	if err := cfg.apply(); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// apply validates the config and, if it is valid, installs it
+func (cfg fileConfig) apply() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	p := cfg.Physics
+	check(p.JumpHeight >= 1 && p.JumpHeight <= 8, "physics.jump_height must be between 1 and 8, got %d", p.JumpHeight)
+	check(p.HangDuration >= 0, "physics.hang_duration must not be negative, got %d", p.HangDuration)
+	check(p.DuckHoldDuration >= 1, "physics.duck_hold_duration must be at least 1, got %d", p.DuckHoldDuration)
+
+	c := cfg.Clouds
+	check(c.MinHeight >= 1, "clouds.min_height must be at least 1, got %d", c.MinHeight)
+	check(c.MaxHeight >= c.MinHeight, "clouds.max_height (%d) must not be below clouds.min_height (%d)", c.MaxHeight, c.MinHeight)
+	check(c.MinSpeed > 0, "clouds.min_speed must be positive, got %g", c.MinSpeed)
+	check(c.MaxSpeed >= c.MinSpeed, "clouds.max_speed (%g) must not be below clouds.min_speed (%g)", c.MaxSpeed, c.MinSpeed)
+	check(c.MinCount >= 0, "clouds.min_count must not be negative, got %d", c.MinCount)
+	check(c.MaxCount >= c.MinCount, "clouds.max_count (%d) must not be below clouds.min_count (%d)", c.MaxCount, c.MinCount)
+	check(c.RightEdgeBuffer >= 0, "clouds.right_edge_buffer must not be negative, got %d", c.RightEdgeBuffer)
+	check(c.MinExtraSpace >= 0, "clouds.min_extra_space must not be negative, got %d", c.MinExtraSpace)
+	check(c.MaxExtraSpace >= c.MinExtraSpace, "clouds.max_extra_space (%d) must not be below clouds.min_extra_space (%d)", c.MaxExtraSpace, c.MinExtraSpace)
+	check(c.BufferSpace >= 0, "clouds.buffer_space must not be negative, got %d", c.BufferSpace)
+
+	a := cfg.Audio
+	check(a.Volume >= 0 && a.Volume <= 100, "audio.volume must be between 0 and 100, got %d", a.Volume)
+	check(a.EffectsVolume >= 0 && a.EffectsVolume <= 100, "audio.effects_volume must be between 0 and 100, got %d", a.EffectsVolume)
+	check(a.EventsVolume >= 0 && a.EventsVolume <= 100, "audio.events_volume must be between 0 and 100, got %d", a.EventsVolume)
+	check(a.MusicVolume >= 0 && a.MusicVolume <= 100, "audio.music_volume must be between 0 and 100, got %d", a.MusicVolume)
+
+	f := cfg.Feedback
+	check(f.Mode == feedbackAuto || f.Mode == feedbackOn || f.Mode == feedbackOff, "feedback.mode must be auto, on or off, got %q", f.Mode)
+	for _, name := range []string{SoundJump, SoundDrop, SoundScore, SoundCollision} {
+		effect := f.effect(name)
+		check(validEffect(effect), "feedback.%s must be flash, pulse, dino, bell or none, got %q", name, effect)
+	}
+
+	d := cfg.Display
+	newTheme, ok := lookupTheme(d.Theme)
+	check(ok, "display.theme must be one of %s, got %q", strings.Join(ThemeNames(), ", "), d.Theme)
+	check(d.DayNightInterval >= 0, "display.day_night_interval must not be negative, got %d", d.DayNightInterval)
+	check(validColorMode(d.Color), "display.color must be one of %s, got %q", strings.Join(ColorModes(), ", "), d.Color)
+	check(validRenderMode(d.Render), "display.render must be one of %s, got %q", strings.Join(RenderModes(), ", "), d.Render)
+
+	keys, keyErrs := parseKeyMap(cfg.Keys, keyMap)
+	errs = append(errs, keyErrs...)
+
+	if cfg.Stages != nil {
+		errs = append(errs, validateStages(cfg.Stages)...)
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	baseJumpHeight = p.JumpHeight
+	hangDuration = p.HangDuration
+	duckHoldDuration = p.DuckHoldDuration
+
+	cloudMinHeight = c.MinHeight
+	baseCloudMaxHeight = c.MaxHeight
+	cloudMinSpeed = c.MinSpeed
+	cloudMaxSpeed = c.MaxSpeed
+	cloudMinCount = c.MinCount
+	cloudMaxCount = c.MaxCount
+	cloudRightEdgeBuffer = c.RightEdgeBuffer
+	cloudMinExtraSpace = c.MinExtraSpace
+	cloudMaxExtraSpace = c.MaxExtraSpace
+	cloudBufferSpace = c.BufferSpace
+
+	audioSettings = a
+	feedbackSettings = f
+	theme, palette = newTheme, newTheme
+	dayNightInterval = d.DayNightInterval
+	colorSetting = d.Color
+	renderSetting = d.Render
+	keyMap = keys
+
+	if cfg.Stages != nil {
+		stageConfigs = cfg.Stages
+	}
+
+	// 重新计算依赖这些参数的值（跳跃物理、云朵高度）
+	SetHeight(height)
+	return nil
+}
+
+// validateStages checks that stages are usable: thresholds start at 0 and
+// go up, speeds and gaps are positive and every ratio group sums to 1
+func validateStages(stages []StageConfig) []error {
+	if len(stages) == 0 {
+		return []error{errors.New("stages: at least one stage is required")}
+	}
+	var errs []error
+	sumsToOne := func(v float64) bool { return math.Abs(v-1) < 1e-6 }
+	for i, st := range stages {
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, fmt.Errorf("stages[%d]: "+format, append([]interface{}{i}, args...)...))
+		}
+		if i == 0 && st.ScoreThreshold != 0 {
+			fail("the first stage must have score_threshold 0, got %d", st.ScoreThreshold)
+		}
+		if i > 0 && st.ScoreThreshold <= stages[i-1].ScoreThreshold {
+			fail("score_threshold %d must be greater than the previous stage's %d", st.ScoreThreshold, stages[i-1].ScoreThreshold)
+		}
+		if st.Speed <= 0 {
+			fail("speed must be positive, got %g", st.Speed)
+		}
+		if st.CactusProb < 0 || st.CactusProb > 1 {
+			fail("cactus_prob must be between 0 and 1, got %g", st.CactusProb)
+		}
+		if sum := st.SingleCactusRatio + st.ShortCactusRatio + st.GroupCactusRatio; !sumsToOne(sum) {
+			fail("single/short/group cactus ratios sum to %g, want 1", sum)
+		}
+		if sum := st.SmallBirdRatio + st.BigBirdRatio; !sumsToOne(sum) {
+			fail("small/big bird ratios sum to %g, want 1", sum)
+		}
+		if st.MinGap < 1 {
+			fail("min_gap must be at least 1, got %d", st.MinGap)
+		}
+		if st.MaxGap < st.MinGap {
+			fail("max_gap (%d) must not be below min_gap (%d)", st.MaxGap, st.MinGap)
+		}
+	}
+	return errs
+}
+
+// TuningFingerprint identifies the gameplay tunables in effect. Replays
+// store it so a run recorded with different settings is not mistaken for
+// a run under the current ones.
+func TuningFingerprint() string {
+	// 按键不影响游戏结果，不参与计算
+	cfg := currentConfig()
+	tuning := fmt.Sprintf("%+v %+v %+v", cfg.Physics, cfg.Clouds, stageConfigs)
+	if skinTuning != "" {
+		// 内置精灵不参与计算，旧录像的指纹保持不变
+		tuning += " " + skinTuning
+	}
+	sum := sha256.Sum256([]byte(tuning))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// defaultTuning is the fingerprint of the built-in tunables, assumed for
+// replays without a tuning record
+var defaultTuning = TuningFingerprint()
+
+// SaveKeyMap writes the current key bindings to the [keys] section of the
+// config file at path, creating the file if needed. Other settings in the
+// file are left as they are.
+func SaveKeyMap(path string) error {
+	keys := map[string][]string{}
+	for a := InputAction(0); a < inputActionCount; a++ {
+		keys[a.String()] = keyMap.names(a)
+	}
+	return saveConfigTable(path, "keys", keys)
+}
+
+// SaveAudioSettings writes the current volumes and mutes to the [audio]
+// section of the config file at path, like SaveKeyMap
+func SaveAudioSettings(path string) error {
+	return saveConfigTable(path, "audio", audioSettings)
+}
+
+// saveConfigTable replaces the table called name in the config file at
+// path with value, keeping the rest of the file including comments
+func saveConfigTable(path, name string, value interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var out []byte
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		doc := map[string]interface{}{}
+		if len(bytes.TrimSpace(data)) > 0 {
+			if err := json.Unmarshal(data, &doc); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+		}
+		doc[name] = value
+		if out, err = json.MarshalIndent(doc, "", "  "); err != nil {
+			return err
+		}
+		out = append(out, '\n')
+	} else {
+		var buf bytes.Buffer
+		buf.WriteString(withoutTOMLTable(string(data), name))
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		enc := toml.NewEncoder(&buf)
+		enc.Indent = ""
+		if err := enc.Encode(map[string]interface{}{name: value}); err != nil {
+			return err
+		}
+		out = buf.Bytes()
+		// 写入前确认结果仍然是合法的 TOML
+		var check map[string]interface{}
+		if _, err := toml.Decode(string(out), &check); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	return writeFileAtomic(path, out, 0644)
+}
+
+// withoutTOMLTable removes the [name] table from a TOML document, keeping
+// everything else including comments
+func withoutTOMLTable(doc, name string) string {
+	if strings.TrimSpace(doc) == "" {
+		return ""
+	}
+	var kept []string
+	inTable := false
+	for _, line := range strings.Split(doc, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			header := strings.TrimSpace(strings.SplitN(trimmed, "#", 2)[0])
+			inTable = header == "["+name+"]"
+		}
+		if !inTable {
+			kept = append(kept, line)
+		}
+	}
+	return strings.TrimRight(strings.Join(kept, "\n"), "\n") + "\n"
+}
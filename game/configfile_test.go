@@ -0,0 +1,397 @@
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// keepConfig puts back the settings LoadConfig replaces when the test ends
+func keepConfig(t *testing.T) {
+	cfg, keys, stages := currentConfig(), keyMap, stageConfigs
+	t.Cleanup(func() {
+		if err := cfg.apply(); err != nil {
+			t.Error(err)
+		}
+		keyMap, stageConfigs = keys, stages
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	tuning := TuningFingerprint()
+	tests := []struct {
+		name  string
+		file  string // file name, its extension picks TOML or JSON
+		data  string
+		errs  []string           // parts of the error; none for a valid file
+		check func(t *testing.T) // checks the installed settings of a valid file
+	}{
+		{
+			name: "physics",
+			file: "config.toml",
+			data: "[physics]\njump_height = 5\nhang_duration = 2\nduck_hold_duration = 10\n",
+			check: func(t *testing.T) {
+				if baseJumpHeight != 5 || hangDuration != 2 || duckHoldDuration != 10 {
+					t.Errorf("physics %d %d %d", baseJumpHeight, hangDuration, duckHoldDuration)
+				}
+				if jumpHeight != 5 {
+					t.Errorf("jump height %d at the default play-field height, want 5", jumpHeight)
+				}
+				if TuningFingerprint() == tuning {
+					t.Error("the tuning fingerprint did not change")
+				}
+			},
+		},
+		{
+			name: "json",
+			file: "config.json",
+			data: `{"clouds": {"min_count": 1, "max_count": 2}}`,
+			check: func(t *testing.T) {
+				if cloudMinCount != 1 || cloudMaxCount != 2 {
+					t.Errorf("cloud count %d-%d", cloudMinCount, cloudMaxCount)
+				}
+			},
+		},
+		{
+			name: "stages",
+			file: "config.toml",
+			data: `[[stages]]
+score_threshold = 0
+speed = 1.5
+cactus_prob = 1.0
+single_cactus_ratio = 0.5
+short_cactus_ratio = 0.25
+group_cactus_ratio = 0.25
+small_bird_ratio = 0.5
+big_bird_ratio = 0.5
+min_gap = 30
+max_gap = 50
+`,
+			check: func(t *testing.T) {
+				if len(stageConfigs) != 1 || stageConfigs[0].Speed != 1.5 || stageConfigs[0].MinGap != 30 {
+					t.Errorf("stages %+v", stageConfigs)
+				}
+			},
+		},
+		{
+			name: "keys",
+			file: "config.toml",
+			data: "[keys]\njump = [\"w\", \"space\"]\nquit = [\"esc\"]\n",
+			check: func(t *testing.T) {
+				want := []KeyBinding{{Ch: 'w'}, {Key: termbox.KeySpace}}
+				if !reflect.DeepEqual(keyMap[InputJump], want) {
+					t.Errorf("jump keys %v, want %v", keyMap[InputJump], want)
+				}
+				if !reflect.DeepEqual(keyMap[InputQuit], []KeyBinding{{Key: termbox.KeyEsc}}) {
+					t.Errorf("quit keys %v", keyMap[InputQuit])
+				}
+				if !reflect.DeepEqual(keyMap[InputPause], DefaultKeyMap()[InputPause]) {
+					t.Errorf("pause keys %v, want the default", keyMap[InputPause])
+				}
+				if TuningFingerprint() != tuning {
+					t.Error("keys changed the tuning fingerprint")
+				}
+			},
+		},
+		{
+			name: "audio",
+			file: "config.toml",
+			data: "[audio]\nvolume = 60\neffects_muted = true\nmilestones_only = true\nmusic = true\n",
+			check: func(t *testing.T) {
+				want := audioConfig{Volume: 60, EffectsVolume: 100, EventsVolume: 100, EffectsMuted: true, MilestonesOnly: true, Music: true, MusicVolume: 60}
+				if audioSettings != want {
+					t.Errorf("audio %+v, want %+v", audioSettings, want)
+				}
+				if TuningFingerprint() != tuning {
+					t.Error("audio changed the tuning fingerprint")
+				}
+			},
+		},
+		{
+			name: "theme",
+			file: "config.toml",
+			data: "[display]\ntheme = \"monochrome\"\nday_night_interval = 0\nrender = \"braille\"\n",
+			check: func(t *testing.T) {
+				if theme.Name != "monochrome" {
+					t.Errorf("theme %q, want monochrome", theme.Name)
+				}
+				if renderSetting != renderBraille {
+					t.Errorf("render mode %q, want braille", renderSetting)
+				}
+				if dayNightInterval != 0 {
+					t.Errorf("day/night interval %d, want 0", dayNightInterval)
+				}
+			},
+		},
+		{
+			name: "unknown setting",
+			file: "config.toml",
+			data: "[physics]\njump_hight = 5\n",
+			errs: []string{`unknown setting "physics.jump_hight"`},
+		},
+		{
+			name: "unknown json field",
+			file: "config.json",
+			data: `{"physics": {"gravity": 3}}`,
+			errs: []string{`unknown field "gravity"`},
+		},
+		{
+			name: "syntax error",
+			file: "config.toml",
+			data: "[physics\n",
+			errs: []string{"config.toml"},
+		},
+		{
+			name: "every problem is reported",
+			file: "config.toml",
+			data: "[physics]\njump_height = 0\nhang_duration = -1\n[clouds]\nmin_count = 3\nmax_count = 1\n",
+			errs: []string{
+				"physics.jump_height must be between 1 and 8, got 0",
+				"physics.hang_duration must not be negative, got -1",
+				"clouds.max_count (1) must not be below clouds.min_count (3)",
+			},
+		},
+		{
+			name: "bad audio",
+			file: "config.json",
+			data: `{"audio": {"volume": 120, "events_volume": -5}}`,
+			errs: []string{
+				"audio.volume must be between 0 and 100, got 120",
+				"audio.events_volume must be between 0 and 100, got -5",
+			},
+		},
+		{
+			name: "bad feedback",
+			file: "config.toml",
+			data: "[feedback]\nmode = \"sometimes\"\njump = \"confetti\"\n",
+			errs: []string{
+				`feedback.mode must be auto, on or off, got "sometimes"`,
+				`feedback.jump must be flash, pulse, dino, bell or none, got "confetti"`,
+			},
+		},
+		{
+			name: "bad theme",
+			file: "config.toml",
+			data: "[display]\ntheme = \"neon\"\nday_night_interval = -1\ncolor = \"16\"\nrender = \"sixel\"\n",
+			errs: []string{
+				`display.theme must be one of default, `,
+				"display.day_night_interval must not be negative, got -1",
+				`display.color must be one of auto, 8, 256, truecolor, got "16"`,
+				`display.render must be one of text, braille, got "sixel"`,
+			},
+		},
+		{
+			name: "bad clouds",
+			file: "config.toml",
+			data: "[clouds]\nmin_height = 4\nmax_height = 2\nmin_speed = 0.0\n",
+			errs: []string{
+				"clouds.max_height (2) must not be below clouds.min_height (4)",
+				"clouds.min_speed must be positive, got 0",
+			},
+		},
+		{
+			name: "bad stages",
+			file: "config.toml",
+			data: "[[stages]]\nscore_threshold = 5\nspeed = 1.0\n",
+			errs: []string{
+				"stages[0]: the first stage must have score_threshold 0, got 5",
+				"stages[0]: single/short/group cactus ratios sum to 0, want 1",
+				"stages[0]: min_gap must be at least 1, got 0",
+			},
+		},
+		{
+			name: "empty stages",
+			file: "config.json",
+			data: `{"stages": []}`,
+			errs: []string{"stages: at least one stage is required"},
+		},
+		{
+			name: "bad keys",
+			file: "config.toml",
+			data: "[keys]\nfly = [\"f\"]\njump = [\"hyper\"]\nduck = [\"p\"]\npause = [\"p\"]\nrestart = []\n",
+			errs: []string{
+				`keys: unknown action "fly"`,
+				`keys.jump: unknown key "hyper"`,
+				`keys.pause: "p" is already bound to duck`,
+				"keys.jump: at least one key is required",
+				"keys.restart: at least one key is required",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keepConfig(t)
+			before := currentConfig()
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := LoadConfig(path)
+			if tt.errs == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				tt.check(t)
+				return
+			}
+			if err == nil {
+				t.Fatalf("no error, want %q", tt.errs)
+			}
+			for _, want := range tt.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+			// 整个文件有效才会生效
+			if after := currentConfig(); !reflect.DeepEqual(after, before) {
+				t.Errorf("an invalid file changed the config to %+v", after)
+			}
+			if !reflect.DeepEqual(keyMap, DefaultKeyMap()) {
+				t.Errorf("an invalid file changed the keys to %v", keyMap)
+			}
+		})
+	}
+}
+
+func TestParseKeyBinding(t *testing.T) {
+	tests := []struct {
+		name string
+		want KeyBinding
+		err  bool
+	}{
+		{name: "w", want: KeyBinding{Ch: 'w'}},
+		{name: "W", want: KeyBinding{Ch: 'W'}},
+		{name: "é", want: KeyBinding{Ch: 'é'}},
+		{name: "space", want: KeyBinding{Key: termbox.KeySpace}},
+		{name: "Up", want: KeyBinding{Key: termbox.KeyArrowUp}},
+		{name: "PgDn", want: KeyBinding{Key: termbox.KeyPgdn}},
+		{name: "F12", want: KeyBinding{Key: termbox.KeyF12}},
+		{name: " ", err: true},
+		{name: "", err: true},
+		{name: "ctrl", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := ParseKeyBinding(tt.name)
+			if tt.err {
+				if err == nil {
+					t.Errorf("got %v, want an error", b)
+				}
+				return
+			}
+			if err != nil || b != tt.want {
+				t.Fatalf("got %v, %v, want %v", b, err, tt.want)
+			}
+			// 名字写回配置文件后还能读出同一个键
+			if again, err := ParseKeyBinding(b.String()); err != nil || again != b {
+				t.Errorf("%q reads back as %v, %v", b.String(), again, err)
+			}
+		})
+	}
+}
+
+func TestParseKeyMap(t *testing.T) {
+	base := DefaultKeyMap()
+	tests := []struct {
+		name string
+		keys map[string][]string
+		want map[InputAction][]KeyBinding // actions to check
+		errs int
+	}{
+		{
+			name: "no section keeps the base",
+			want: map[InputAction][]KeyBinding{InputJump: base[InputJump], InputQuit: base[InputQuit]},
+		},
+		{
+			name: "replace an action",
+			keys: map[string][]string{"jump": {"k"}},
+			want: map[InputAction][]KeyBinding{InputJump: {{Ch: 'k'}}, InputDuck: base[InputDuck]},
+		},
+		{
+			name: "a key moves away from the base action",
+			keys: map[string][]string{"pause": {"q"}},
+			want: map[InputAction][]KeyBinding{InputPause: {{Ch: 'q'}}, InputQuit: {{Key: termbox.KeyEsc}}},
+		},
+		{
+			name: "a key listed twice for one action",
+			keys: map[string][]string{"jump": {"space", "space"}},
+			want: map[InputAction][]KeyBinding{InputJump: {{Key: termbox.KeySpace}}},
+		},
+		{
+			name: "optional actions may be unbound",
+			keys: map[string][]string{"bind": {}},
+			want: map[InputAction][]KeyBinding{InputBind: {}},
+		},
+		{name: "unknown action", keys: map[string][]string{"dance": {"d"}}, errs: 1},
+		{name: "unknown key", keys: map[string][]string{"duck": {"down", "meta"}}, errs: 1},
+		{name: "a key for two actions", keys: map[string][]string{"jump": {"x"}, "duck": {"x"}}, errs: 1},
+		{name: "required action unbound", keys: map[string][]string{"jump": {}, "restart": {}}, errs: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, errs := parseKeyMap(tt.keys, base)
+			if len(errs) != tt.errs {
+				t.Fatalf("errors %v, want %d", errs, tt.errs)
+			}
+			for a, want := range tt.want {
+				if !reflect.DeepEqual(m[a], want) {
+					t.Errorf("%s keys %v, want %v", a, m[a], want)
+				}
+			}
+			if !reflect.DeepEqual(base, DefaultKeyMap()) {
+				t.Error("the base key map was changed")
+			}
+		})
+	}
+}
+
+// TestSaveKeyMap checks that saved key bindings load again and leave the
+// other settings in the file alone
+func TestSaveKeyMap(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string // the file before saving, "" for none
+		keep string // a setting that must survive
+	}{
+		{"new toml file", "config.toml", "", ""},
+		{"toml with settings", "config.toml", "# my settings\n[physics]\njump_height = 6\n\n[keys]\njump = [\"w\"]\n", "jump_height = 6"},
+		{"json with settings", "config.json", `{"physics": {"jump_height": 6}, "keys": {"jump": ["w"]}}`, `"jump_height": 6`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keepConfig(t)
+			path := filepath.Join(t.TempDir(), tt.file)
+			if tt.data != "" {
+				if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			keyMap = DefaultKeyMap()
+			keyMap.Bind(InputJump, KeyBinding{Ch: 'k'})
+			saved := keyMap
+			if err := SaveKeyMap(path); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), tt.keep) {
+				t.Errorf("%q was dropped from\n%s", tt.keep, data)
+			}
+			keyMap = DefaultKeyMap()
+			if err := LoadConfig(path); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(keyMap, saved) {
+				t.Errorf("loaded keys %v, saved %v", keyMap, saved)
+			}
+		})
+	}
+}
@@ -40,43 +40,52 @@ func (d *Dino) Update() {
 
 	// 如果恐龙在地面上且下键被按住，持续刷新蹲下状态
 	// 注意：这里不需要检查d.isFastDropping，因为落地时已经处理了
-	if d.posY == float64(height-2) && d.isDownKeyPressed {
+	if d.OnGround() && d.isDownKeyPressed {
 		d.Duck()
 	}
 }
 
-// Jump initiates an upward velocity if on the ground
-func (d *Dino) Jump() {
-	if d.posY == float64(height-2) {
-		d.velY = jumpVelocity
-		d.hangFrames = 0
-		d.isFastDropping = false
-
-		// 播放跳跃音效
-		GetAudioManager().PlaySound(SoundJump)
+// Jump initiates an upward velocity if on the ground.
+// It returns true if the dino actually took off.
+func (d *Dino) Jump() bool {
+	if d.posY != float64(height-2) {
+		return false
 	}
+	d.velY = jumpVelocity
+	d.hangFrames = 0
+	d.isFastDropping = false
+	return true
 }
 
-// FastDrop initiates a fast downward velocity if in the air
-func (d *Dino) FastDrop() {
+// FastDrop initiates a fast downward velocity if in the air.
+// It returns true if the dino started dropping.
+func (d *Dino) FastDrop() bool {
 	// 只有在空中才能快速下降
-	if d.posY < float64(height-2) {
-		// 设置一个较大的向下速度，比重力加速度更快
-		d.velY = -jumpVelocity * 0.8 // 使用跳跃速度的80%作为下降速度
-		d.hangFrames = 0             // 取消任何悬停时间
-		d.isFastDropping = true
-		d.isDownKeyPressed = true // 确保下键状态被设置为按住
-
-		// 播放快速下降音效
-		GetAudioManager().PlaySound(SoundDrop) // 使用专门的下降音效
+	if d.posY >= float64(height-2) {
+		return false
 	}
+	// 设置一个较大的向下速度，比重力加速度更快
+	d.velY = -jumpVelocity * 0.8 // 使用跳跃速度的80%作为下降速度
+	d.hangFrames = 0             // 取消任何悬停时间
+	d.isFastDropping = true
+	d.isDownKeyPressed = true // 确保下键状态被设置为按住
+	return true
+}
+
+// OnGround returns true if the dino is standing on the ground row
+func (d *Dino) OnGround() bool {
+	return int(d.posY) == height-2
 }
 
 // Draw renders the dino sprite at its current position with animation
-func (d *Dino) Draw() {
-	var sprite Sprite
-	onGround := int(d.posY) == height-2
-	if !onGround {
+func (d *Dino) Draw(r Renderer) {
+	d.drawColored(r, palette.Dino)
+}
+
+// drawColored renders the dino in the given colour
+func (d *Dino) drawColored(r Renderer, fg termbox.Attribute) {
+	var sprite ColorSprite
+	if !d.OnGround() {
 		// 如果在快速下降，可以使用不同的精灵图（可选）
 		if d.isFastDropping {
 			sprite = dinoDuckFrames[0] // 使用蹲下的精灵图表示快速下降
@@ -84,14 +93,14 @@ func (d *Dino) Draw() {
 			sprite = dinoStandFrames[0]
 		}
 	} else if d.duckFrames > 0 {
-		sprite = dinoDuckFrames[d.animFrame]
+		sprite = d.frame(dinoDuckFrames)
 	} else {
-		sprite = dinoStandFrames[d.animFrame]
+		sprite = d.frame(dinoStandFrames)
 	}
-	h := len(sprite)
+	h := len(sprite.Glyphs)
 	y := int(d.posY)
 	startY := y - (h - 1)
-	sprite.Draw(d.X, startY, termbox.ColorGreen, termbox.ColorDefault)
+	drawSprite(r, sprite, d.X, startY, float64(d.X), d.posY-float64(h-1), fg, palette.Background)
 }
 
 // updateAnimation advances animation frames
@@ -99,10 +108,16 @@ func (d *Dino) updateAnimation() {
 	d.animCounter++
 	if d.animCounter >= animPeriod {
 		d.animCounter = 0
-		d.animFrame = (d.animFrame + 1) % 2
+		// 站立和蹲下的帧数可以不同（皮肤），一起循环
+		d.animFrame = (d.animFrame + 1) % (len(dinoStandFrames) * len(dinoDuckFrames))
 	}
 }
 
+// frame returns the current animation frame of a set of dino frames
+func (d *Dino) frame(frames []ColorSprite) ColorSprite {
+	return frames[d.animFrame%len(frames)]
+}
+
 // shouldUpdate returns true if the dino is airborne or hanging
 func (d *Dino) shouldUpdate() bool {
 	return d.posY < float64(height-2) || d.velY != 0 || d.hangFrames > 0
@@ -0,0 +1,164 @@
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// 无障碍反馈：听不到音效时，把每个音效事件变成短暂的画面效果或一次受控的终端铃声
+
+// Feedback effects a sound event can be mapped to in the [feedback] section
+const (
+	effectNone  = "none"
+	effectFlash = "flash" // invert the screen for a moment
+	effectPulse = "pulse" // pulse the score in colour
+	effectDino  = "dino"  // flash the dino
+	effectBell  = "bell"  // ring the terminal bell once, between two frames
+)
+
+// Feedback modes
+const (
+	feedbackAuto = "auto" // show the cue of every sound that can't be heard
+	feedbackOn   = "on"
+	feedbackOff  = "off"
+)
+
+// How long the visual effects last
+const (
+	flashDuration = 120 * time.Millisecond
+	pulseDuration = time.Second
+	pulseInterval = 150 * time.Millisecond
+	dinoDuration  = 600 * time.Millisecond
+	dinoInterval  = 100 * time.Millisecond
+)
+
+// validEffect reports whether name is one of the effect* constants
+func validEffect(name string) bool {
+	switch name {
+	case effectNone, effectFlash, effectPulse, effectDino, effectBell:
+		return true
+	}
+	return false
+}
+
+// effect returns the effect configured for a sound
+func (f feedbackConfig) effect(name string) string {
+	switch name {
+	case SoundJump:
+		return f.Jump
+	case SoundDrop:
+		return f.Drop
+	case SoundScore:
+		return f.Score
+	case SoundCollision:
+		return f.Collision
+	}
+	return effectNone
+}
+
+// active reports whether the cue of a sound should be shown
+func (f feedbackConfig) active(name string) bool {
+	switch f.Mode {
+	case feedbackOn:
+		return true
+	case feedbackOff:
+		return false
+	}
+	return !GetAudioManager().Audible(name)
+}
+
+// feedback keeps track of the visual effects that are showing
+type feedback struct {
+	flashUntil time.Time
+	pulseStart time.Time
+	dinoStart  time.Time
+}
+
+// trigger starts an effect
+func (fb *feedback) trigger(effect string, now time.Time) {
+	switch effect {
+	case effectFlash:
+		fb.flashUntil = now.Add(flashDuration)
+	case effectPulse:
+		fb.pulseStart = now
+	case effectDino:
+		fb.dinoStart = now
+	case effectBell:
+		ringBell()
+	}
+}
+
+// flashing reports whether the screen is inverted
+func (fb *feedback) flashing(now time.Time) bool {
+	return now.Before(fb.flashUntil)
+}
+
+// pulseColor returns the colour of the score while it pulses
+func (fb *feedback) pulseColor(now time.Time) (termbox.Attribute, bool) {
+	elapsed := now.Sub(fb.pulseStart)
+	if fb.pulseStart.IsZero() || elapsed >= pulseDuration {
+		return 0, false
+	}
+	if (elapsed/pulseInterval)%2 == 0 {
+		return palette.Pulse[0], true
+	}
+	return palette.Pulse[1], true
+}
+
+// dinoColor returns the colour of the dino while it flashes. The first
+// phase is "on", so the dino stays highlighted on the game over screen.
+func (fb *feedback) dinoColor(now time.Time) (termbox.Attribute, bool) {
+	elapsed := now.Sub(fb.dinoStart)
+	if fb.dinoStart.IsZero() || elapsed >= dinoDuration || (elapsed/dinoInterval)%2 == 1 {
+		return 0, false
+	}
+	return palette.Alert, true
+}
+
+// bellPending is set when the bell should ring after the next frame
+var bellPending atomic.Bool
+
+// ringBell rings the terminal bell once the current frame is on screen.
+// Several requests before that ring it only once.
+func ringBell() {
+	bellPending.Store(true)
+}
+
+// feedbackRenderer wraps a Renderer to invert the screen during a flash
+// and to ring the bell between frames, so the bell never lands in the
+// middle of termbox output
+type feedbackRenderer struct {
+	Renderer
+	reverse bool
+}
+
+// Clear clears the screen, filling it in reverse video during a flash
+func (f *feedbackRenderer) Clear() {
+	f.Renderer.Clear()
+	if !f.reverse {
+		return
+	}
+	for y := 0; y <= height; y++ {
+		for x := 0; x < width; x++ {
+			f.Renderer.SetCell(x, y, ' ', palette.Text|termbox.AttrReverse, palette.Background)
+		}
+	}
+}
+
+// SetCell sets a cell, in reverse video during a flash
+func (f *feedbackRenderer) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	if f.reverse {
+		fg |= termbox.AttrReverse
+	}
+	f.Renderer.SetCell(x, y, ch, fg, bg)
+}
+
+// Flush shows the frame, then rings the bell if it was requested
+func (f *feedbackRenderer) Flush() {
+	f.Renderer.Flush()
+	if bellPending.Swap(false) {
+		os.Stdout.WriteString("\a")
+	}
+}
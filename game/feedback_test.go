@@ -0,0 +1,103 @@
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+	"testing"
+	"time"
+)
+
+// useAudio replaces the audio manager until the test ends
+func useAudio(t *testing.T, am *AudioManager) {
+	saved, settings := audioManager, audioSettings
+	t.Cleanup(func() { audioManager, audioSettings = saved, settings })
+	audioManager = am
+}
+
+func TestFeedbackActive(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		enabled bool
+		backend AudioBackend
+		sound   string
+		want    bool
+	}{
+		{"on", feedbackOn, true, &mixerBackend{}, SoundJump, true},
+		{"off", feedbackOff, true, nullBackend{}, SoundJump, false},
+		{"auto, heard", feedbackAuto, true, &mixerBackend{}, SoundJump, false},
+		{"auto, sound off", feedbackAuto, false, &mixerBackend{}, SoundJump, true},
+		{"auto, silent backend", feedbackAuto, true, nullBackend{}, SoundCollision, true},
+		{"auto, bell rings for collisions", feedbackAuto, true, bellBackend{}, SoundCollision, false},
+		{"auto, bell stays quiet for jumps", feedbackAuto, true, bellBackend{}, SoundJump, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useAudio(t, &AudioManager{enabled: tt.enabled, backend: tt.backend})
+			f := feedbackSettings
+			f.Mode = tt.mode
+			if got := f.active(tt.sound); got != tt.want {
+				t.Errorf("active(%q) = %v, want %v", tt.sound, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("auto, muted", func(t *testing.T) {
+		useAudio(t, &AudioManager{enabled: true, backend: &mixerBackend{}})
+		audioSettings.EffectsMuted = true
+		if !feedbackSettings.active(SoundJump) {
+			t.Error("no cue for a muted jump")
+		}
+		if feedbackSettings.active(SoundScore) {
+			t.Error("cue for a score milestone that can be heard")
+		}
+	})
+}
+
+func TestFeedbackEffects(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		effect string
+		after  time.Duration
+		flash  bool
+		pulse  termbox.Attribute // 0 when the score does not pulse
+		dino   bool
+	}{
+		{"nothing", effectNone, 0, false, 0, false},
+		{"flash", effectFlash, 0, true, 0, false},
+		{"flash is over", effectFlash, flashDuration, false, 0, false},
+		{"pulse", effectPulse, 0, false, termbox.ColorYellow | termbox.AttrBold, false},
+		{"pulse, second phase", effectPulse, pulseInterval, false, termbox.ColorRed | termbox.AttrBold, false},
+		{"pulse is over", effectPulse, pulseDuration, false, 0, false},
+		{"dino", effectDino, 0, false, 0, true},
+		{"dino, off phase", effectDino, dinoInterval, false, 0, false},
+		{"dino, on again", effectDino, 2 * dinoInterval, false, 0, true},
+		{"dino is over", effectDino, dinoDuration, false, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fb feedback
+			fb.trigger(tt.effect, start)
+			now := start.Add(tt.after)
+			if got := fb.flashing(now); got != tt.flash {
+				t.Errorf("flashing %v, want %v", got, tt.flash)
+			}
+			if c, ok := fb.pulseColor(now); c != tt.pulse || ok != (tt.pulse != 0) {
+				t.Errorf("pulse colour %v, %v, want %v", c, ok, tt.pulse)
+			}
+			if _, ok := fb.dinoColor(now); ok != tt.dino {
+				t.Errorf("dino flashing %v, want %v", ok, tt.dino)
+			}
+		})
+	}
+
+	t.Run("bell", func(t *testing.T) {
+		bellPending.Store(false)
+		var fb feedback
+		fb.trigger(effectBell, start)
+		fb.trigger(effectBell, start)
+		if !bellPending.Swap(false) {
+			t.Error("the bell was not requested")
+		}
+	})
+}
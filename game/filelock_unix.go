@@ -0,0 +1,31 @@
+//go:build !windows
+
+package game
+
+import (
+	"os"
+	"syscall"
+)
+
+// acquireLock holds an exclusive flock on lockPath until released. The
+// lock is dropped by the kernel if the process dies.
+func acquireLock(lockPath string) (func(), error) {
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,37 @@
+//go:build windows
+
+package game
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// lockStaleAfter is how old a lock file must be before it is considered
+// left behind by a crashed process
+const lockStaleAfter = 10 * time.Second
+
+// acquireLock creates lockPath exclusively, retrying while another process
+// holds it. Lock files older than lockStaleAfter are removed.
+func acquireLock(lockPath string) (func(), error) {
+	deadline := time.Now().Add(2 * lockStaleAfter)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > lockStaleAfter {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", lockPath)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
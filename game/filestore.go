@@ -0,0 +1,47 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic replaces path with data so that readers see either the
+// old or the new contents, never a partly written file: the data goes to a
+// temporary file in the same directory which is synced and renamed over path
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// 出错时删除临时文件；重命名成功后删除会失败，无影响
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// lockFile takes an advisory lock on path+".lock", waiting for other
+// processes holding it. The returned function releases the lock.
+func lockFile(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return acquireLock(path + ".lock")
+}
@@ -3,6 +3,7 @@ package game
 import (
 	"fmt"
 	"github.com/nsf/termbox-go"
+	"math"
 	"strings"
 	"time"
 )
@@ -10,305 +11,436 @@ import (
 // SetWidth updates game width based on terminal size
 func SetWidth(w int) {
 	width = w
-	// Reinitialize ground decorations when width changes
-	InitGroundDecorations()
 }
 
-// Game holds all state
+// SetHeight sets the play-field height (clamped to defaultHeight..maxHeight)
+// and derives the sky rows, bird flight rows and jump physics from it.
+// It must be called before the Simulation is created.
+func SetHeight(h int) {
+	if h < defaultHeight {
+		h = defaultHeight
+	}
+	if h > maxHeight {
+		h = maxHeight
+	}
+	height = h
+	scale := float64(h) / float64(defaultHeight)
+	ground := h - 2
+
+	// 跳得更高但滞空时间不变，水平方向的难度保持一致
+	jumpHeight = int(math.Round(float64(baseJumpHeight) * scale))
+	jumpVelocity = -2 * float64(jumpHeight) / float64(jumpDuration)
+	gravity = -jumpVelocity / float64(jumpDuration)
+
+	// 小鸟的高度取决于恐龙精灵的大小，与地面保持固定距离；大鸟随跳跃高度缩放
+	birdFlightRows = []int{ground - 1, ground - 4}
+	bigBirdFlightRow = ground - int(math.Round(baseBigBirdClearance*scale))
+
+	cloudMaxHeight = int(math.Round(float64(baseCloudMaxHeight) * scale))
+}
+
+// FitHeight returns the largest play-field height that fits a terminal
+// termHeight rows tall
+func FitHeight(termHeight int) int {
+	// 地面装饰画在第 height 行，所以需要 height+1 行
+	h := termHeight - 1
+	if h < defaultHeight {
+		return defaultHeight
+	}
+	if h > maxHeight {
+		return maxHeight
+	}
+	return h
+}
+
+// Options holds the command line settings for a game session
+type Options struct {
+	Seed       int64   // seed for all randomness; the same seed reproduces the same world
+	FPS        int     // render frame rate; 0 means defaultFPS. Gameplay speed does not depend on it
+	Height     int     // play-field height in rows; 0 means defaultHeight, -1 fits the terminal
+	RecordPath string  // if set, every action is recorded to this replay file
+	Replay     *Replay // if set, the recorded run is played back instead of reading the keyboard
+	ConfigPath string  // config file the key binding screen saves to
+	// LegacyKeyboard skips the kitty keyboard protocol and always guesses
+	// key releases from auto-repeat
+	LegacyKeyboard bool
+	// Audio names the audio backend to use; "" or "auto" picks the best one
+	Audio string
+	// Theme names the colour theme; "" keeps the one from the config file
+	Theme string
+	// Color is the colour mode (see ColorModes); "" keeps the one from the
+	// config file
+	Color string
+	// Render is the render mode (see RenderModes); "" keeps the one from
+	// the config file
+	Render string
+}
+
+// Game is the termbox frontend: it turns keyboard events into actions,
+// steps the Simulation and draws it through a Renderer
 type Game struct {
-	dino                     *Dino
-	obstacleManager          *ObstacleManager
-	downKeyHeld              bool // 添加一个字段来跟踪下键状态
-	cloudManager             *CloudManager
-	ticker                   *time.Ticker
-	events                   chan termbox.Event
-	score                    int
-	highestScore             int
-	groundStart              int
-	groundEnd                int
-	started                  bool
-	pause                    bool
-	groundExtending          bool
-	collided                 bool // indicates collision occurred
-	stageIndexActive         int
-	stageIndexTarget         int
-	stageTransitionStart     time.Time
-	scoreBlinking            bool      // 标记分数是否正在闪烁
-	scoreBlinkStart          time.Time // 分数开始闪烁的时间
-	scoreBlinkVisible        bool      // 控制分数闪烁的显示/隐藏状态
-	lastBlinkToggle          time.Time // 上次闪烁状态切换的时间
-	groundSpecialCharCounter int       // 用于控制特殊地面字符的添加频率
-	frameCounter             int       // 用于控制积分累计速度的帧计数器
+	sim         *Simulation
+	renderer    Renderer
+	effects     *feedbackRenderer // the renderer that shows the visual cues, inside renderer
+	ticker      *time.Ticker
+	keyboard    *keyboard
+	pending     []Action // actions queued for the next tick
+	leaderboard *Leaderboard
+	recorder    *Recorder     // records the session when --record is used
+	recordErr   error         // why the recording could not be saved at the last game over
+	player      *replayPlayer // plays back a recorded session when --replay is used
+	termWidth   int           // current terminal size
+	termHeight  int
+	bindScreen  *bindScreen  // key binding screen, nil when closed
+	audioScreen *audioScreen // audio settings screen, nil when closed
+	feedback    feedback     // visual cues for sounds that can't be heard
+	boardOpen   bool         // the leaderboard view is shown
+	configPath  string
 }
 
 // NewGame initializes and returns a new Game
-func NewGame() *Game {
-	events := make(chan termbox.Event)
-	go func() {
-		for {
-			events <- termbox.PollEvent()
-		}
-	}()
-	// initialize player
-	d := NewDino()
-	// calculate initial ground boundaries
-	half := initialGroundLength / 2
-	gs := d.X - half
-	if gs < 0 {
-		gs = 0
-	}
-	ge := d.X + half
-	if ge > width-1 {
-		ge = width - 1
+func NewGame(opts Options) *Game {
+	fps := opts.FPS
+	if fps <= 0 {
+		fps = defaultFPS
 	}
 
-	// Initialize ground decorations
-	InitGroundDecorations()
+	if opts.Theme != "" {
+		SetTheme(opts.Theme)
+	}
+	if opts.Color != "" {
+		colorSetting = opts.Color
+	}
+	initColors(colorSetting)
+	if opts.Render != "" {
+		renderSetting = opts.Render
+	}
 
 	// Initialize audio manager
 	audioManager := GetAudioManager()
+	audioManager.SetPreferredBackend(opts.Audio)
 	audioManager.Initialize()
 
-	// 加载历史最高分
-	highScore, err := LoadHighScore()
-	if err != nil {
-		// 如果加载失败，使用默认值0
-		//fmt.Println("cant load highest score:", err)
-		highScore = 0
-	}
-
-	return &Game{
-		dino:                     d,
-		obstacleManager:          NewObstacleManager(),
-		cloudManager:             NewCloudManager(),
-		ticker:                   time.NewTicker(tickDuration),
-		events:                   events,
-		score:                    0,
-		highestScore:             highScore,
-		groundStart:              gs,
-		groundEnd:                ge,
-		started:                  false,
-		pause:                    false,
-		groundExtending:          false,
-		downKeyHeld:              false,
-		stageIndexActive:         0,
-		stageIndexTarget:         0,
-		stageTransitionStart:     time.Time{},
-		scoreBlinking:            false,
-		scoreBlinkStart:          time.Time{},
-		scoreBlinkVisible:        true,
-		lastBlinkToggle:          time.Time{},
-		groundSpecialCharCounter: 0,
-		frameCounter:             0,
+	// 加载排行榜（失败时使用空排行榜）
+	leaderboard, _ := LoadLeaderboard()
+
+	g := &Game{
+		effects:     &feedbackRenderer{Renderer: TermboxRenderer{}},
+		ticker:      time.NewTicker(time.Second / time.Duration(fps)),
+		keyboard:    newKeyboard(!opts.LegacyKeyboard && opts.Replay == nil),
+		leaderboard: leaderboard,
+		configPath:  opts.ConfigPath,
+	}
+	g.renderer = g.effects
+	if renderSetting == renderBraille {
+		// 点阵在最外层，闪屏的反色也作用在盲文字符上
+		g.renderer = NewBrailleRenderer(g.effects)
 	}
+	// 游戏宽度跟随终端宽度；回放时使用录像中的宽度
+	g.termWidth, g.termHeight = termbox.Size()
+	if opts.Replay != nil {
+		opts.Seed = opts.Replay.Seed
+		SetWidth(opts.Replay.Width)
+		SetHeight(opts.Replay.Height)
+		g.player = &replayPlayer{replay: opts.Replay}
+	} else {
+		SetWidth(playWidth(g.termWidth))
+		switch {
+		case opts.Height < 0:
+			SetHeight(FitHeight(g.termHeight))
+		case opts.Height > 0:
+			SetHeight(opts.Height)
+		}
+		if opts.RecordPath != "" {
+			g.recorder = NewRecorder(opts.RecordPath, opts.Seed, width, height)
+		}
+	}
+	g.sim = NewSimulation(opts.Seed)
+	return g
 }
 
-// drawStartScreen renders the initial start prompt and partial ground
-func (g *Game) drawStartScreen() {
-	// Draw clouds first (always across the entire sky)
-	g.cloudManager.Draw()
-
-	// Draw partial ground
-	g.drawGroundPartial()
+// playWidth returns the play-field width for a terminal termWidth cells wide
+func playWidth(termWidth int) int {
+	if termWidth < minWidth {
+		return minWidth
+	}
+	return termWidth
+}
 
-	// Draw the dinosaur at its starting position
-	g.dino.Draw()
+// resize reacts to a terminal size change. In a live game the play field
+// follows the terminal width (and the change is recorded); a replay keeps
+// the recorded width. A running game is paused when the terminal becomes
+// too small to show it.
+func (g *Game) resize(w, h int) {
+	g.termWidth, g.termHeight = w, h
+	if g.player == nil {
+		if pw := playWidth(w); pw != width {
+			if g.recorder != nil {
+				g.recorder.RecordResize(g.sim.tick, pw)
+			}
+			g.sim.Resize(pw)
+		}
+		s := g.sim
+		if g.tooSmall() && s.started && !s.pause && !s.collided && !containsAction(g.pending, ActionPause) {
+			g.queue(ActionPause)
+		}
+	}
+	// termbox 需要在尺寸变化后清屏，否则会残留旧画面
+	TermboxRenderer{}.Clear()
+}
 
-	PrintCenter("Press Space or Up Arrow to Start")
+// tooSmall reports whether the terminal cannot show the whole play field
+func (g *Game) tooSmall() bool {
+	return g.termWidth < width || g.termHeight < height+1
+}
 
-	// 显示音效控制提示
-	soundMsg := "Press 'm' to toggle sound"
-	if !GetAudioManager().IsEnabled() {
-		soundMsg = "Sound OFF - Press 'm' to enable"
+// drawTooSmall replaces the scene with a notice while the terminal is too small
+func (g *Game) drawTooSmall() {
+	r := g.renderer
+	r.Clear()
+	lines := []string{
+		"Terminal too small",
+		fmt.Sprintf("need %dx%d, have %dx%d", width, height+1, g.termWidth, g.termHeight),
+	}
+	for i, msg := range lines {
+		x := (g.termWidth - len(msg)) / 2
+		if x < 0 {
+			x = 0
+		}
+		PrintAt(r, x, g.termHeight/2-1+i, msg)
 	}
-	PrintCenterAt(soundMsg, height/2+2)
+	r.Flush()
 }
 
-// drawGameScene renders the full game scene after start
-func (g *Game) drawGameScene() {
-	// Draw clouds first (always across the entire sky)
-	g.cloudManager.Draw()
-
-	// ground
-	if g.groundExtending {
-		g.drawGroundPartial()
-	} else {
-		DrawGround()
+// drawStartScreen renders the initial start prompt
+func (g *Game) drawStartScreen() {
+	if g.player != nil {
+		PrintCenter(g.renderer, fmt.Sprintf("Replaying seed %d", g.sim.Seed()))
+		return
 	}
+	PrintCenter(g.renderer, fmt.Sprintf("Press %s to Start", keyMap.Describe(InputJump, " or ")))
 
-	// dino
-	g.dino.Draw()
+	// 显示音效控制提示
+	PrintCenterAt(g.renderer, soundHint(), height/2+2)
+	PrintCenterAt(g.renderer, fmt.Sprintf("Press %s to change keys, %s for audio", keyMap.Hint(InputBind), keyMap.Hint(InputAudio)), height/2+3)
+	PrintCenterAt(g.renderer, fmt.Sprintf("Press %s for the leaderboard", keyMap.Hint(InputLeaderboard)), height/2+4)
 
-	// obstacle
-	g.obstacleManager.Draw()
+	// 地面下方一行显示音频后端和遇到的问题
+	PrintCenterAt(g.renderer, clipText(GetAudioManager().Status(), width), height)
+}
+
+// clipText shortens s to at most n columns, marking the cut with "..."
+func clipText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+// soundHint returns the sound toggle hint for the current sound state
+func soundHint() string {
+	key := keyMap.Hint(InputToggleSound)
+	am := GetAudioManager()
+	if !am.Available() {
+		return "Sound OFF - no audio output"
+	}
+	if !am.IsEnabled() {
+		return fmt.Sprintf("Sound OFF - Press %s to enable", key)
+	}
+	return fmt.Sprintf("Press %s to toggle sound", key)
 }
 
 // draw renders the current game state
 func (g *Game) draw() {
-	ClearScreen()
-
-	// 处理分数闪烁逻辑
-	if g.scoreBlinking {
-		elapsed := time.Since(g.scoreBlinkStart)
-
-		// 检查是否需要结束闪烁
-		if elapsed >= ScoreBlinkDuration {
-			g.scoreBlinking = false
-			g.scoreBlinkVisible = true
-		} else {
-			// 检查是否需要切换闪烁状态
-			if time.Since(g.lastBlinkToggle) >= ScoreBlinkInterval {
-				g.scoreBlinkVisible = !g.scoreBlinkVisible
-				g.lastBlinkToggle = time.Now()
-			}
-		}
+	r := g.renderer
+	s := g.sim
+	setPalette(s.darkness())
+	if g.tooSmall() {
+		g.drawTooSmall()
+		return
+	}
+	now := time.Now()
+	g.effects.reverse = g.feedback.flashing(now) && g.bindScreen == nil && !g.boardOpen && g.audioScreen == nil
+	r.Clear()
+	if g.bindScreen != nil {
+		g.drawBindScreen()
+		r.Flush()
+		return
+	}
+	if g.boardOpen {
+		g.drawLeaderboard()
+		r.Flush()
+		return
+	}
+	if g.audioScreen != nil {
+		g.drawAudioScreen()
+		r.Flush()
+		return
 	}
 
 	// score and quit hint
-	if g.scoreBlinking && !g.scoreBlinkVisible {
+	quitHint := keyMap.Hint(InputQuit)
+	if s.scoreBlinking && !s.scoreBlinkVisible {
 		// 闪烁状态下，用空格替换分数的每一位，保持原有位数
-		scoreStr := fmt.Sprintf("%d", g.score)
+		scoreStr := fmt.Sprintf("%d", s.score)
 		blankScore := strings.Repeat(" ", len(scoreStr))
-		PrintAt(0, 0, fmt.Sprintf("Score: %s  (Q to quit)", blankScore))
+		PrintAt(r, 0, 0, fmt.Sprintf("Score: %s  (%s to quit)", blankScore, quitHint))
 	} else {
-		PrintAt(0, 0, fmt.Sprintf("Score: %d  (Q to quit)", g.score))
+		PrintAt(r, 0, 0, fmt.Sprintf("Score: %d  (%s to quit)", s.score, quitHint))
+		if fg, ok := g.feedback.pulseColor(now); ok {
+			for i, ch := range fmt.Sprintf("Score: %d", s.score) {
+				r.SetCell(i, 0, ch, fg, palette.Background)
+			}
+		}
 	}
 
 	// 始终显示最高分，即使是0
-	hsText := fmt.Sprintf("High: %d", g.highestScore)
+	hsText := fmt.Sprintf("High: %d", g.leaderboard.Best())
 	x := width - len(hsText)
-	PrintAt(x, 0, hsText)
-
-	if !g.started {
-		g.drawStartScreen()
-		termbox.Flush()
-		return
-	}
+	PrintAt(r, x, 0, hsText)
 
 	// main game view
-	g.drawGameScene()
-
-	// Show pause indicator if game is paused
-	if g.pause && !g.collided {
-		PrintCenter("PAUSED")
-		PrintCenterAt("Press 'P' to resume", height/2+2)
+	s.Draw(r)
+	if fg, ok := g.feedback.dinoColor(now); ok {
+		s.dino.drawColored(r, fg)
 	}
 
-	termbox.Flush()
-}
-
-// Reset resets the game state for a new game
-func (g *Game) Reset() {
-	// 重置恐龙
-	g.dino = NewDino()
-
-	// 重置障碍物管理器
-	g.obstacleManager = NewObstacleManager()
-
-	// 重置云朵管理器
-	g.cloudManager = NewCloudManager()
-
-	// 重置分数
-	g.score = 0
-
-	// 重置游戏状态
-	g.started = true
-	g.groundExtending = true
-	g.collided = false
-	g.downKeyHeld = false
-
-	// 重置阶段
-	g.stageIndexActive = 0
-	g.stageIndexTarget = 0
-	g.stageTransitionStart = time.Time{}
-
-	// 重置分数闪烁状态
-	g.scoreBlinking = false
-	g.scoreBlinkStart = time.Time{}
-	g.scoreBlinkVisible = true
-	g.lastBlinkToggle = time.Time{}
-	g.frameCounter = 0
-}
-
-// TogglePause toggles the game's paused state
-func (g *Game) TogglePause() {
-	g.pause = !g.pause
-}
-
-// Run starts the game loop
-func (g *Game) Run() {
-	// 初始化音频系统
-	audioManager := GetAudioManager()
-	// 如果音频初始化失败，记录警告但继续游戏
-	if !audioManager.IsEnabled() {
-		//fmt.Println("Warning: Audio system initialization failed. Game will run without sound.")
+	if !s.started {
+		g.drawStartScreen()
+	} else if g.player != nil {
+		PrintAt(r, 0, 1, "REPLAY")
+	}
+	if s.started && s.pause && !s.collided {
+		// Show pause indicator if game is paused
+		PrintCenter(r, "PAUSED")
+		PrintCenterAt(r, fmt.Sprintf("Press %s to resume", keyMap.Hint(InputPause)), height/2+2)
+		if g.player == nil {
+			PrintCenterAt(r, fmt.Sprintf("Press %s to change keys, %s for audio", keyMap.Hint(InputBind), keyMap.Hint(InputAudio)), height/2+3)
+		}
 	}
 
-	lastScoreMilestone := 0
+	r.Flush()
+}
 
+// Run starts the game loop. The simulation advances in fixed tickDuration
+// steps driven by an accumulator, while rendering happens once per ticker
+// frame, so a slow terminal drops frames instead of slowing the game down.
+// It returns when the player quits, reporting any error from saving the
+// recording.
+func (g *Game) Run() error {
 	// 用于跟踪下键状态的变量
 	lastKeyPressTime := time.Now()
 	keyCheckInterval := 100 * time.Millisecond
 
+	last := time.Now()
+	var accumulator time.Duration
+
 	for range g.ticker.C {
-		// 定期检查是否有按键事件
-		// 如果一段时间内没有收到下键的按键事件，则认为下键已释放
-		if g.downKeyHeld && time.Since(lastKeyPressTime) > keyCheckInterval {
-			// 检查是否有新的按键事件
+		now := time.Now()
+		accumulator += now.Sub(last)
+		last = now
+		if accumulator > maxFrameLag {
+			accumulator = maxFrameLag
+		}
+
+		// 处理所有待处理的按键事件
+		for drained := false; !drained; {
 			select {
-			case ev := <-g.events:
-				if ev.Type == termbox.EventKey && ev.Key == KeyDuck {
-					// 如果是下键，更新最后按键时间
-					lastKeyPressTime = time.Now()
-				} else {
-					// 如果是其他键或非按键事件，处理它
-					if !g.handleEvent(ev) {
-						return
+			case ev := <-g.keyboard.events:
+				if ev.Release {
+					// 终端报告了真实的按键释放
+					if keyMap.Matches(InputDuck, ev.Event) && g.player == nil && g.sim.downKeyHeld {
+						g.queue(ActionDuckRelease)
 					}
+					continue
 				}
-			default:
-				// 如果没有新的按键事件，认为下键已释放
-				g.downKeyHeld = false
-				g.dino.isDownKeyPressed = false
-			}
-		} else {
-			// 正常处理按键事件
-			select {
-			case ev := <-g.events:
-				if ev.Type == termbox.EventKey && ev.Key == KeyDuck {
+				if keyMap.Matches(InputDuck, ev.Event) {
 					// 如果是下键，更新最后按键时间
 					lastKeyPressTime = time.Now()
 				}
-				if !g.handleEvent(ev) {
-					return
+				if !g.handleEvent(ev.Event) {
+					return g.quit()
 				}
 			default:
+				drained = true
 			}
 		}
-		g.update()
-		if g.collided {
-			g.draw()
-			g.gameOver()
-			// clear collision flag and restart loop
-			g.collided = false
-			continue
+
+		// 终端不报告按键释放时，如果一段时间内没有收到下键的按键事件，则认为下键已释放
+		if g.player == nil && g.sim.downKeyHeld && !g.keyboard.reportsReleases() &&
+			time.Since(lastKeyPressTime) > keyCheckInterval {
+			g.queue(ActionDuckRelease)
 		}
-		if g.started && !g.pause {
-			// 只有在分数不闪烁时才增加分数
-			if !g.scoreBlinking {
-				// 使用帧计数器来减慢积分累计速度
-				if g.frameCounter%2 == 0 {
-					g.score++
-				}
-				g.frameCounter++
 
-				// 每得到100分播放一次得分音效
-				if g.score/ScoreMilestone > lastScoreMilestone {
-					lastScoreMilestone = g.score / ScoreMilestone
-					GetAudioManager().PlaySound(SoundScore)
-				}
-			}
+		// 终端太小时冻结模拟（实时游戏同时会被暂停）
+		if g.tooSmall() {
+			accumulator = 0
+		}
+
+		// 以固定步长推进模拟
+		gameOver := false
+		for accumulator >= tickDuration && !gameOver && !g.replayDone() {
+			accumulator -= tickDuration
+			gameOver = g.step()
 		}
+
 		g.draw()
+		if gameOver {
+			if !g.gameOver() {
+				return g.quit()
+			}
+			// 不把结束画面停留的时间计入模拟
+			last = time.Now()
+			accumulator = 0
+		} else if g.replayDone() {
+			g.replayFinished()
+			return nil
+		}
+	}
+	return nil
+}
+
+// replayDone reports whether a replay has been played to its end
+func (g *Game) replayDone() bool {
+	return g.player != nil && g.player.finished(g.sim.tick)
+}
+
+// step advances the simulation by one tick with the queued actions and
+// plays the resulting sounds. It returns true when the run just ended.
+func (g *Game) step() bool {
+	if g.player != nil {
+		// 宽度变化在同一 tick 的动作之前生效，与录制和校验时一致
+		g.player.resizeAt(g.sim, g.sim.tick)
+		g.pending = append(g.pending, g.player.actionsAt(g.sim.tick)...)
+	}
+	if g.recorder != nil {
+		g.recorder.Record(g.sim.tick, g.pending)
+	}
+	state := g.sim.Step(g.pending)
+	g.pending = g.pending[:0]
+	am := GetAudioManager()
+	for _, name := range state.Sounds {
+		am.PlaySound(name)
+		if feedbackSettings.active(name) {
+			g.feedback.trigger(feedbackSettings.effect(name), time.Now())
+		}
+	}
+	am.UpdateMusic(state)
+	return state.GameOver
+}
+
+// Close gives the terminal's keyboard mode back when the program is ended
+// without leaving the game loop, for example by a signal
+func (g *Game) Close() {
+	g.keyboard.Close()
+}
+
+// quit saves the recording, if any, before leaving the game loop
+func (g *Game) quit() error {
+	g.keyboard.Close()
+	GetAudioManager().Close()
+	if g.recorder == nil {
+		return nil
 	}
+	return g.recorder.Save(g.sim.State())
 }
@@ -1,74 +1,217 @@
 package game
 
-import "github.com/nsf/termbox-go"
+import (
+	"fmt"
+	"github.com/nsf/termbox-go"
+	"strings"
+	"unicode/utf8"
+)
 
-// handleEvent processes a single input event.
+// KeyBinding is a single key: either a special termbox key or, when Ch is
+// set, a printable character
+type KeyBinding struct {
+	Key termbox.Key
+	Ch  rune
+}
+
+// Matches reports whether the key event was produced by this binding
+func (b KeyBinding) Matches(ev termbox.Event) bool {
+	if b.Ch != 0 {
+		return ev.Ch == b.Ch
+	}
+	return ev.Ch == 0 && ev.Key == b.Key
+}
+
+// keyNames maps the names used in the config file to special keys
+var keyNames = map[string]termbox.Key{
+	"space":     termbox.KeySpace,
+	"up":        termbox.KeyArrowUp,
+	"down":      termbox.KeyArrowDown,
+	"left":      termbox.KeyArrowLeft,
+	"right":     termbox.KeyArrowRight,
+	"enter":     termbox.KeyEnter,
+	"esc":       termbox.KeyEsc,
+	"tab":       termbox.KeyTab,
+	"backspace": termbox.KeyBackspace2,
+	"insert":    termbox.KeyInsert,
+	"delete":    termbox.KeyDelete,
+	"home":      termbox.KeyHome,
+	"end":       termbox.KeyEnd,
+	"pgup":      termbox.KeyPgup,
+	"pgdn":      termbox.KeyPgdn,
+	"f1":        termbox.KeyF1,
+	"f2":        termbox.KeyF2,
+	"f3":        termbox.KeyF3,
+	"f4":        termbox.KeyF4,
+	"f5":        termbox.KeyF5,
+	"f6":        termbox.KeyF6,
+	"f7":        termbox.KeyF7,
+	"f8":        termbox.KeyF8,
+	"f9":        termbox.KeyF9,
+	"f10":       termbox.KeyF10,
+	"f11":       termbox.KeyF11,
+	"f12":       termbox.KeyF12,
+}
+
+// ParseKeyBinding parses a key name ("space", "up", "esc", ...) or a
+// single character ("k")
+func ParseKeyBinding(name string) (KeyBinding, error) {
+	if utf8.RuneCountInString(name) == 1 && name != " " {
+		ch, _ := utf8.DecodeRuneInString(name)
+		return KeyBinding{Ch: ch}, nil
+	}
+	if key, ok := keyNames[strings.ToLower(name)]; ok {
+		return KeyBinding{Key: key}, nil
+	}
+	return KeyBinding{}, fmt.Errorf("unknown key %q", name)
+}
+
+// String returns the name ParseKeyBinding accepts for this binding
+func (b KeyBinding) String() string {
+	if b.Ch != 0 {
+		return string(b.Ch)
+	}
+	for name, key := range keyNames {
+		if key == b.Key {
+			return name
+		}
+	}
+	return fmt.Sprintf("key(%d)", b.Key)
+}
+
+// Label returns the binding as shown in on-screen hints: 'q', Space, Up
+func (b KeyBinding) Label() string {
+	if b.Ch != 0 {
+		return "'" + string(b.Ch) + "'"
+	}
+	name := b.String()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// named reports whether the binding can be written to the config file
+func (b KeyBinding) named() bool {
+	_, err := ParseKeyBinding(b.String())
+	return err == nil
+}
+
+// Action is a single player input understood by the Simulation
+type Action int
+
+const (
+	ActionJump        Action = iota // jump, or start the run
+	ActionDuck                      // duck on the ground, fast drop in the air
+	ActionDuckRelease               // the down key was released
+	ActionPause                     // pause/resume the run
+	ActionRestart                   // start a new run after game over
+)
+
+// String returns the action name
+func (a Action) String() string {
+	switch a {
+	case ActionJump:
+		return "jump"
+	case ActionDuck:
+		return "duck"
+	case ActionDuckRelease:
+		return "duck-release"
+	case ActionPause:
+		return "pause"
+	case ActionRestart:
+		return "restart"
+	}
+	return "unknown"
+}
+
+// handleEvent translates a single input event into actions queued for the
+// next tick. It returns false when the player asked to quit.
 func (g *Game) handleEvent(ev termbox.Event) bool {
-	if ev.Type == termbox.EventKey {
-		switch ev.Key {
-		case KeyJump, KeyJumpAlt:
-			if !g.started {
-				g.started = true
-				g.groundExtending = true
-			}
-			g.dino.Jump()
-			// cancel duck when jumping
-			g.dino.duckFrames = 0
-			// 跳跃时重置下键状态
-			g.downKeyHeld = false
-			g.dino.isDownKeyPressed = false
-		case KeyDuck:
-			if g.started {
-				// 设置下键被按住的状态
-				g.downKeyHeld = true
-				g.dino.isDownKeyPressed = true
-
-				if int(g.dino.posY) == height-2 {
-					// 在地面上按下键时蹲下
-					g.dino.Duck()
-				} else {
-					// 在空中按下键时快速下降
-					g.dino.FastDrop()
-				}
-			}
-		case KeyQuit:
+	if ev.Type == termbox.EventResize {
+		g.resize(ev.Width, ev.Height)
+		return true
+	}
+	if ev.Type != termbox.EventKey {
+		return true
+	}
+
+	// 按键设置界面打开时，按键都交给它处理
+	if g.bindScreen != nil {
+		if ev.Key == termbox.KeyCtrlC {
 			return false
-		case termbox.KeyCtrlC: // 添加对 Ctrl+C 的处理
+		}
+		g.handleBindEvent(ev)
+		return true
+	}
+	if g.audioScreen != nil {
+		if ev.Key == termbox.KeyCtrlC {
 			return false
-		default:
-			// 如果按下了其他键，认为下键已释放
-			if g.downKeyHeld {
-				g.downKeyHeld = false
-				g.dino.isDownKeyPressed = false
-			}
 		}
-
-		// 处理字符键
-		switch ev.Ch {
-		case KeyQuitRune:
+		g.handleAudioEvent(ev)
+		return true
+	}
+	// 排行榜界面按任意键返回
+	if g.boardOpen {
+		if ev.Key == termbox.KeyCtrlC {
 			return false
-		case 'm': // 音效开关
-			am := GetAudioManager()
-			am.SetEnabled(!am.IsEnabled())
-			// 保持蹲下状态，如果当前正在蹲下
-			if g.started && int(g.dino.posY) == height-2 && g.dino.IsDucking() {
-				g.dino.Duck()
-			}
-		case KeyRestartRune: // 重新开始游戏
-			if g.collided {
-				g.Reset()
-			}
-		case KeyPauseRune: // 暂停/继续游戏
-			if g.started && !g.collided {
-				g.TogglePause()
-			}
-		default:
-			// 如果按下了其他字符键，认为下键已释放
-			if g.downKeyHeld {
-				g.downKeyHeld = false
-				g.dino.isDownKeyPressed = false
-			}
 		}
+		g.boardOpen = false
+		return true
+	}
+
+	if isQuitKey(ev) {
+		return false
+	}
+	if keyMap.Matches(InputToggleSound, ev) { // 音效开关，不影响蹲下状态
+		am := GetAudioManager()
+		am.SetEnabled(!am.IsEnabled())
+		return true
+	}
+
+	// 回放模式下只响应退出和音效开关
+	if g.player != nil {
+		return true
+	}
+
+	// 只能在开始界面或暂停时修改按键和音频设置、查看排行榜
+	if !g.sim.started || g.sim.pause {
+		switch {
+		case keyMap.Matches(InputBind, ev):
+			g.openBindScreen()
+			return true
+		case keyMap.Matches(InputLeaderboard, ev):
+			g.boardOpen = true
+			return true
+		case keyMap.Matches(InputAudio, ev):
+			g.openAudioScreen()
+			return true
+		}
+	}
+
+	switch {
+	case keyMap.Matches(InputJump, ev):
+		g.queue(ActionJump)
+		return true
+	case keyMap.Matches(InputDuck, ev):
+		g.queue(ActionDuck)
+		return true
+	}
+
+	// 终端不报告按键释放时，如果按下了其他键，认为下键已释放
+	if g.sim.downKeyHeld && !g.keyboard.reportsReleases() {
+		g.queue(ActionDuckRelease)
+	}
+	if keyMap.Matches(InputPause, ev) { // 暂停/继续游戏
+		g.queue(ActionPause)
 	}
 	return true
 }
+
+// isQuitKey reports whether ev is bound to quit (Ctrl+C always quits)
+func isQuitKey(ev termbox.Event) bool {
+	return ev.Type == termbox.EventKey &&
+		(keyMap.Matches(InputQuit, ev) || ev.Key == termbox.KeyCtrlC)
+}
+
+// queue adds an action to be applied on the next simulation tick
+func (g *Game) queue(a Action) {
+	g.pending = append(g.pending, a)
+}
@@ -0,0 +1,43 @@
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// inputEvent is a terminal event. Release is set for key releases, which
+// are only reported when the terminal speaks the kitty keyboard protocol.
+type inputEvent struct {
+	termbox.Event
+	Release bool
+}
+
+// keyboard reads terminal events in the background. With enhanced input it
+// asks the terminal for the kitty keyboard protocol and, if the terminal
+// confirms, reports real key releases. Terminals without it (including
+// xterm, whose modifyOtherKeys mode never reports releases) keep working
+// with plain key presses.
+type keyboard struct {
+	events   chan inputEvent
+	releases atomic.Bool // the terminal confirmed that it reports releases
+	enhanced bool
+
+	mu     sync.Mutex // guards tty and closed; Close may run on a signal
+	tty    *os.File   // the terminal, for protocol requests
+	closed bool
+}
+
+// newKeyboard starts reading events; enhanced enables protocol detection
+func newKeyboard(enhanced bool) *keyboard {
+	k := &keyboard{events: make(chan inputEvent), enhanced: enhanced}
+	go k.run()
+	return k
+}
+
+// reportsReleases reports whether key releases are delivered as events,
+// so the down key no longer has to be guessed released
+func (k *keyboard) reportsReleases() bool {
+	return k.releases.Load()
+}
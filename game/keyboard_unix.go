@@ -0,0 +1,223 @@
+//go:build !windows
+
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// kitty keyboard protocol sequences, see
+// https://sw.kovidgoyal.net/kitty/keyboard-protocol/
+const (
+	kittyQuery = "\x1b[?u\x1b[c" // query the flags, then primary device attributes as a fallback reply
+	kittyPush  = "\x1b[>3u"      // disambiguate escape codes (1) + report event types (2)
+	kittyPop   = "\x1b[<u"
+)
+
+// kitty event types
+const (
+	kittyPress   = 1
+	kittyRepeat  = 2
+	kittyRelease = 3
+)
+
+// run reads raw terminal input and turns it into events
+func (k *keyboard) run() {
+	if !k.enhanced {
+		for {
+			k.events <- inputEvent{Event: termbox.PollEvent()}
+		}
+	}
+
+	if tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		k.mu.Lock()
+		if k.closed {
+			tty.Close()
+		} else {
+			k.tty = tty
+			tty.WriteString(kittyQuery)
+		}
+		k.mu.Unlock()
+	}
+
+	buf := make([]byte, 256)
+	var pending []byte
+	for {
+		ev := termbox.PollRawEvent(buf)
+		if ev.Type != termbox.EventRaw {
+			k.events <- inputEvent{Event: ev}
+			continue
+		}
+		pending = append(pending, buf[:ev.N]...)
+		pending = k.parse(pending)
+	}
+}
+
+// Close restores the terminal's keyboard mode and closes the terminal. It
+// may be called more than once, also from a signal handler.
+func (k *keyboard) Close() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.closed {
+		return
+	}
+	k.closed = true
+	if k.tty == nil {
+		return
+	}
+	if k.reportsReleases() {
+		k.tty.WriteString(kittyPop)
+	}
+	k.tty.Close()
+	k.tty = nil
+}
+
+// pushFlags turns on key release reports, unless the keyboard was closed
+// in the meantime and would leave them on
+func (k *keyboard) pushFlags() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.closed || k.tty == nil || k.reportsReleases() {
+		return
+	}
+	k.tty.WriteString(kittyPush)
+	k.releases.Store(true)
+}
+
+// parse emits every complete event in data and returns the unparsed rest
+func (k *keyboard) parse(data []byte) []byte {
+	for len(data) > 0 {
+		if len(data) >= 2 && data[0] == '\x1b' && data[1] == '[' {
+			n := csiLength(data)
+			if n == 0 {
+				return data // 转义序列还没读完整
+			}
+			k.handleCSI(data[:n])
+			data = data[n:]
+			continue
+		}
+		if len(data) == 2 && data[0] == '\x1b' && data[1] == 'O' {
+			return data
+		}
+
+		ev := termbox.ParseEvent(data)
+		if ev.N == 0 {
+			return nil
+		}
+		if ev.Type != termbox.EventNone {
+			k.events <- inputEvent{Event: ev}
+		}
+		data = data[ev.N:]
+	}
+	return nil
+}
+
+// csiLength returns the length of the CSI sequence at the start of data,
+// or 0 if it is incomplete
+func csiLength(data []byte) int {
+	for i := 2; i < len(data); i++ {
+		if data[i] >= 0x40 && data[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// csiKeys maps the final byte of CSI key sequences to keys
+var csiKeys = map[byte]termbox.Key{
+	'A': termbox.KeyArrowUp,
+	'B': termbox.KeyArrowDown,
+	'C': termbox.KeyArrowRight,
+	'D': termbox.KeyArrowLeft,
+	'H': termbox.KeyHome,
+	'F': termbox.KeyEnd,
+	'P': termbox.KeyF1,
+	'Q': termbox.KeyF2,
+	'S': termbox.KeyF4,
+}
+
+// tildeKeys maps the number of CSI ~ sequences to keys
+var tildeKeys = map[int]termbox.Key{
+	1: termbox.KeyHome, 2: termbox.KeyInsert, 3: termbox.KeyDelete, 4: termbox.KeyEnd,
+	5: termbox.KeyPgup, 6: termbox.KeyPgdn, 7: termbox.KeyHome, 8: termbox.KeyEnd,
+	13: termbox.KeyF3, 15: termbox.KeyF5, 17: termbox.KeyF6, 18: termbox.KeyF7,
+	19: termbox.KeyF8, 20: termbox.KeyF9, 21: termbox.KeyF10, 23: termbox.KeyF11,
+	24: termbox.KeyF12,
+}
+
+// codeKeys maps kitty key codes of CSI u sequences to special keys
+var codeKeys = map[int]termbox.Key{
+	9:   termbox.KeyTab,
+	13:  termbox.KeyEnter,
+	27:  termbox.KeyEsc,
+	32:  termbox.KeySpace,
+	127: termbox.KeyBackspace2,
+}
+
+// handleCSI handles a complete CSI sequence: protocol replies and keys in
+// both the legacy and the kitty encoding
+func (k *keyboard) handleCSI(seq []byte) {
+	final := seq[len(seq)-1]
+	params := string(seq[2 : len(seq)-1])
+
+	// 终端的回复：支持 kitty 协议时先回复当前标志，再回复设备属性
+	if strings.HasPrefix(params, "?") {
+		if final == 'u' {
+			k.pushFlags()
+		}
+		return
+	}
+
+	// code[:alternates];modifiers[:event][;text]
+	fields := strings.Split(params, ";")
+	code, _ := strconv.Atoi(strings.Split(fields[0], ":")[0])
+	mods, event := 1, kittyPress
+	if len(fields) > 1 {
+		sub := strings.Split(fields[1], ":")
+		if m, err := strconv.Atoi(sub[0]); err == nil {
+			mods = m
+		}
+		if len(sub) > 1 {
+			if e, err := strconv.Atoi(sub[1]); err == nil {
+				event = e
+			}
+		}
+	}
+
+	ev := termbox.Event{Type: termbox.EventKey}
+	switch final {
+	case 'u':
+		if key, ok := codeKeys[code]; ok {
+			ev.Key = key
+		} else if ctrl := (mods-1)&4 != 0; ctrl && code >= 'a' && code <= 'z' {
+			ev.Key = termbox.KeyCtrlA + termbox.Key(code-'a')
+		} else if code > 0 && code < 0xe000 {
+			ev.Ch = rune(code)
+		} else {
+			return // 修饰键、小键盘等功能键
+		}
+	case '~':
+		key, ok := tildeKeys[code]
+		if !ok {
+			return
+		}
+		ev.Key = key
+	default:
+		key, ok := csiKeys[final]
+		if !ok {
+			// 其他序列（例如鼠标）交给 termbox 解析
+			if tev := termbox.ParseEvent(seq); tev.Type != termbox.EventNone && tev.N == len(seq) {
+				k.events <- inputEvent{Event: tev}
+			}
+			return
+		}
+		ev.Key = key
+	}
+	if (mods-1)&2 != 0 {
+		ev.Mod = termbox.ModAlt
+	}
+	k.events <- inputEvent{Event: ev, Release: event == kittyRelease}
+}
@@ -0,0 +1,99 @@
+//go:build !windows
+
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestKeyboardParse(t *testing.T) {
+	press := func(key termbox.Key, ch rune) inputEvent {
+		return inputEvent{Event: termbox.Event{Type: termbox.EventKey, Key: key, Ch: ch}}
+	}
+	release := func(key termbox.Key, ch rune) inputEvent {
+		ev := press(key, ch)
+		ev.Release = true
+		return ev
+	}
+	tests := []struct {
+		name string
+		data string
+		want []inputEvent
+		rest string
+	}{
+		{"plain character", "a", []inputEvent{press(0, 'a')}, ""},
+		{"legacy arrow", "\x1b[B", []inputEvent{press(termbox.KeyArrowDown, 0)}, ""},
+		{"legacy tilde key", "\x1b[24~", []inputEvent{press(termbox.KeyF12, 0)}, ""},
+		{"kitty character", "\x1b[97u", []inputEvent{press(0, 'a')}, ""},
+		{"kitty space release", "\x1b[32;1:3u", []inputEvent{release(termbox.KeySpace, 0)}, ""},
+		{"kitty arrow press and release", "\x1b[1;1:1B\x1b[1;1:3B", []inputEvent{press(termbox.KeyArrowDown, 0), release(termbox.KeyArrowDown, 0)}, ""},
+		{"kitty ctrl+c", "\x1b[99;5u", []inputEvent{press(termbox.KeyCtrlC, 0)}, ""},
+		{"modifier key alone", "\x1b[57441u", nil, ""},
+		{"protocol reply", "\x1b[?0u\x1b[?62;22c", nil, ""},
+		{"incomplete sequence", "a\x1b[1;1:", []inputEvent{press(0, 'a')}, "\x1b[1;1:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &keyboard{events: make(chan inputEvent, 10)}
+			rest := k.parse([]byte(tt.data))
+			close(k.events)
+			var got []inputEvent
+			for ev := range k.events {
+				ev.N = 0
+				got = append(got, ev)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events %+v, want %+v", got, tt.want)
+			}
+			if string(rest) != tt.rest {
+				t.Errorf("rest %q, want %q", rest, tt.rest)
+			}
+			// 没有打开终端时不会打开 kitty 协议
+			if k.reportsReleases() {
+				t.Error("key releases were turned on without a terminal")
+			}
+		})
+	}
+}
+
+// TestKeyboardClose checks that a closed keyboard stays closed and does
+// not turn the protocol on when the terminal's reply comes in late
+func TestKeyboardClose(t *testing.T) {
+	k := &keyboard{events: make(chan inputEvent, 1)}
+	k.Close()
+	k.Close()
+	k.parse([]byte("\x1b[?0u"))
+	if !k.closed || k.reportsReleases() {
+		t.Errorf("closed %v, releases %v after Close", k.closed, k.reportsReleases())
+	}
+}
+
+// TestKeyboardProtocol checks the sequences sent to the terminal: release
+// reports are turned on when the terminal confirms the protocol and off
+// again on Close, which also closes the terminal
+func TestKeyboardProtocol(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	k := &keyboard{events: make(chan inputEvent, 1), tty: w}
+	k.parse([]byte("\x1b[?0u\x1b[?62c"))
+	if !k.reportsReleases() {
+		t.Fatal("the confirmed protocol was not turned on")
+	}
+	k.Close()
+	k.Close()
+
+	sent, err := io.ReadAll(r) // 终端关闭后读到 EOF
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := kittyPush + kittyPop; string(sent) != want {
+		t.Errorf("sent %q, want %q", sent, want)
+	}
+}
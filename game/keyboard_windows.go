@@ -0,0 +1,18 @@
+//go:build windows
+
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+)
+
+// run reads console events; the Windows console has no kitty keyboard
+// protocol, so key releases are never reported
+func (k *keyboard) run() {
+	for {
+		k.events <- inputEvent{Event: termbox.PollEvent()}
+	}
+}
+
+// Close is a no-op on Windows
+func (k *keyboard) Close() {}
@@ -0,0 +1,245 @@
+package game
+
+import (
+	"fmt"
+	"github.com/nsf/termbox-go"
+	"strings"
+)
+
+// InputAction is something the player can bind keys to. Unlike Action it
+// also covers frontend-only commands such as quitting or toggling sound.
+type InputAction int
+
+const (
+	InputJump        InputAction = iota // jump, or start the run
+	InputDuck                           // duck / fast drop
+	InputPause                          // pause/resume the run
+	InputRestart                        // start a new run after game over
+	InputQuit                           // leave the game (Ctrl+C always quits too)
+	InputToggleSound                    // turn sound effects on or off
+	InputBind                           // open the key binding screen
+	InputLeaderboard                    // show the leaderboard
+	InputAudio                          // open the audio settings screen
+	inputActionCount
+)
+
+// String returns the name used for the action in the config file
+func (a InputAction) String() string {
+	switch a {
+	case InputJump:
+		return "jump"
+	case InputDuck:
+		return "duck"
+	case InputPause:
+		return "pause"
+	case InputRestart:
+		return "restart"
+	case InputQuit:
+		return "quit"
+	case InputToggleSound:
+		return "toggle_sound"
+	case InputBind:
+		return "bind"
+	case InputLeaderboard:
+		return "leaderboard"
+	case InputAudio:
+		return "audio"
+	}
+	return "unknown"
+}
+
+// Title returns the action name shown on the key binding screen
+func (a InputAction) Title() string {
+	switch a {
+	case InputJump:
+		return "Jump"
+	case InputDuck:
+		return "Duck"
+	case InputPause:
+		return "Pause"
+	case InputRestart:
+		return "Restart"
+	case InputQuit:
+		return "Quit"
+	case InputToggleSound:
+		return "Sound on/off"
+	case InputBind:
+		return "Key bindings"
+	case InputLeaderboard:
+		return "Leaderboard"
+	case InputAudio:
+		return "Audio settings"
+	}
+	return "Unknown"
+}
+
+// required reports whether the game cannot be played without a key for a
+func (a InputAction) required() bool {
+	return a == InputJump || a == InputRestart
+}
+
+// parseInputAction converts a config file name back into an InputAction
+func parseInputAction(name string) (InputAction, bool) {
+	for a := InputAction(0); a < inputActionCount; a++ {
+		if a.String() == name {
+			return a, true
+		}
+	}
+	return 0, false
+}
+
+// KeyMap maps every input action to the keys bound to it
+type KeyMap map[InputAction][]KeyBinding
+
+// DefaultKeyMap returns the built-in key bindings
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		InputJump:        {{Key: termbox.KeySpace}, {Key: termbox.KeyArrowUp}},
+		InputDuck:        {{Key: termbox.KeyArrowDown}},
+		InputPause:       {{Ch: 'p'}},
+		InputRestart:     {{Ch: 'r'}},
+		InputQuit:        {{Ch: 'q'}, {Key: termbox.KeyEsc}},
+		InputToggleSound: {{Ch: 'm'}},
+		InputBind:        {{Ch: 'b'}},
+		InputLeaderboard: {{Ch: 'l'}},
+		InputAudio:       {{Ch: 'v'}},
+	}
+}
+
+// Matches reports whether ev is bound to a
+func (m KeyMap) Matches(a InputAction, ev termbox.Event) bool {
+	if ev.Type != termbox.EventKey {
+		return false
+	}
+	for _, b := range m[a] {
+		if b.Matches(ev) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the action the binding is bound to
+func (m KeyMap) Lookup(b KeyBinding) (InputAction, bool) {
+	for a := InputAction(0); a < inputActionCount; a++ {
+		for _, x := range m[a] {
+			if x == b {
+				return a, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Bind adds b to the keys of a, removing it from any other action
+func (m KeyMap) Bind(a InputAction, b KeyBinding) {
+	if other, ok := m.Lookup(b); ok {
+		if other == a {
+			return
+		}
+		m.unbind(other, b)
+	}
+	m[a] = append(m[a], b)
+}
+
+// unbind removes b from the keys of a
+func (m KeyMap) unbind(a InputAction, b KeyBinding) {
+	keys := m[a][:0:0]
+	for _, x := range m[a] {
+		if x != b {
+			keys = append(keys, x)
+		}
+	}
+	m[a] = keys
+}
+
+// Hint returns the label of the first key bound to a, for on-screen hints
+func (m KeyMap) Hint(a InputAction) string {
+	if len(m[a]) == 0 {
+		return "(unbound)"
+	}
+	return m[a][0].Label()
+}
+
+// Describe returns the labels of all keys bound to a, joined with sep
+func (m KeyMap) Describe(a InputAction, sep string) string {
+	if len(m[a]) == 0 {
+		return "(unbound)"
+	}
+	labels := make([]string, len(m[a]))
+	for i, b := range m[a] {
+		labels[i] = b.Label()
+	}
+	return strings.Join(labels, sep)
+}
+
+// names returns the config file names of the keys bound to a
+func (m KeyMap) names(a InputAction) []string {
+	names := make([]string, len(m[a]))
+	for i, b := range m[a] {
+		names[i] = b.String()
+	}
+	return names
+}
+
+// parseKeyMap builds a key map from the [keys] section of the config file.
+// Actions missing from the section keep their keys from base, except keys
+// the section binds to something else. Every key may be bound to only one
+// action.
+func parseKeyMap(keys map[string][]string, base KeyMap) (KeyMap, []error) {
+	var errs []error
+	for name := range keys {
+		if _, ok := parseInputAction(name); !ok {
+			errs = append(errs, fmt.Errorf("keys: unknown action %q", name))
+		}
+	}
+
+	m := KeyMap{}
+	for a := InputAction(0); a < inputActionCount; a++ {
+		values, ok := keys[a.String()]
+		if !ok {
+			continue
+		}
+		m[a] = []KeyBinding{}
+		for _, v := range values {
+			b, err := ParseKeyBinding(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("keys.%s: %v", a, err))
+				continue
+			}
+			if other, ok := m.Lookup(b); ok {
+				if other != a {
+					errs = append(errs, fmt.Errorf("keys.%s: %q is already bound to %s", a, v, other))
+				}
+				continue
+			}
+			m[a] = append(m[a], b)
+		}
+	}
+	for a := InputAction(0); a < inputActionCount; a++ {
+		if _, ok := keys[a.String()]; ok {
+			continue
+		}
+		m[a] = []KeyBinding{}
+		for _, b := range base[a] {
+			if _, taken := m.Lookup(b); !taken {
+				m[a] = append(m[a], b)
+			}
+		}
+	}
+
+	for a := InputAction(0); a < inputActionCount; a++ {
+		if a.required() && len(m[a]) == 0 {
+			errs = append(errs, fmt.Errorf("keys.%s: at least one key is required", a))
+		}
+	}
+	return m, errs
+}
+
+// keyEvent returns the binding that produced a key event
+func keyEvent(ev termbox.Event) KeyBinding {
+	if ev.Ch != 0 {
+		return KeyBinding{Ch: ev.Ch}
+	}
+	return KeyBinding{Key: ev.Key}
+}
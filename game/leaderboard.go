@@ -0,0 +1,261 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// leaderboardFileName is the leaderboard file in the data directory
+const leaderboardFileName = "leaderboard.json"
+
+// legacyLeaderboardFileName is where earlier versions kept the leaderboard
+// in the home directory; it is moved to the data directory on first load
+const legacyLeaderboardFileName = ".term-rex-leaderboard.json"
+
+// legacyHighScoreFileName is the single-integer high score file used
+// before the leaderboard existed; it is migrated on first load
+const legacyHighScoreFileName = ".term-rex-highscore"
+
+// leaderboardSize is the number of runs kept on the leaderboard
+const leaderboardSize = 10
+
+// maxNameLength is the longest player name accepted
+const maxNameLength = 12
+
+// LeaderboardEntry is a single run on the leaderboard
+type LeaderboardEntry struct {
+	Score int       `json:"score"`
+	Name  string    `json:"name"`
+	Date  time.Time `json:"date"`
+	Seed  int64     `json:"seed"`
+	Stage int       `json:"stage"` // stage reached, starting at 1; 0 if unknown
+	Ticks int       `json:"ticks"` // run duration in simulation ticks; 0 if unknown
+}
+
+// Duration returns how long the run lasted
+func (e LeaderboardEntry) Duration() time.Duration {
+	return time.Duration(e.Ticks) * tickDuration
+}
+
+// Leaderboard is the top-N list of runs, best first
+type Leaderboard struct {
+	Entries  []LeaderboardEntry `json:"entries"`
+	LastName string             `json:"last_name"` // name entered last, offered again next time
+}
+
+// leaderboardPath returns the path of the leaderboard file
+func leaderboardPath() (string, error) {
+	dir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, leaderboardFileName), nil
+}
+
+// LoadLeaderboard 从文件中加载排行榜。主文件损坏时从备份恢复，
+// 旧版本保存在主目录中的排行榜和最高分文件会被自动迁移
+func LoadLeaderboard() (*Leaderboard, error) {
+	path, err := leaderboardPath()
+	if err != nil {
+		return &Leaderboard{}, err
+	}
+	unlock, err := lockFile(path)
+	if err != nil {
+		return &Leaderboard{}, err
+	}
+	defer unlock()
+	return loadLeaderboard(path)
+}
+
+// loadLeaderboard reads the leaderboard at path, recovering from the backup
+// or migrating older files as needed. The caller holds the lock.
+func loadLeaderboard(path string) (*Leaderboard, error) {
+	lb, err := readLeaderboard(path)
+	if err == nil {
+		return lb, nil
+	}
+	if os.IsNotExist(err) {
+		return migrateLeaderboard(path)
+	}
+
+	// 主文件损坏：从备份恢复
+	bak, bakErr := readLeaderboard(path + ".bak")
+	if bakErr != nil {
+		// 备份也不可用：保留损坏的文件以便手动恢复，从空排行榜开始
+		os.Rename(path, path+".corrupt")
+		return &Leaderboard{}, fmt.Errorf("排行榜文件已损坏且没有可用的备份: %v", err)
+	}
+	return bak, bak.write(path)
+}
+
+// readLeaderboard parses a leaderboard file
+func readLeaderboard(path string) (*Leaderboard, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lb := &Leaderboard{}
+	if err := json.Unmarshal(data, lb); err != nil {
+		return nil, fmt.Errorf("无法解析排行榜文件 %s: %v", path, err)
+	}
+	lb.sort()
+	return lb, nil
+}
+
+// migrateLeaderboard 把旧版本保存在主目录中的排行榜移动到 path
+func migrateLeaderboard(path string) (*Leaderboard, error) {
+	oldPath, ok := legacyPath(legacyLeaderboardFileName)
+	if !ok {
+		return &Leaderboard{}, nil
+	}
+	lb, err := readLeaderboard(oldPath)
+	if err != nil {
+		return migrateHighScore(path)
+	}
+	if err := lb.write(path); err != nil {
+		return lb, err
+	}
+	os.Remove(oldPath)
+	os.Remove(oldPath + ".bak")
+	os.Remove(oldPath + ".lock")
+	return lb, nil
+}
+
+// migrateHighScore 把旧的最高分文件转换为只有一条记录的排行榜
+func migrateHighScore(path string) (*Leaderboard, error) {
+	lb := &Leaderboard{}
+	oldPath, ok := legacyPath(legacyHighScoreFileName)
+	if !ok {
+		return lb, nil
+	}
+	info, err := os.Stat(oldPath)
+	if os.IsNotExist(err) {
+		return lb, nil
+	}
+	data, err := os.ReadFile(oldPath)
+	if err != nil {
+		return lb, fmt.Errorf("无法读取高分文件: %v", err)
+	}
+	score, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return lb, fmt.Errorf("无法解析高分: %v", err)
+	}
+	if score > 0 {
+		lb.Entries = []LeaderboardEntry{{Score: score, Name: "(old best)", Date: info.ModTime()}}
+	}
+	if err := lb.write(path); err != nil {
+		return lb, err
+	}
+	// 迁移成功后删除旧文件
+	os.Remove(oldPath)
+	return lb, nil
+}
+
+// write 原子地写入排行榜，并把上一个有效的版本保留为备份。调用者需持有锁
+func (lb *Leaderboard) write(path string) error {
+	data, err := json.MarshalIndent(lb, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := readLeaderboard(path); err == nil {
+		old, err := os.ReadFile(path)
+		if err == nil {
+			if err := writeFileAtomic(path+".bak", old, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0644)
+}
+
+// Record adds a run and saves the leaderboard. Runs saved by other game
+// instances in the meantime are merged in, so parallel sessions never
+// overwrite each other's scores.
+func (lb *Leaderboard) Record(e LeaderboardEntry) error {
+	lb.Add(e)
+	path, err := leaderboardPath()
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// 合并磁盘上的最新内容（即使读取失败，内存中的记录也会被写回）
+	disk, _ := loadLeaderboard(path)
+	lb.merge(disk)
+	return lb.write(path)
+}
+
+// merge adds the entries of other that are not on lb yet
+func (lb *Leaderboard) merge(other *Leaderboard) {
+	for _, e := range other.Entries {
+		if !lb.contains(e) {
+			lb.Entries = append(lb.Entries, e)
+		}
+	}
+	lb.sort()
+}
+
+// contains reports whether the same run is already on the leaderboard
+func (lb *Leaderboard) contains(e LeaderboardEntry) bool {
+	for _, x := range lb.Entries {
+		if x.Score == e.Score && x.Name == e.Name && x.Seed == e.Seed && x.Date.Equal(e.Date) {
+			return true
+		}
+	}
+	return false
+}
+
+// sort orders the entries best first; ties keep the earlier run ahead
+func (lb *Leaderboard) sort() {
+	sort.SliceStable(lb.Entries, func(i, j int) bool {
+		if lb.Entries[i].Score != lb.Entries[j].Score {
+			return lb.Entries[i].Score > lb.Entries[j].Score
+		}
+		return lb.Entries[i].Date.Before(lb.Entries[j].Date)
+	})
+	if len(lb.Entries) > leaderboardSize {
+		lb.Entries = lb.Entries[:leaderboardSize]
+	}
+}
+
+// Best returns the highest score on the leaderboard, or 0 if it is empty
+func (lb *Leaderboard) Best() int {
+	if len(lb.Entries) == 0 {
+		return 0
+	}
+	return lb.Entries[0].Score
+}
+
+// Rank returns the 1-based place a run with score would take, or 0 if it
+// does not make the leaderboard
+func (lb *Leaderboard) Rank(score int) int {
+	if score <= 0 {
+		return 0
+	}
+	for i, e := range lb.Entries {
+		if score > e.Score {
+			return i + 1
+		}
+	}
+	if len(lb.Entries) < leaderboardSize {
+		return len(lb.Entries) + 1
+	}
+	return 0
+}
+
+// Add inserts a run and drops the entries that fall off the end
+func (lb *Leaderboard) Add(e LeaderboardEntry) {
+	lb.Entries = append(lb.Entries, e)
+	lb.LastName = e.Name
+	lb.sort()
+}
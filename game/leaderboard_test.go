@@ -0,0 +1,288 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// day returns a date some days after a fixed start, for ordering entries
+func day(n int) time.Time {
+	return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC).AddDate(0, 0, n)
+}
+
+// entryNames lists the entries as name:score
+func entryNames(lb *Leaderboard) []string {
+	names := []string{}
+	for _, e := range lb.Entries {
+		names = append(names, fmt.Sprintf("%s:%d", e.Name, e.Score))
+	}
+	return names
+}
+
+// useHome points the home directory at a new temporary directory
+func useHome(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	return home
+}
+
+func TestLeaderboardAdd(t *testing.T) {
+	ann := LeaderboardEntry{Score: 300, Name: "ann", Date: day(1)}
+	bob := LeaderboardEntry{Score: 200, Name: "bob", Date: day(2)}
+	cat := LeaderboardEntry{Score: 200, Name: "cat", Date: day(0)}
+	many := func(n, score int) []LeaderboardEntry {
+		var entries []LeaderboardEntry
+		for i := 0; i < n; i++ {
+			entries = append(entries, LeaderboardEntry{Score: score + i, Name: fmt.Sprint("p", i), Date: day(i)})
+		}
+		return entries
+	}
+
+	tests := []struct {
+		name  string
+		board []LeaderboardEntry
+		add   LeaderboardEntry
+		want  []string
+	}{
+		{name: "empty board", add: bob, want: []string{"bob:200"}},
+		{name: "better run goes first", board: []LeaderboardEntry{bob}, add: ann, want: []string{"ann:300", "bob:200"}},
+		{name: "ties keep the earlier run ahead", board: []LeaderboardEntry{bob}, add: cat, want: []string{"cat:200", "bob:200"}},
+		{name: "last place falls off", board: many(leaderboardSize, 100), add: ann, want: []string{
+			"ann:300", "p9:109", "p8:108", "p7:107", "p6:106", "p5:105", "p4:104", "p3:103", "p2:102", "p1:101",
+		}},
+		{name: "too low for a full board", board: many(leaderboardSize, 400), add: ann, want: []string{
+			"p9:409", "p8:408", "p7:407", "p6:406", "p5:405", "p4:404", "p3:403", "p2:402", "p1:401", "p0:400",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := &Leaderboard{Entries: append([]LeaderboardEntry(nil), tt.board...)}
+			lb.sort()
+			lb.Add(tt.add)
+			if got := entryNames(lb); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("board %v, want %v", got, tt.want)
+			}
+			if lb.LastName != tt.add.Name {
+				t.Errorf("last name %q, want %q", lb.LastName, tt.add.Name)
+			}
+		})
+	}
+}
+
+func TestLeaderboardMerge(t *testing.T) {
+	ann := LeaderboardEntry{Score: 300, Name: "ann", Date: day(1), Seed: 1}
+	bob := LeaderboardEntry{Score: 200, Name: "bob", Date: day(2), Seed: 2}
+	cat := LeaderboardEntry{Score: 200, Name: "cat", Date: day(0), Seed: 3}
+
+	tests := []struct {
+		name  string
+		mine  []LeaderboardEntry
+		other []LeaderboardEntry
+		want  []string
+	}{
+		{name: "empty", want: []string{}},
+		{name: "disjoint", mine: []LeaderboardEntry{bob}, other: []LeaderboardEntry{ann}, want: []string{"ann:300", "bob:200"}},
+		{name: "same run once", mine: []LeaderboardEntry{ann, bob}, other: []LeaderboardEntry{ann}, want: []string{"ann:300", "bob:200"}},
+		{name: "same score, other run", mine: []LeaderboardEntry{ann}, other: []LeaderboardEntry{{Score: 300, Name: "ann", Date: day(5), Seed: 1}}, want: []string{"ann:300", "ann:300"}},
+		{name: "ties keep the earlier run ahead", mine: []LeaderboardEntry{bob}, other: []LeaderboardEntry{cat}, want: []string{"cat:200", "bob:200"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := &Leaderboard{Entries: append([]LeaderboardEntry(nil), tt.mine...)}
+			lb.merge(&Leaderboard{Entries: tt.other})
+			if got := entryNames(lb); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("merged %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaderboardRank(t *testing.T) {
+	full := &Leaderboard{}
+	for i := 0; i < leaderboardSize; i++ {
+		full.Add(LeaderboardEntry{Score: 100 * (i + 1), Date: day(i)})
+	}
+	short := &Leaderboard{Entries: []LeaderboardEntry{{Score: 50}}}
+
+	tests := []struct {
+		name  string
+		lb    *Leaderboard
+		score int
+		want  int
+	}{
+		{"empty board", &Leaderboard{}, 1, 1},
+		{"nothing scored", &Leaderboard{}, 0, 0},
+		{"best", full, 1001, 1},
+		{"tie goes below", full, 1000, 2},
+		{"last place", full, 101, leaderboardSize},
+		{"too low for a full board", full, 100, 0},
+		{"room left", short, 10, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lb.Rank(tt.score); got != tt.want {
+				t.Errorf("Rank(%d) = %d, want %d", tt.score, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadLeaderboardMigration(t *testing.T) {
+	board := func(entries ...LeaderboardEntry) string {
+		data, _ := json.Marshal(&Leaderboard{Entries: entries})
+		return string(data)
+	}
+	ann := LeaderboardEntry{Score: 300, Name: "ann", Date: day(1)}
+	bob := LeaderboardEntry{Score: 200, Name: "bob", Date: day(2)}
+
+	tests := []struct {
+		name    string
+		home    map[string]string // files in the home directory
+		data    map[string]string // files next to leaderboard.json
+		want    []string
+		gone    []string // files in the home directory that must be removed
+		kept    []string // files in the data directory that must exist
+		wantErr bool
+	}{
+		{name: "nothing yet", want: []string{}},
+		{name: "current file", data: map[string]string{leaderboardFileName: board(ann, bob)}, want: []string{"ann:300", "bob:200"}},
+		{
+			name: "old leaderboard in the home directory",
+			home: map[string]string{legacyLeaderboardFileName: board(bob, ann), legacyLeaderboardFileName + ".bak": board(bob)},
+			want: []string{"ann:300", "bob:200"},
+			gone: []string{legacyLeaderboardFileName, legacyLeaderboardFileName + ".bak"},
+			kept: []string{leaderboardFileName},
+		},
+		{
+			name: "old high score",
+			home: map[string]string{legacyHighScoreFileName: "1234\n"},
+			want: []string{"(old best):1234"},
+			gone: []string{legacyHighScoreFileName},
+			kept: []string{leaderboardFileName},
+		},
+		{
+			name: "broken old leaderboard falls back to the high score",
+			home: map[string]string{legacyLeaderboardFileName: "{", legacyHighScoreFileName: "77"},
+			want: []string{"(old best):77"},
+		},
+		{
+			name:    "unreadable high score",
+			home:    map[string]string{legacyHighScoreFileName: "lots"},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name: "current file wins over old ones",
+			data: map[string]string{leaderboardFileName: board(bob)},
+			home: map[string]string{legacyHighScoreFileName: "1234"},
+			want: []string{"bob:200"},
+		},
+		{
+			name: "corrupt file restored from the backup",
+			data: map[string]string{leaderboardFileName: "{not json", leaderboardFileName + ".bak": board(ann)},
+			want: []string{"ann:300"},
+			kept: []string{leaderboardFileName, leaderboardFileName + ".bak"},
+		},
+		{
+			name:    "corrupt file without a backup",
+			data:    map[string]string{leaderboardFileName: "{not json"},
+			want:    []string{},
+			kept:    []string{leaderboardFileName + ".corrupt"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home, dataDir := useHome(t), t.TempDir()
+			write := func(dir string, files map[string]string) {
+				for name, content := range files {
+					if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			write(home, tt.home)
+			write(dataDir, tt.data)
+
+			lb, err := loadLeaderboard(filepath.Join(dataDir, leaderboardFileName))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error %v, want error %v", err, tt.wantErr)
+			}
+			if got := entryNames(lb); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loaded %v, want %v", got, tt.want)
+			}
+			for _, name := range tt.gone {
+				if _, err := os.Stat(filepath.Join(home, name)); !os.IsNotExist(err) {
+					t.Errorf("%s is still in the home directory", name)
+				}
+			}
+			for _, name := range tt.kept {
+				if _, err := os.Stat(filepath.Join(dataDir, name)); err != nil {
+					t.Errorf("%s: %v", name, err)
+				}
+			}
+			// 迁移或恢复后的文件下次直接读到同样的内容
+			if len(tt.want) > 0 {
+				again, err := readLeaderboard(filepath.Join(dataDir, leaderboardFileName))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := entryNames(again); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("saved %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestLeaderboardRecord checks that two sessions recording runs into the
+// same data directory keep each other's scores
+func TestLeaderboardRecord(t *testing.T) {
+	dir := t.TempDir()
+	SetDataDir(dir)
+	t.Cleanup(func() { SetDataDir("") })
+
+	first, err := LoadLeaderboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := LoadLeaderboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Record(LeaderboardEntry{Score: 100, Name: "ann", Date: day(0), Seed: 7, Stage: 2, Ticks: 600}); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Record(LeaderboardEntry{Score: 200, Name: "bob", Date: day(1), Seed: -3, Stage: 3, Ticks: 1200}); err != nil {
+		t.Fatal(err)
+	}
+
+	lb, err := LoadLeaderboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entryNames(lb), []string{"bob:200", "ann:100"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("saved %v, want %v", got, want)
+	}
+	if lb.LastName != "bob" {
+		t.Errorf("last name %q, want bob", lb.LastName)
+	}
+	if d := lb.Entries[0].Duration(); d != 1200*tickDuration {
+		t.Errorf("duration %v, want %v", d, 1200*tickDuration)
+	}
+
+	// 备份保留上一次写入的内容
+	bak, err := readLeaderboard(filepath.Join(dir, leaderboardFileName+".bak"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entryNames(bak), []string{"ann:100"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("backup %v, want %v", got, want)
+	}
+}
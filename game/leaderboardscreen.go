@@ -0,0 +1,134 @@
+package game
+
+import (
+	"fmt"
+	"github.com/nsf/termbox-go"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// recordRun adds the finished run to the leaderboard, asking for the
+// player's name when it qualifies. It returns false when the player quit.
+func (g *Game) recordRun() bool {
+	// 先合并其他同时运行的游戏保存的成绩，名次才准确
+	if disk, err := LoadLeaderboard(); err == nil {
+		g.leaderboard.merge(disk)
+	}
+	state := g.sim.State()
+	rank := g.leaderboard.Rank(state.Score)
+	if rank == 0 {
+		return true
+	}
+	name, ok := g.enterName(rank)
+	// 保存失败不影响游戏，新的记录在本次运行中仍然有效
+	g.leaderboard.Record(LeaderboardEntry{
+		Score: state.Score,
+		Name:  name,
+		Date:  time.Now(),
+		Seed:  g.sim.Seed(),
+		Stage: state.Stage + 1,
+		Ticks: state.RunTicks,
+	})
+	return ok
+}
+
+// enterName prompts for the player's name on top of the game over frame.
+// It returns false as second value when the player quit with Ctrl+C.
+func (g *Game) enterName(rank int) (string, bool) {
+	name := []rune(g.leaderboard.LastName)
+	done := func() string {
+		if s := strings.TrimSpace(string(name)); s != "" {
+			return s
+		}
+		return "anonymous"
+	}
+	for {
+		g.draw()
+		g.drawNameEntry(rank, string(name))
+
+		ev := <-g.keyboard.events
+		if ev.Release {
+			continue
+		}
+		if ev.Type == termbox.EventResize {
+			g.resize(ev.Width, ev.Height)
+			continue
+		}
+		if ev.Type != termbox.EventKey {
+			continue
+		}
+		switch {
+		case ev.Key == termbox.KeyCtrlC:
+			return done(), false
+		case ev.Ch == 0 && (ev.Key == termbox.KeyEnter || ev.Key == termbox.KeyEsc):
+			return done(), true
+		case ev.Ch == 0 && (ev.Key == termbox.KeyBackspace || ev.Key == termbox.KeyBackspace2):
+			if len(name) > 0 {
+				name = name[:len(name)-1]
+			}
+		case ev.Ch == 0 && ev.Key == termbox.KeySpace:
+			if len(name) > 0 && len(name) < maxNameLength {
+				name = append(name, ' ')
+			}
+		case ev.Ch != 0 && unicode.IsPrint(ev.Ch):
+			if len(name) < maxNameLength {
+				name = append(name, ev.Ch)
+			}
+		}
+	}
+}
+
+// drawNameEntry draws the name prompt over the last frame
+func (g *Game) drawNameEntry(rank int, name string) {
+	if g.tooSmall() {
+		return
+	}
+	PrintCenterAt(g.renderer, "GAME OVER", height/2-2)
+	PrintCenterAt(g.renderer, fmt.Sprintf("New high score! You placed #%d", rank), height/2)
+	field := name + "_" + strings.Repeat(" ", maxNameLength-len([]rune(name)))
+	PrintCenterAt(g.renderer, "Name: "+field, height/2+1)
+	PrintCenterAt(g.renderer, "(Enter to save)", height/2+2)
+	g.renderer.Flush()
+}
+
+// drawLeaderboard renders the leaderboard view
+func (g *Game) drawLeaderboard() {
+	r := g.renderer
+	PrintCenterAt(r, "LEADERBOARD", 1)
+
+	// 终端足够宽时才显示种子
+	showSeed := width >= 72
+	header := fmt.Sprintf("%2s  %-*s %6s %5s %6s  %-10s", "#", maxNameLength, "Name", "Score", "Stage", "Time", "Date")
+	if showSeed {
+		header += "  Seed"
+	}
+	x := (width - len(header)) / 2
+	if x < 0 {
+		x = 0
+	}
+	PrintAt(r, x, 3, header)
+
+	if len(g.leaderboard.Entries) == 0 {
+		PrintCenterAt(r, "No runs yet", 5)
+	}
+	for i, e := range g.leaderboard.Entries {
+		stage, duration, seed := "-", "-", "-"
+		if e.Stage > 0 {
+			stage = fmt.Sprint(e.Stage)
+		}
+		if e.Ticks > 0 {
+			d := e.Duration().Round(time.Second)
+			duration = fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+		}
+		if e.Seed != 0 {
+			seed = fmt.Sprint(e.Seed)
+		}
+		line := fmt.Sprintf("%2d  %-*s %6d %5s %6s  %-10s", i+1, maxNameLength, e.Name, e.Score, stage, duration, e.Date.Local().Format("2006-01-02"))
+		if showSeed {
+			line += "  " + seed
+		}
+		PrintAt(r, x, 4+i, line)
+	}
+	PrintCenterAt(r, "Press any key to go back", 4+leaderboardSize)
+}
@@ -0,0 +1,261 @@
+package game
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/hajimehoshi/go-mp3"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// 混音输出格式：44.1kHz 立体声 16 位
+const (
+	mixRate     = 44100
+	mixChannels = 2
+	maxVoices   = 16 // 同时播放的音效上限，超出时丢弃最早的
+)
+
+// voice is a sound that is currently playing
+type voice struct {
+	samples []int16
+	pos     int
+	gain    float64
+}
+
+// mixer keeps every sound decoded in memory and mixes the playing ones into
+// one stream of interleaved stereo samples, so overlapping sounds don't need
+// a player process each
+type mixer struct {
+	mu     sync.Mutex
+	sounds map[string][]int16
+	voices []*voice
+	music  *chiptune // background music mixed under the sounds, if any
+}
+
+func newMixer() *mixer {
+	return &mixer{sounds: make(map[string][]int16)}
+}
+
+// Load decodes a sound so it can be played without any further work
+func (m *mixer) Load(s Sound) error {
+	samples, err := decodeSound(s.File, s.Data)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.sounds[s.Name] = samples
+	m.mu.Unlock()
+	return nil
+}
+
+// Play starts a sound on top of the ones already playing; volume scales
+// it from 0 to 1
+func (m *mixer) Play(name string, volume float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	samples, ok := m.sounds[name]
+	if !ok {
+		return
+	}
+	if len(m.voices) >= maxVoices {
+		m.voices = m.voices[1:]
+	}
+	m.voices = append(m.voices, &voice{samples: samples, gain: volume})
+}
+
+// SetMusic makes the mixer play the background music
+func (m *mixer) SetMusic(c *chiptune) {
+	m.mu.Lock()
+	m.music = c
+	m.mu.Unlock()
+}
+
+// mix fills buf with the next samples of the stream and returns how many
+// it wrote, always a whole number of frames
+func (m *mixer) mix(buf []int16) int {
+	n := len(buf) - len(buf)%mixChannels
+	acc := make([]int32, n)
+
+	m.mu.Lock()
+	playing := m.voices[:0]
+	for _, v := range m.voices {
+		k := copyLen(n, len(v.samples)-v.pos)
+		for i, s := range v.samples[v.pos : v.pos+k] {
+			acc[i] += int32(float64(s) * v.gain)
+		}
+		v.pos += k
+		if v.pos < len(v.samples) {
+			playing = append(playing, v)
+		}
+	}
+	m.voices = playing
+	music := m.music
+	m.mu.Unlock()
+
+	if music != nil {
+		music.render(acc)
+	}
+
+	for i, s := range acc {
+		buf[i] = clip16(s)
+	}
+	return n
+}
+
+// Read implements io.Reader with little-endian samples; the stream never
+// ends, it is silent while no sound plays
+func (m *mixer) Read(p []byte) (int, error) {
+	samples := make([]int16, len(p)/2)
+	n := m.mix(samples)
+	for i, s := range samples[:n] {
+		binary.LittleEndian.PutUint16(p[2*i:], uint16(s))
+	}
+	return 2 * n, nil
+}
+
+func copyLen(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// clip16 limits a mixed sample to the int16 range
+func clip16(s int32) int16 {
+	if s > 32767 {
+		return 32767
+	}
+	if s < -32768 {
+		return -32768
+	}
+	return int16(s)
+}
+
+// decodeSound decodes a WAV or MP3 file to interleaved stereo samples at
+// mixRate
+func decodeSound(file string, data []byte) ([]int16, error) {
+	var samples []int16
+	var channels, rate int
+	var err error
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".mp3":
+		samples, rate, err = decodeMP3(data)
+		channels = 2 // go-mp3 总是输出立体声
+	case ".wav":
+		samples, channels, rate, err = decodeWAV(data)
+	default:
+		return nil, fmt.Errorf("%s: 不支持的音频格式", file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if channels == 1 {
+		stereo := make([]int16, 2*len(samples))
+		for i, s := range samples {
+			stereo[2*i], stereo[2*i+1] = s, s
+		}
+		samples = stereo
+	}
+	return resample(samples, rate), nil
+}
+
+// decodeMP3 returns the stereo samples and sample rate of an MP3 file
+func decodeMP3(data []byte) ([]int16, int, error) {
+	d, err := mp3.NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, err
+	}
+	raw, err := io.ReadAll(d)
+	if err != nil {
+		return nil, 0, err
+	}
+	samples := make([]int16, len(raw)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(raw[2*i:]))
+	}
+	return samples, d.SampleRate(), nil
+}
+
+// decodeWAV reads an uncompressed 8 or 16 bit mono or stereo WAV file
+func decodeWAV(data []byte) (samples []int16, channels, rate int, err error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, 0, errors.New("不是 WAV 文件")
+	}
+	var bits int
+	var pcm []byte
+	for rest := data[12:]; len(rest) >= 8; {
+		id := string(rest[0:4])
+		size := int(binary.LittleEndian.Uint32(rest[4:8]))
+		body := rest[8:]
+		if size > len(body) {
+			size = len(body) // 截断的文件尽量播放已有部分
+		}
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, 0, errors.New("fmt 块太短")
+			}
+			if format := binary.LittleEndian.Uint16(body[0:2]); format != 1 && format != 0xfffe {
+				return nil, 0, 0, fmt.Errorf("不支持的 WAV 编码 %d", format)
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			rate = int(binary.LittleEndian.Uint32(body[4:8]))
+			bits = int(binary.LittleEndian.Uint16(body[14:16]))
+		case "data":
+			pcm = body[:size]
+		}
+		rest = body[size:]
+		if size%2 == 1 && len(rest) > 0 {
+			rest = rest[1:] // 块按偶数字节对齐
+		}
+	}
+	switch {
+	case rate == 0:
+		return nil, 0, 0, errors.New("缺少 fmt 块")
+	case channels != 1 && channels != 2:
+		return nil, 0, 0, fmt.Errorf("不支持 %d 个声道", channels)
+	case bits == 16:
+		samples = make([]int16, len(pcm)/2)
+		for i := range samples {
+			samples[i] = int16(binary.LittleEndian.Uint16(pcm[2*i:]))
+		}
+	case bits == 8:
+		samples = make([]int16, len(pcm))
+		for i, b := range pcm {
+			samples[i] = int16(int(b)-128) << 8
+		}
+	default:
+		return nil, 0, 0, fmt.Errorf("不支持 %d 位采样", bits)
+	}
+	samples = samples[:len(samples)-len(samples)%channels]
+	return samples, channels, rate, nil
+}
+
+// resample converts stereo samples from rate to mixRate with linear
+// interpolation
+func resample(samples []int16, rate int) []int16 {
+	if rate == mixRate || rate <= 0 {
+		return samples
+	}
+	frames := len(samples) / 2
+	outFrames := int(int64(frames) * mixRate / int64(rate))
+	out := make([]int16, 2*outFrames)
+	for i := 0; i < outFrames; i++ {
+		pos := float64(i) * float64(rate) / mixRate
+		j := int(pos)
+		frac := pos - float64(j)
+		for c := 0; c < 2; c++ {
+			a := float64(samples[2*j+c])
+			b := a
+			if j+1 < frames {
+				b = float64(samples[2*(j+1)+c])
+			}
+			out[2*i+c] = int16(a + (b-a)*frac)
+		}
+	}
+	return out
+}
@@ -1,15 +1,15 @@
 package game
 
 import (
-	"github.com/nsf/termbox-go"
 	"math"
 	"math/rand"
+	"sort"
 )
 
 // IObstacle defines the interface for all obstacle types
 type IObstacle interface {
-	Update()
-	Draw()
+	Update(speed float64)
+	Draw(r Renderer)
 	GetPosition() (float64, int)
 	SetPosition(x float64, y int)
 	Reset()
@@ -26,9 +26,9 @@ type BaseObstacle struct {
 	obstacleType ObstacleType
 }
 
-// Update moves the obstacle and updates animation
-func (b *BaseObstacle) Update() {
-	b.posX -= obstacleSpeed
+// Update moves the obstacle by speed cells and updates animation
+func (b *BaseObstacle) Update(speed float64) {
+	b.posX -= speed
 	b.updateAnimation()
 }
 
@@ -81,17 +81,17 @@ func (c *Cactus) Reset() {
 }
 
 // Draw renders the cactus on screen
-func (c *Cactus) Draw() {
+func (c *Cactus) Draw(r Renderer) {
 	sprite := ObstacleFrames[c.obstacleType][c.animFrame]
-	h := len(sprite)
+	h := len(sprite.Glyphs)
 	startY := c.y - (h - 1)
 	x := int(math.Round(c.posX))
-	sprite.Draw(x, startY, termbox.ColorRed, termbox.ColorDefault)
+	drawSprite(r, sprite, x, startY, c.posX, float64(startY), palette.Cactus, palette.Background)
 }
 
 // GetSprite returns the current sprite for collision detection
 func (c *Cactus) GetSprite() Sprite {
-	return ObstacleFrames[c.obstacleType][c.animFrame]
+	return ObstacleFrames[c.obstacleType][c.animFrame].Shape()
 }
 
 // ShortCactus represents a short cactus obstacle
@@ -117,27 +117,28 @@ func (c *ShortCactus) Reset() {
 }
 
 // Draw renders the short cactus on screen
-func (c *ShortCactus) Draw() {
+func (c *ShortCactus) Draw(r Renderer) {
 	sprite := ObstacleFrames[c.obstacleType][c.animFrame]
-	h := len(sprite)
+	h := len(sprite.Glyphs)
 	startY := c.y - (h - 1)
 	x := int(math.Round(c.posX))
-	sprite.Draw(x, startY, termbox.ColorRed, termbox.ColorDefault)
+	drawSprite(r, sprite, x, startY, c.posX, float64(startY), palette.Cactus, palette.Background)
 }
 
 // GetSprite returns the current sprite for collision detection
 func (c *ShortCactus) GetSprite() Sprite {
-	return ObstacleFrames[c.obstacleType][c.animFrame]
+	return ObstacleFrames[c.obstacleType][c.animFrame].Shape()
 }
 
 // Bird represents a small bird obstacle
 type Bird struct {
 	BaseObstacle
+	rng *rand.Rand // picks the flight height on every reset
 }
 
 // NewBird creates a new bird obstacle
-func NewBird() *Bird {
-	b := &Bird{}
+func NewBird(rng *rand.Rand) *Bird {
+	b := &Bird{rng: rng}
 	b.obstacleType = BirdType
 	b.Reset()
 	return b
@@ -149,24 +150,24 @@ func (b *Bird) Reset() {
 	b.posX = effectiveWidth
 
 	// Randomly select one of the available flight heights
-	b.y = birdFlightRows[rand.Intn(len(birdFlightRows))]
+	b.y = birdFlightRows[b.rng.Intn(len(birdFlightRows))]
 
 	b.animFrame = 0
 	b.animCounter = 0
 }
 
 // Draw renders the bird on screen
-func (b *Bird) Draw() {
+func (b *Bird) Draw(r Renderer) {
 	sprite := ObstacleFrames[b.obstacleType][b.animFrame]
-	h := len(sprite)
+	h := len(sprite.Glyphs)
 	startY := b.y - (h - 1)
 	x := int(math.Round(b.posX))
-	sprite.Draw(x, startY, termbox.ColorYellow, termbox.ColorDefault)
+	drawSprite(r, sprite, x, startY, b.posX, float64(startY), palette.SmallBird, palette.Background)
 }
 
 // GetSprite returns the current sprite for collision detection
 func (b *Bird) GetSprite() Sprite {
-	return ObstacleFrames[b.obstacleType][b.animFrame]
+	return ObstacleFrames[b.obstacleType][b.animFrame].Shape()
 }
 
 // BigBird represents a large bird obstacle
@@ -192,17 +193,17 @@ func (b *BigBird) Reset() {
 }
 
 // Draw renders the big bird on screen
-func (b *BigBird) Draw() {
+func (b *BigBird) Draw(r Renderer) {
 	sprite := ObstacleFrames[b.obstacleType][b.animFrame]
-	h := len(sprite)
+	h := len(sprite.Glyphs)
 	startY := b.y - (h - 1)
 	x := int(math.Round(b.posX))
-	sprite.Draw(x, startY, termbox.ColorMagenta, termbox.ColorDefault)
+	drawSprite(r, sprite, x, startY, b.posX, float64(startY), palette.BigBird, palette.Background)
 }
 
 // GetSprite returns the current sprite for collision detection
 func (b *BigBird) GetSprite() Sprite {
-	return ObstacleFrames[b.obstacleType][b.animFrame]
+	return ObstacleFrames[b.obstacleType][b.animFrame].Shape()
 }
 
 // GroupCactus represents a group of connected cacti obstacle
@@ -228,17 +229,17 @@ func (c *GroupCactus) Reset() {
 }
 
 // Draw renders the group cactus on screen
-func (c *GroupCactus) Draw() {
+func (c *GroupCactus) Draw(r Renderer) {
 	sprite := ObstacleFrames[c.obstacleType][c.animFrame]
-	h := len(sprite)
+	h := len(sprite.Glyphs)
 	startY := c.y - (h - 1)
 	x := int(math.Round(c.posX))
-	sprite.Draw(x, startY, termbox.ColorRed, termbox.ColorDefault)
+	drawSprite(r, sprite, x, startY, c.posX, float64(startY), palette.Cactus, palette.Background)
 }
 
 // GetSprite returns the current sprite for collision detection
 func (c *GroupCactus) GetSprite() Sprite {
-	return ObstacleFrames[c.obstacleType][c.animFrame]
+	return ObstacleFrames[c.obstacleType][c.animFrame].Shape()
 }
 
 // ObstacleManager manages the creation and updating of obstacles
@@ -256,10 +257,12 @@ type ObstacleManager struct {
 	groupCactusRatio  float64 // 组合仙人掌在仙人掌类别中的占比
 	smallBirdRatio    float64 // 小鸟在鸟类别中的占比
 	bigBirdRatio      float64 // 大鸟在鸟类别中的占比
+
+	rng *rand.Rand // 障碍物生成使用的随机数源
 }
 
-// NewObstacleManager creates a new obstacle manager
-func NewObstacleManager() *ObstacleManager {
+// NewObstacleManager creates a new obstacle manager drawing all randomness from rng
+func NewObstacleManager(rng *rand.Rand) *ObstacleManager {
 	// 获取初始阶段的配置
 	initialStage := stageConfigs[0]
 
@@ -277,6 +280,8 @@ func NewObstacleManager() *ObstacleManager {
 		groupCactusRatio:  initialStage.GroupCactusRatio,
 		smallBirdRatio:    initialStage.SmallBirdRatio,
 		bigBirdRatio:      initialStage.BigBirdRatio,
+
+		rng: rng,
 	}
 
 	// 生成第一个障碍物
@@ -284,15 +289,16 @@ func NewObstacleManager() *ObstacleManager {
 	return om
 }
 
-// Update updates all obstacles and generates new ones if needed
-func (om *ObstacleManager) Update() {
+// Update moves all obstacles by speed cells and generates new ones if needed
+func (om *ObstacleManager) Update(speed float64) {
 	// 更新所有现有障碍物
 	for i := 0; i < len(om.obstacles); i++ {
-		om.obstacles[i].Update()
+		om.obstacles[i].Update(speed)
 
 		// 如果障碍物已经完全移出屏幕左侧，从列表中移除
+		// 使用-10确保障碍物完全离开屏幕；皮肤里更宽的障碍物等它整个离开
 		x, _ := om.obstacles[i].GetPosition()
-		if x < -10 { // 使用-10确保障碍物完全离开屏幕
+		if x < -float64(max(10, om.obstacles[i].GetSprite().Width())) {
 			// 移除障碍物（通过将最后一个元素移到当前位置，然后缩小切片）
 			om.obstacles[i] = om.obstacles[len(om.obstacles)-1]
 			om.obstacles = om.obstacles[:len(om.obstacles)-1]
@@ -328,7 +334,7 @@ func (om *ObstacleManager) Update() {
 			// 概率随着屏幕宽度增加而增加，但基于有效宽度
 			effectiveWidthFactor := effectiveWidth / 80.0
 			spawnChance := 0.1 * math.Min(effectiveWidthFactor, 3.0) // 最高30%概率
-			if rand.Float64() < spawnChance {
+			if om.rng.Float64() < spawnChance {
 				om.generateNewObstacle()
 			}
 		}
@@ -353,10 +359,37 @@ func (om *ObstacleManager) UpdateStageGaps(minGap, maxGap int, stageIndex int) {
 	}
 }
 
+// Resize moves obstacles beyond the new spawn edge back in after the screen
+// shrank from oldWidth. Their order is kept and they stay at least
+// resizeObstacleSpacing cells apart so no impossible combination appears.
+func (om *ObstacleManager) Resize(oldWidth int) {
+	oldEdge := math.Min(float64(oldWidth), float64(maxEffectiveWidth))
+	newEdge := math.Min(float64(width), float64(maxEffectiveWidth))
+	shift := oldEdge - newEdge
+	if shift <= 0 {
+		return
+	}
+
+	sort.Slice(om.obstacles, func(i, j int) bool {
+		xi, _ := om.obstacles[i].GetPosition()
+		xj, _ := om.obstacles[j].GetPosition()
+		return xi < xj
+	})
+	minX := math.Inf(-1)
+	for _, obs := range om.obstacles {
+		x, y := obs.GetPosition()
+		if x > newEdge {
+			x = math.Max(math.Max(newEdge, x-shift), minX)
+			obs.SetPosition(x, y)
+		}
+		minX = x + float64(obs.GetSprite().Width()+resizeObstacleSpacing)
+	}
+}
+
 // Draw renders all obstacles
-func (om *ObstacleManager) Draw() {
+func (om *ObstacleManager) Draw(r Renderer) {
 	for _, obstacle := range om.obstacles {
-		obstacle.Draw()
+		obstacle.Draw(r)
 	}
 }
 
@@ -370,12 +403,12 @@ func (om *ObstacleManager) generateNewObstacle() {
 	var newObstacle IObstacle
 
 	// 第一层概率：决定是仙人掌还是鸟类
-	r := rand.Float64()
+	r := om.rng.Float64()
 
 	if r < om.cactusProbability {
 		// 选择了仙人掌类别
 		// 第二层概率：决定是哪种仙人掌
-		cactusTypeRoll := rand.Float64()
+		cactusTypeRoll := om.rng.Float64()
 
 		// 计算累积概率
 		shortCactusCumulProb := om.shortCactusRatio
@@ -392,10 +425,10 @@ func (om *ObstacleManager) generateNewObstacle() {
 	} else {
 		// 选择了鸟类别
 		// 第二层概率：决定是小鸟还是大鸟
-		birdTypeRoll := rand.Float64()
+		birdTypeRoll := om.rng.Float64()
 
 		if birdTypeRoll < om.smallBirdRatio {
-			newObstacle = NewBird()
+			newObstacle = NewBird(om.rng)
 		} else {
 			newObstacle = NewBigBird()
 		}
@@ -424,7 +457,7 @@ func (om *ObstacleManager) generateNewObstacle() {
 
 	// Select a random gap value between min and max for current stage
 	gapRange := om.maxGap - om.minGap + 1
-	baseGap := om.minGap + rand.Intn(gapRange)
+	baseGap := om.minGap + om.rng.Intn(gapRange)
 
 	// Apply the multiplier to get final gap
 	finalGap := int(float64(baseGap) * gapMultiplier)
@@ -0,0 +1,112 @@
+package game
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// appDirName is the directory name used under the XDG base directories
+const appDirName = "term-rex"
+
+// dataDirOverride is set by --data-dir: config and data then both live in
+// that directory, so a whole profile can be carried around
+var dataDirOverride string
+
+// SetDataDir makes the game keep its config and data in dir
+func SetDataDir(dir string) {
+	dataDirOverride = dir
+}
+
+// DataDir returns the directory for scores and other data:
+// --data-dir, $XDG_DATA_HOME/term-rex, %LOCALAPPDATA%\term-rex on Windows,
+// or ~/.local/share/term-rex
+func DataDir() (string, error) {
+	if dataDirOverride != "" {
+		return dataDirOverride, nil
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appDirName), nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+			return filepath.Join(dir, appDirName), nil
+		}
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("无法获取用户主目录: %v", err)
+	}
+	return filepath.Join(homeDir, ".local", "share", appDirName), nil
+}
+
+// ConfigDir returns the directory of config.toml: --data-dir,
+// $XDG_CONFIG_HOME/term-rex, %APPDATA%\term-rex on Windows, or
+// ~/.config/term-rex
+func ConfigDir() (string, error) {
+	if dataDirOverride != "" {
+		return dataDirOverride, nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appDirName), nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("APPDATA"); dir != "" {
+			return filepath.Join(dir, appDirName), nil
+		}
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("无法获取用户主目录: %v", err)
+	}
+	return filepath.Join(homeDir, ".config", appDirName), nil
+}
+
+// legacyPath returns the path of a file the game used to keep directly in
+// the home directory. There is none for a --data-dir profile.
+func legacyPath(name string) (string, bool) {
+	if dataDirOverride != "" {
+		return "", false
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(homeDir, name), true
+}
+
+// findSoundsDir looks for the sound files in the data directory, next to
+// the executable (release archives, npm and Homebrew layouts) and finally
+// in assets/sounds below the working directory when running from the repo.
+// It returns "" if none of them has the sounds.
+func findSoundsDir() string {
+	var candidates []string
+	if dir, err := DataDir(); err == nil {
+		candidates = append(candidates, filepath.Join(dir, "sounds"))
+	}
+	if exe, err := os.Executable(); err == nil {
+		// npm 安装时 bin 目录下是符号链接，需要找到真实位置
+		if real, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = real
+		}
+		exeDir := filepath.Dir(exe)
+		candidates = append(candidates,
+			filepath.Join(exeDir, "assets", "sounds"),
+			filepath.Join(exeDir, "sounds"),
+			filepath.Join(exeDir, "..", "assets", "sounds"),
+			filepath.Join(exeDir, "..", "share", appDirName, "sounds"),
+		)
+	}
+	candidates = append(candidates, filepath.Join("assets", "sounds"))
+
+	for _, dir := range candidates {
+		if _, err := os.Stat(filepath.Join(dir, "jump.mp3")); err == nil {
+			if abs, err := filepath.Abs(dir); err == nil {
+				return abs
+			}
+			return dir
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package game
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDirs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("falls back to %APPDATA% and %LOCALAPPDATA% on Windows")
+	}
+	tests := []struct {
+		name       string
+		override   string
+		configHome string
+		dataHome   string
+		config     string // relative to the home directory unless absolute
+		data       string
+	}{
+		{
+			name:   "defaults",
+			config: ".config/term-rex",
+			data:   ".local/share/term-rex",
+		},
+		{
+			name:       "xdg",
+			configHome: "/xdg/config",
+			dataHome:   "/xdg/data",
+			config:     "/xdg/config/term-rex",
+			data:       "/xdg/data/term-rex",
+		},
+		{
+			name:       "relative xdg paths are ignored",
+			configHome: "config",
+			dataHome:   "data",
+			config:     ".config/term-rex",
+			data:       ".local/share/term-rex",
+		},
+		{
+			name:       "data dir wins over xdg",
+			override:   "/profile",
+			configHome: "/xdg/config",
+			dataHome:   "/xdg/data",
+			config:     "/profile",
+			data:       "/profile",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := useHome(t)
+			t.Setenv("XDG_CONFIG_HOME", tt.configHome)
+			t.Setenv("XDG_DATA_HOME", tt.dataHome)
+			SetDataDir(tt.override)
+			t.Cleanup(func() { SetDataDir("") })
+
+			abs := func(p string) string {
+				if filepath.IsAbs(p) {
+					return filepath.FromSlash(p)
+				}
+				return filepath.Join(home, p)
+			}
+			if dir, err := ConfigDir(); err != nil || dir != abs(tt.config) {
+				t.Errorf("ConfigDir() = %q, %v, want %q", dir, err, abs(tt.config))
+			}
+			if dir, err := DataDir(); err != nil || dir != abs(tt.data) {
+				t.Errorf("DataDir() = %q, %v, want %q", dir, err, abs(tt.data))
+			}
+		})
+	}
+}
+
+func TestLegacyPath(t *testing.T) {
+	home := useHome(t)
+	if path, ok := legacyPath(legacyHighScoreFileName); !ok || path != filepath.Join(home, legacyHighScoreFileName) {
+		t.Errorf("legacyPath = %q, %v", path, ok)
+	}
+
+	// --data-dir 的数据不会和主目录中的旧文件混在一起
+	SetDataDir(t.TempDir())
+	t.Cleanup(func() { SetDataDir("") })
+	if path, ok := legacyPath(legacyHighScoreFileName); ok {
+		t.Errorf("legacyPath = %q with --data-dir", path)
+	}
+}
@@ -1,9 +1,33 @@
 package game
 
-import (
-	"github.com/nsf/termbox-go"
-	"math/rand"
-)
+import "github.com/nsf/termbox-go"
+
+// Renderer is the drawing surface the game renders into.
+// The termbox backend is TermboxRenderer; other frontends (tests, bots,
+// alternative UIs) can provide their own implementation.
+type Renderer interface {
+	Clear()
+	SetCell(x, y int, ch rune, fg, bg termbox.Attribute)
+	Flush()
+}
+
+// TermboxRenderer draws directly to the terminal through termbox
+type TermboxRenderer struct{}
+
+// Clear fills the terminal with the theme's background
+func (TermboxRenderer) Clear() {
+	termbox.Clear(termboxColor(palette.Text, true), termboxColor(palette.Background, false))
+}
+
+// SetCell sets a single terminal cell
+func (TermboxRenderer) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	termbox.SetCell(x, y, ch, termboxColor(fg, true), termboxColor(bg, false))
+}
+
+// Flush pushes the back buffer to the terminal
+func (TermboxRenderer) Flush() {
+	termbox.Flush()
+}
 
 // Ground decoration types
 type GroundDecoration struct {
@@ -17,22 +41,16 @@ type GroundLineChar struct {
 	char rune
 }
 
-// Collection of ground decorations
-var groundDecorations []GroundDecoration
-
-// 地面线字符集合
-var groundLineChars []GroundLineChar
-
-// Initialize ground decorations
-func InitGroundDecorations() {
-	groundDecorations = make([]GroundDecoration, 0)
-	groundLineChars = make([]GroundLineChar, 0)
+// initGroundDecorations fills the ground line and decorations for the current width
+func (s *Simulation) initGroundDecorations() {
+	s.groundDecorations = make([]GroundDecoration, 0)
+	s.groundLineChars = make([]GroundLineChar, 0)
 
 	// Add random decorations across the ground
-	for x := 0; x < width*2; x += 2 + rand.Intn(5) { // 生成更多装饰，以便滚动时有足够的装饰
+	for x := 0; x < width*2; x += 2 + s.groundRng.Intn(5) { // 生成更多装饰，以便滚动时有足够的装饰
 		// Choose a decoration character
 		var char rune
-		switch rand.Intn(5) {
+		switch s.groundRng.Intn(5) {
 		case 0:
 			char = '.'
 		case 1:
@@ -45,7 +63,7 @@ func InitGroundDecorations() {
 			char = '-'
 		}
 
-		groundDecorations = append(groundDecorations, GroundDecoration{
+		s.groundDecorations = append(s.groundDecorations, GroundDecoration{
 			x:    float64(x),
 			char: char,
 		})
@@ -54,20 +72,20 @@ func InitGroundDecorations() {
 	// 初始化地面线字符 - 以较大间隔放置特殊字符
 	// 首先用下划线填充整个地面
 	for x := 0; x < width*2; x++ {
-		groundLineChars = append(groundLineChars, GroundLineChar{
+		s.groundLineChars = append(s.groundLineChars, GroundLineChar{
 			x:    float64(x),
 			char: '_', // 默认全部使用下划线
 		})
 	}
 
 	// 然后在较大间隔处放置特殊字符
-	minInterval := 200              // 最小间隔距离
-	nextSpecialPos := rand.Intn(50) // 第一个特殊字符的位置（随机起点）
+	minInterval := 200                     // 最小间隔距离
+	nextSpecialPos := s.groundRng.Intn(50) // 第一个特殊字符的位置（随机起点）
 
 	for nextSpecialPos < width*2 {
 		// 选择一个特殊字符
 		var specialChar rune
-		switch rand.Intn(4) {
+		switch s.groundRng.Intn(4) {
 		case 0:
 			specialChar = '='
 		case 1:
@@ -79,31 +97,78 @@ func InitGroundDecorations() {
 		}
 
 		// 在特定位置放置特殊字符
-		if nextSpecialPos < len(groundLineChars) {
-			groundLineChars[nextSpecialPos].char = specialChar
+		if nextSpecialPos < len(s.groundLineChars) {
+			s.groundLineChars[nextSpecialPos].char = specialChar
 		}
 
 		// 计算下一个特殊字符的位置
 		// 最小间隔为minInterval，再加上一些随机变化
-		nextSpecialPos += minInterval + rand.Intn(100)
+		nextSpecialPos += minInterval + s.groundRng.Intn(100)
 	}
 }
 
-// ClearScreen clears the terminal
-func ClearScreen() {
-	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+// resizeGround regenerates the ground for the current width after a resize
+// from oldWidth. Everything that was visible on both sizes keeps its
+// character and sub-cell offset, so the ground does not jump.
+func (s *Simulation) resizeGround(oldWidth int) {
+	keep := oldWidth
+	if width < keep {
+		keep = width
+	}
+
+	// 记录当前可见部分的地面字符和装饰（按屏幕列）
+	frac := 0.0
+	if len(s.groundLineChars) > 0 {
+		x := s.groundLineChars[0].x
+		frac = x - float64(int(x))
+	}
+	visibleLine := make(map[int]rune)
+	for _, lineChar := range s.groundLineChars {
+		intX := int(lineChar.x) % (oldWidth * 2)
+		if intX >= 0 && intX < keep {
+			visibleLine[intX] = lineChar.char
+		}
+	}
+	var visibleDecorations []GroundDecoration
+	for _, decoration := range s.groundDecorations {
+		intX := int(decoration.x) % (oldWidth * 2)
+		if intX >= 0 && intX < keep {
+			visibleDecorations = append(visibleDecorations, GroundDecoration{
+				x:    float64(intX) + frac,
+				char: decoration.char,
+			})
+		}
+	}
+
+	// 按新宽度重新生成，然后用原来可见的部分覆盖左侧
+	s.initGroundDecorations()
+	for i := range s.groundLineChars {
+		col := int(s.groundLineChars[i].x)
+		if ch, ok := visibleLine[col]; ok {
+			s.groundLineChars[i].char = ch
+		}
+		s.groundLineChars[i].x += frac
+	}
+	decorations := visibleDecorations
+	for _, decoration := range s.groundDecorations {
+		if int(decoration.x) >= keep {
+			decoration.x += frac
+			decorations = append(decorations, decoration)
+		}
+	}
+	s.groundDecorations = decorations
 }
 
-// DrawGround draws the ground line with decorations
-func DrawGround() {
+// drawGround draws the ground line with decorations
+func (s *Simulation) drawGround(r Renderer) {
 	// Draw the main ground line using varied characters
 	for x := 0; x < width; x++ {
 		// 查找对应位置的地面线字符
 		found := false
-		for _, lineChar := range groundLineChars {
+		for _, lineChar := range s.groundLineChars {
 			intX := int(lineChar.x) % (width * 2)
 			if intX == x {
-				termbox.SetCell(x, height-1, lineChar.char, termbox.ColorWhite, termbox.ColorDefault)
+				r.SetCell(x, height-1, lineChar.char, palette.Ground, palette.Background)
 				found = true
 				break
 			}
@@ -111,29 +176,29 @@ func DrawGround() {
 
 		// 如果没有找到对应的特殊字符，使用默认的下划线
 		if !found {
-			termbox.SetCell(x, height-1, '_', termbox.ColorWhite, termbox.ColorDefault)
+			r.SetCell(x, height-1, '_', palette.Ground, palette.Background)
 		}
 	}
 
 	// Draw decorations below the ground
-	for _, decoration := range groundDecorations {
+	for _, decoration := range s.groundDecorations {
 		intX := int(decoration.x) % (width * 2) // 使用取模运算使装饰在屏幕范围内循环
 		if intX < width {
-			termbox.SetCell(intX, height, decoration.char, termbox.ColorWhite, termbox.ColorDefault)
+			r.SetCell(intX, height, decoration.char, palette.Decoration, palette.Background)
 		}
 	}
 }
 
 // drawGroundPartial draws ground between current Game boundaries with decorations
-func (g *Game) drawGroundPartial() {
+func (s *Simulation) drawGroundPartial(r Renderer) {
 	// Draw the main ground line using varied characters
-	for x := g.groundStart; x <= g.groundEnd; x++ {
+	for x := s.groundStart; x <= s.groundEnd; x++ {
 		// 查找对应位置的地面线字符
 		found := false
-		for _, lineChar := range groundLineChars {
+		for _, lineChar := range s.groundLineChars {
 			intX := int(lineChar.x) % (width * 2)
 			if intX == x {
-				termbox.SetCell(x, height-1, lineChar.char, termbox.ColorWhite, termbox.ColorDefault)
+				r.SetCell(x, height-1, lineChar.char, palette.Ground, palette.Background)
 				found = true
 				break
 			}
@@ -141,39 +206,39 @@ func (g *Game) drawGroundPartial() {
 
 		// 如果没有找到对应的特殊字符，使用默认的下划线
 		if !found {
-			termbox.SetCell(x, height-1, '_', termbox.ColorWhite, termbox.ColorDefault)
+			r.SetCell(x, height-1, '_', palette.Ground, palette.Background)
 		}
 	}
 
 	// Draw decorations below the ground
-	for _, decoration := range groundDecorations {
+	for _, decoration := range s.groundDecorations {
 		intX := int(decoration.x) % (width * 2) // 使用取模运算使装饰在屏幕范围内循环
-		if intX >= g.groundStart && intX <= g.groundEnd {
-			termbox.SetCell(intX, height, decoration.char, termbox.ColorWhite, termbox.ColorDefault)
+		if intX >= s.groundStart && intX <= s.groundEnd {
+			r.SetCell(intX, height, decoration.char, palette.Decoration, palette.Background)
 		}
 	}
 }
 
 // PrintCenter prints a message at center of screen
-func PrintCenter(msg string) {
+func PrintCenter(r Renderer, msg string) {
 	x := (width - len(msg)) / 2
 	y := height / 2
 	for i, c := range msg {
-		termbox.SetCell(x+i, y, c, termbox.ColorWhite, termbox.ColorDefault)
+		r.SetCell(x+i, y, c, palette.Text, palette.Background)
 	}
 }
 
 // PrintCenterAt prints a message centered horizontally at the specified row
-func PrintCenterAt(msg string, row int) {
+func PrintCenterAt(r Renderer, msg string, row int) {
 	x := (width - len(msg)) / 2
 	for i, c := range msg {
-		termbox.SetCell(x+i, row, c, termbox.ColorWhite, termbox.ColorDefault)
+		r.SetCell(x+i, row, c, palette.Text, palette.Background)
 	}
 }
 
 // PrintAt prints a message at the specified coordinates.
-func PrintAt(x, y int, msg string) {
+func PrintAt(r Renderer, x, y int, msg string) {
 	for i, ch := range msg {
-		termbox.SetCell(x+i, y, ch, termbox.ColorWhite, termbox.ColorDefault)
+		r.SetCell(x+i, y, ch, palette.Text, palette.Background)
 	}
 }
@@ -0,0 +1,375 @@
+package game
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplayVersion is the version of the replay file format written by Recorder
+const ReplayVersion = 1
+
+// replayMagic is the first word of every replay file
+const replayMagic = "term-rex-replay"
+
+// maxReplayIdle is how long a recording may go on after its last action,
+// like a game left paused; a later end tick is taken as corrupt
+const maxReplayIdle = time.Hour
+
+// RecordedAction is an action applied on a specific simulation tick
+type RecordedAction struct {
+	Tick   int
+	Action Action
+}
+
+// RecordedResize is a change of the play-field width before a specific tick
+type RecordedResize struct {
+	Tick  int
+	Width int
+}
+
+// Replay is a recorded session: the seed, the play-field size, every
+// action the player made and the score the run ended with.
+//
+// The file format is line based:
+//
+//	term-rex-replay 1
+//	seed 42
+//	width 80
+//	height 15
+//	tuning 3f2a9c0d81b4e657
+//	120 jump
+//	185 duck
+//	190 duck-release
+//	400 resize 100
+//	end 734 312
+//
+// where the last line holds the final tick and the claimed score.
+// Resizes on a tick are applied before the actions of that tick.
+type Replay struct {
+	Version int
+	Seed    int64
+	Width   int
+	Height  int
+	Tuning  string // TuningFingerprint of the settings the run was recorded with
+	Actions []RecordedAction
+	Resizes []RecordedResize
+	EndTick int
+	Score   int
+}
+
+// parseAction converts an action name back into an Action
+func parseAction(name string) (Action, bool) {
+	for a := ActionJump; a <= ActionRestart; a++ {
+		if a.String() == name {
+			return a, true
+		}
+	}
+	return 0, false
+}
+
+// ReadReplay parses a replay file
+func ReadReplay(r io.Reader) (*Replay, error) {
+	rp := &Replay{Width: 80, Height: defaultHeight, Tuning: defaultTuning}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	lastTick := 0
+	hasSeed := false
+	ended := false
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if ended {
+			return nil, fmt.Errorf("line %d: data after end record", lineNo)
+		}
+		fields := strings.Fields(line)
+
+		if rp.Version == 0 {
+			if len(fields) != 2 || fields[0] != replayMagic {
+				return nil, fmt.Errorf("line %d: not a term-rex replay file", lineNo)
+			}
+			v, err := strconv.Atoi(fields[1])
+			if err != nil || v != ReplayVersion {
+				return nil, fmt.Errorf("line %d: unsupported replay version %q", lineNo, fields[1])
+			}
+			rp.Version = v
+			continue
+		}
+
+		switch fields[0] {
+		case "seed":
+			if len(fields) != 2 || hasSeed {
+				return nil, fmt.Errorf("line %d: malformed seed record", lineNo)
+			}
+			seed, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid seed %q", lineNo, fields[1])
+			}
+			rp.Seed = seed
+			hasSeed = true
+		case "width":
+			w, err := parseReplayWidth(fields)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: malformed width record", lineNo)
+			}
+			rp.Width = w
+		case "height":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: malformed height record", lineNo)
+			}
+			h, err := strconv.Atoi(fields[1])
+			if err != nil || h < defaultHeight || h > maxHeight {
+				return nil, fmt.Errorf("line %d: invalid height %q", lineNo, fields[1])
+			}
+			rp.Height = h
+		case "tuning":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: malformed tuning record", lineNo)
+			}
+			rp.Tuning = fields[1]
+		case "end":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("line %d: malformed end record", lineNo)
+			}
+			endTick, err1 := strconv.Atoi(fields[1])
+			score, err2 := strconv.Atoi(fields[2])
+			if err1 != nil || err2 != nil || endTick < lastTick || score < 0 {
+				return nil, fmt.Errorf("line %d: malformed end record", lineNo)
+			}
+			if endTick-lastTick > ticksFor(maxReplayIdle) {
+				return nil, fmt.Errorf("line %d: end tick %d is more than %v after the last record", lineNo, endTick, maxReplayIdle)
+			}
+			rp.EndTick = endTick
+			rp.Score = score
+			ended = true
+		default:
+			if !hasSeed {
+				return nil, fmt.Errorf("line %d: missing seed", lineNo)
+			}
+			tick, err := strconv.Atoi(fields[0])
+			if err != nil || tick < 0 {
+				return nil, fmt.Errorf("line %d: invalid tick %q", lineNo, fields[0])
+			}
+			if tick < lastTick {
+				return nil, fmt.Errorf("line %d: ticks out of order", lineNo)
+			}
+			lastTick = tick
+			if len(fields) == 3 && fields[1] == "resize" {
+				w, err := parseReplayWidth(fields[1:])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: malformed resize record", lineNo)
+				}
+				rp.Resizes = append(rp.Resizes, RecordedResize{Tick: tick, Width: w})
+				continue
+			}
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: malformed action record", lineNo)
+			}
+			a, ok := parseAction(fields[1])
+			if !ok {
+				return nil, fmt.Errorf("line %d: unknown action %q", lineNo, fields[1])
+			}
+			rp.Actions = append(rp.Actions, RecordedAction{Tick: tick, Action: a})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if rp.Version == 0 {
+		return nil, fmt.Errorf("empty replay file")
+	}
+	if !hasSeed {
+		return nil, fmt.Errorf("missing seed")
+	}
+	if !ended {
+		return nil, fmt.Errorf("missing end record")
+	}
+	return rp, nil
+}
+
+// parseReplayWidth parses a "width N" or "resize N" pair
+func parseReplayWidth(fields []string) (int, error) {
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("expected a single width")
+	}
+	w, err := strconv.Atoi(fields[1])
+	if err != nil || w < minWidth {
+		return 0, fmt.Errorf("invalid width %q", fields[1])
+	}
+	return w, nil
+}
+
+// LoadReplay reads a replay file from disk
+func LoadReplay(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rp, err := ReadReplay(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return rp, nil
+}
+
+// Write encodes the replay in the file format described on Replay
+func (rp *Replay) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s %d\n", replayMagic, ReplayVersion)
+	fmt.Fprintf(bw, "seed %d\n", rp.Seed)
+	fmt.Fprintf(bw, "width %d\n", rp.Width)
+	fmt.Fprintf(bw, "height %d\n", rp.Height)
+	fmt.Fprintf(bw, "tuning %s\n", rp.Tuning)
+	// 按 tick 顺序交错写入尺寸变化和动作，同一 tick 的尺寸变化在前
+	ri := 0
+	for _, ra := range rp.Actions {
+		for ri < len(rp.Resizes) && rp.Resizes[ri].Tick <= ra.Tick {
+			fmt.Fprintf(bw, "%d resize %d\n", rp.Resizes[ri].Tick, rp.Resizes[ri].Width)
+			ri++
+		}
+		fmt.Fprintf(bw, "%d %s\n", ra.Tick, ra.Action)
+	}
+	for ; ri < len(rp.Resizes); ri++ {
+		fmt.Fprintf(bw, "%d resize %d\n", rp.Resizes[ri].Tick, rp.Resizes[ri].Width)
+	}
+	fmt.Fprintf(bw, "end %d %d\n", rp.EndTick, rp.Score)
+	return bw.Flush()
+}
+
+// Save writes the replay to path
+func (rp *Replay) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := rp.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Recorder collects the actions handled during a live session
+type Recorder struct {
+	path   string
+	replay Replay
+}
+
+// NewRecorder creates a recorder for a session started with seed on a
+// play field of w by h cells, saving to path
+func NewRecorder(path string, seed int64, w, h int) *Recorder {
+	return &Recorder{
+		path:   path,
+		replay: Replay{Version: ReplayVersion, Seed: seed, Width: w, Height: h, Tuning: TuningFingerprint()},
+	}
+}
+
+// Record stores the actions about to be applied on tick
+func (rec *Recorder) Record(tick int, actions []Action) {
+	for _, a := range actions {
+		rec.replay.Actions = append(rec.replay.Actions, RecordedAction{Tick: tick, Action: a})
+	}
+}
+
+// RecordResize stores a play-field width change applied before tick
+func (rec *Recorder) RecordResize(tick int, w int) {
+	rec.replay.Resizes = append(rec.replay.Resizes, RecordedResize{Tick: tick, Width: w})
+}
+
+// Save writes everything recorded so far together with the final state
+func (rec *Recorder) Save(state State) error {
+	rec.replay.EndTick = state.Tick
+	rec.replay.Score = state.Score
+	return rec.replay.Save(rec.path)
+}
+
+// replayPlayer feeds recorded actions back to the simulation tick by tick
+type replayPlayer struct {
+	replay     *Replay
+	next       int // index of the next action to play
+	nextResize int // index of the next resize to play
+}
+
+// resizeAt applies the recorded width changes for tick to the simulation
+func (p *replayPlayer) resizeAt(sim *Simulation, tick int) {
+	for p.nextResize < len(p.replay.Resizes) && p.replay.Resizes[p.nextResize].Tick <= tick {
+		sim.Resize(p.replay.Resizes[p.nextResize].Width)
+		p.nextResize++
+	}
+}
+
+// actionsAt returns the recorded actions for tick
+func (p *replayPlayer) actionsAt(tick int) []Action {
+	var actions []Action
+	for p.next < len(p.replay.Actions) && p.replay.Actions[p.next].Tick <= tick {
+		actions = append(actions, p.replay.Actions[p.next].Action)
+		p.next++
+	}
+	return actions
+}
+
+// finished reports whether the replay reached its end tick
+func (p *replayPlayer) finished(tick int) bool {
+	return p.next >= len(p.replay.Actions) && tick >= p.replay.EndTick
+}
+
+// CheckTuning returns an error when the replay was recorded with different
+// tunables (physics, clouds or stages) than the ones currently loaded
+func (rp *Replay) CheckTuning() error {
+	if rp.Tuning != TuningFingerprint() {
+		return fmt.Errorf("recorded with different game settings (tuning %s, current %s)", rp.Tuning, TuningFingerprint())
+	}
+	return nil
+}
+
+// VerifyResult is the outcome of re-simulating a replay
+type VerifyResult struct {
+	Claimed int // score stored in the replay file
+	Actual  int // score reached by re-simulating the recorded actions
+	Ticks   int // number of ticks simulated
+}
+
+// Valid reports whether the replayed score matches the claimed one
+func (vr VerifyResult) Valid() bool {
+	return vr.Claimed == vr.Actual
+}
+
+// VerifyReplay re-simulates a replay headlessly at full speed with the same
+// game logic as a live run. An error means the recording is inconsistent
+// (for example it keeps sending input after the run ended without a restart).
+func VerifyReplay(rp *Replay) (VerifyResult, error) {
+	if err := rp.CheckTuning(); err != nil {
+		return VerifyResult{}, err
+	}
+
+	// 游戏尺寸是全局状态，校验结束后恢复
+	defer SetWidth(width)
+	defer SetHeight(height)
+	SetWidth(rp.Width)
+	SetHeight(rp.Height)
+
+	sim := NewSimulation(rp.Seed)
+	player := &replayPlayer{replay: rp}
+	state := sim.State()
+	for state.Tick < rp.EndTick {
+		player.resizeAt(sim, state.Tick)
+		actions := player.actionsAt(state.Tick)
+		// 撞上之后模拟停在这一 tick，没有重新开始就不会再前进
+		if state.GameOver && !containsAction(actions, ActionRestart) {
+			return VerifyResult{}, fmt.Errorf("run ended at tick %d but the recording continues to tick %d", state.Tick, rp.EndTick)
+		}
+		state = sim.Step(actions)
+	}
+	if player.next < len(rp.Actions) {
+		return VerifyResult{}, fmt.Errorf("%d actions left after end tick %d", len(rp.Actions)-player.next, rp.EndTick)
+	}
+	return VerifyResult{Claimed: rp.Score, Actual: state.Score, Ticks: state.Tick}, nil
+}
@@ -0,0 +1,191 @@
+package game
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordSession lets the bot play for the given number of steps while a
+// Recorder records it, changing the width on the way (step → width), and
+// returns the recorder and the final state
+func recordSession(t *testing.T, seed int64, steps int, resizes map[int]int) (*Recorder, State) {
+	t.Helper()
+	defer SetWidth(width)
+	rec := NewRecorder(filepath.Join(t.TempDir(), "session.rec"), seed, width, height)
+	s := NewSimulation(seed)
+	st := s.State()
+	for i := 0; i < steps; i++ {
+		if w, ok := resizes[i]; ok {
+			rec.RecordResize(s.tick, w)
+			s.Resize(w)
+		}
+		actions := botActions(s)
+		rec.Record(s.tick, actions)
+		st = s.Step(actions)
+	}
+	if err := rec.Save(st); err != nil {
+		t.Fatal(err)
+	}
+	return rec, st
+}
+
+// playReplay feeds a replay into a new simulation up to its end tick
+func playReplay(rp *Replay) State {
+	defer SetWidth(width)
+	defer SetHeight(height)
+	SetWidth(rp.Width)
+	SetHeight(rp.Height)
+	s := NewSimulation(rp.Seed)
+	player := &replayPlayer{replay: rp}
+	st := s.State()
+	for st.Tick < rp.EndTick {
+		player.resizeAt(s, st.Tick)
+		st = s.Step(player.actionsAt(st.Tick))
+	}
+	return st
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		seed    int64
+		steps   int
+		resizes map[int]int
+		height  int // play-field height, 0 for the default
+	}{
+		{name: "start screen only", seed: 3, steps: 10},
+		{name: "one run", seed: 42, steps: 2000},
+		{name: "restarts", seed: 7, steps: 15000},
+		{name: "resizes", seed: 1, steps: 8000, resizes: map[int]int{0: 100, 500: minWidth, 3000: 120}},
+		{name: "tall field", seed: 5, steps: 8000, height: 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.height != 0 {
+				SetHeight(tt.height)
+			}
+			rec, st := recordSession(t, tt.seed, tt.steps, tt.resizes)
+			SetHeight(defaultHeight)
+			rp, err := LoadReplay(rec.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*rp, rec.replay) {
+				t.Fatalf("read back %+v, recorded %+v", *rp, rec.replay)
+			}
+			if got := playReplay(rp); got.Score != st.Score || got.Tick != st.Tick {
+				t.Errorf("played back score %d after %d ticks, recorded %d after %d", got.Score, got.Tick, st.Score, st.Tick)
+			}
+			if res, err := VerifyReplay(rp); err != nil || !res.Valid() {
+				t.Errorf("verified %+v, %v", res, err)
+			}
+			if width != 80 || height != defaultHeight {
+				t.Errorf("the replay left the field at %dx%d", width, height)
+			}
+		})
+	}
+}
+
+func TestReadReplayErrors(t *testing.T) {
+	header := "term-rex-replay 1\nseed 1\nwidth 80\nheight 15\ntuning " + TuningFingerprint() + "\n"
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"empty", "", "empty replay file"},
+		{"not a replay", "hello 1\n", "not a term-rex replay file"},
+		{"future version", "term-rex-replay 99\n", "unsupported replay version"},
+		{"missing seed", "term-rex-replay 1\n0 jump\n", "missing seed"},
+		{"invalid seed", "term-rex-replay 1\nseed x\n", "invalid seed"},
+		{"duplicate seed", header + "seed 2\n", "malformed seed record"},
+		{"narrow width", header + "width 10\n", "malformed width record"},
+		{"height too small", header + "height 3\n", "invalid height"},
+		{"height too large", header + "height 41\n", "invalid height"},
+		{"malformed tuning", header + "tuning a b\n", "malformed tuning record"},
+		{"narrow resize", header + "3 resize 10\nend 10 0\n", "malformed resize record"},
+		{"resize out of order", header + "10 jump\n5 resize 90\nend 10 0\n", "ticks out of order"},
+		{"unknown action", header + "0 fly\nend 1 0\n", `unknown action "fly"`},
+		{"ticks out of order", header + "10 jump\n5 jump\nend 10 0\n", "ticks out of order"},
+		{"negative tick", header + "-1 jump\nend 10 0\n", "invalid tick"},
+		{"malformed action", header + "3 jump now\nend 10 0\n", "malformed action record"},
+		{"end before last action", header + "10 jump\nend 5 0\n", "malformed end record"},
+		{"negative score", header + "end 5 -1\n", "malformed end record"},
+		{"data after end", header + "end 5 0\n6 jump\n", "data after end record"},
+		{"missing end", header + "0 jump\n", "missing end record"},
+		{"end far after last action", header + "10 jump\nend " + fmt.Sprint(10+ticksFor(maxReplayIdle)+1) + " 0\n", "after the last record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadReplay(strings.NewReader(tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	rp, err := ReadReplay(strings.NewReader(header + "10 jump\nend " + fmt.Sprint(10+ticksFor(maxReplayIdle)) + " 0\n"))
+	if err != nil {
+		t.Errorf("a recording paused for %v: %v", maxReplayIdle, err)
+	} else if rp.EndTick != 10+ticksFor(maxReplayIdle) {
+		t.Errorf("end tick %d", rp.EndTick)
+	}
+}
+
+func TestVerifyReplay(t *testing.T) {
+	rec, st := recordSession(t, 42, 20000, nil)
+	valid := rec.replay
+	if len(valid.Actions) == 0 || st.Score == 0 {
+		t.Fatal("the bot did not play")
+	}
+
+	// 第一次撞上的 tick：此后不重新开始的输入都是伪造的
+	over, _ := playRun(NewSimulation(valid.Seed))
+
+	tests := []struct {
+		name    string
+		modify  func(rp *Replay)
+		invalid bool   // the score does not match
+		err     string // VerifyReplay fails
+	}{
+		{name: "valid", modify: func(rp *Replay) {}},
+		{name: "claimed score", modify: func(rp *Replay) { rp.Score++ }, invalid: true},
+		{name: "other seed", modify: func(rp *Replay) { rp.Seed++ }, err: "run ended at tick"},
+		{name: "other tuning", modify: func(rp *Replay) { rp.Tuning = "0000000000000000" }, err: "different game settings"},
+		{name: "input after game over", modify: func(rp *Replay) {
+			var actions []RecordedAction
+			for _, ra := range rp.Actions {
+				if ra.Tick < over.Tick {
+					actions = append(actions, ra)
+				}
+			}
+			rp.Actions = append(actions, RecordedAction{Tick: over.Tick, Action: ActionJump})
+			rp.EndTick = over.Tick + 100
+		}, err: "run ended at tick"},
+		{name: "actions after the end", modify: func(rp *Replay) {
+			rp.Actions = append(rp.Actions[:len(rp.Actions):len(rp.Actions)], RecordedAction{Tick: rp.EndTick + 1, Action: ActionJump})
+		}, err: "actions left after end tick"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp := valid
+			tt.modify(&rp)
+			res, err := VerifyReplay(&rp)
+			switch {
+			case tt.err != "":
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error %v, want %q", err, tt.err)
+				}
+			case err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case res.Valid() == tt.invalid:
+				t.Errorf("valid %v (score %d, claimed %d), want %v", res.Valid(), res.Actual, res.Claimed, !tt.invalid)
+			case res.Ticks != st.Tick:
+				t.Errorf("verified %d ticks, recorded %d", res.Ticks, st.Tick)
+			}
+		})
+	}
+}
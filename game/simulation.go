@@ -0,0 +1,367 @@
+package game
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// State is a snapshot of the simulation returned after every tick
+type State struct {
+	Tick     int      // number of ticks simulated so far
+	Score    int      // current score
+	Stage    int      // index of the active stage in stageConfigs
+	Speed    float64  // obstacle speed in cells per tick, follows the stage
+	RunTicks int      // ticks the current run has been played, without pauses
+	Started  bool     // the run has started
+	Paused   bool     // the run is paused
+	GameOver bool     // the dino hit an obstacle on this tick
+	Sounds   []string // sound events (Sound*) produced on this tick
+}
+
+// Simulation moves the game world forward one tick at a time.
+// It never touches the terminal, so it can be driven by tests, bots and
+// any frontend that implements Renderer.
+type Simulation struct {
+	seed      int64      // 本局的种子，用它重新开始可以复现这一局的障碍物
+	runSeeds  *rand.Rand // 依次给出之后每一局的种子
+	rng       *rand.Rand // 障碍物的随机数源，只在游戏进行时前进
+	cloudRng  *rand.Rand // 云的随机数源，开始前和暂停时也在前进
+	groundRng *rand.Rand // 地面装饰的随机数源
+
+	dino            *Dino
+	obstacleManager *ObstacleManager
+	cloudManager    *CloudManager
+
+	groundDecorations []GroundDecoration // 地面下方的装饰
+	groundLineChars   []GroundLineChar   // 地面线字符
+
+	tick                     int
+	runTicks                 int     // 本局实际进行的 tick 数（不含暂停）
+	speed                    float64 // 障碍物每帧移动的格数
+	score                    int
+	frameCounter             int // 用于控制积分累计速度的帧计数器
+	lastScoreMilestone       int
+	groundStart              int
+	groundEnd                int
+	groundSpecialCharCounter int // 用于控制特殊地面字符的添加频率
+	started                  bool
+	pause                    bool
+	groundExtending          bool
+	collided                 bool
+	downKeyHeld              bool
+	stageIndexActive         int
+	stageIndexTarget         int
+	stageTransitionStart     int  // 阶段过渡开始的 tick
+	scoreBlinking            bool // 标记分数是否正在闪烁
+	scoreBlinkStart          int  // 分数开始闪烁的 tick
+	scoreBlinkVisible        bool // 控制分数闪烁的显示/隐藏状态
+	lastBlinkToggle          int  // 上次闪烁状态切换的 tick
+	night                    bool // 天黑了（或正在变黑）
+	dayNightCount            int  // 已经过了几个 dayNightInterval
+	dayNightSwitch           int  // 最近一次昼夜交替开始的 tick
+
+	sounds []string // 当前 tick 产生的音效
+}
+
+// NewSimulation creates a simulation showing the start screen.
+// The same seed and the same actions always produce the same run.
+// Obstacles, clouds and the ground each have their own random source, so
+// how long the start screen is shown does not change the obstacles, and
+// every restart begins a run with a new seed.
+func NewSimulation(seed int64) *Simulation {
+	obstacles, clouds, ground, runs := worldRands(seed)
+	s := &Simulation{
+		seed:              seed,
+		runSeeds:          runs,
+		rng:               obstacles,
+		cloudRng:          clouds,
+		groundRng:         ground,
+		dino:              NewDino(),
+		obstacleManager:   NewObstacleManager(obstacles),
+		cloudManager:      NewCloudManager(clouds),
+		speed:             stageConfigs[0].Speed * speedFactor,
+		scoreBlinkVisible: true,
+	}
+	s.resetGroundBounds()
+	s.initGroundDecorations()
+	return s
+}
+
+// worldRands derives the random sources of obstacles, clouds, the ground
+// and the seeds of later runs from seed
+func worldRands(seed int64) (obstacles, clouds, ground, runs *rand.Rand) {
+	src := rand.New(rand.NewSource(seed))
+	next := func() *rand.Rand {
+		return rand.New(rand.NewSource(src.Int63()))
+	}
+	return next(), next(), next(), next()
+}
+
+// Seed returns the seed of the current run. Starting a simulation with it
+// brings up the same obstacles again.
+func (s *Simulation) Seed() int64 {
+	return s.seed
+}
+
+// ticksFor converts a wall-clock duration into a number of ticks
+func ticksFor(d time.Duration) int {
+	return int(d / tickDuration)
+}
+
+// resetGroundBounds calculates the initial ground boundaries around the dino
+func (s *Simulation) resetGroundBounds() {
+	half := initialGroundLength / 2
+	s.groundStart = s.dino.X - half
+	if s.groundStart < 0 {
+		s.groundStart = 0
+	}
+	s.groundEnd = s.dino.X + half
+	if s.groundEnd > width-1 {
+		s.groundEnd = width - 1
+	}
+}
+
+// Resize changes the play-field width to w cells (at least minWidth).
+// The visible part of the ground is kept so there is no visible jump,
+// and clouds and obstacles beyond the new right edge are pulled in.
+func (s *Simulation) Resize(w int) {
+	if w < minWidth {
+		w = minWidth
+	}
+	oldWidth := width
+	if w == oldWidth {
+		return
+	}
+	SetWidth(w)
+	s.resizeGround(oldWidth)
+	s.cloudManager.Resize(oldWidth)
+	s.obstacleManager.Resize(oldWidth)
+
+	// 地面尚未完全展开时，边界不能超出新的宽度
+	if s.groundEnd > width-1 {
+		s.groundEnd = width - 1
+	}
+	if s.groundStart > s.groundEnd {
+		s.groundStart = s.groundEnd
+	}
+}
+
+// Restart starts a new run after a collision, keeping the clouds moving.
+// Frontends normally send ActionRestart instead so the restart is recorded.
+func (s *Simulation) Restart() {
+	s.dino = NewDino()
+	// 每局换一个种子，结束画面上的种子能复现那一局
+	s.seed = s.runSeeds.Int63()
+	s.rng, _, _, _ = worldRands(s.seed)
+	s.obstacleManager = NewObstacleManager(s.rng)
+	s.score = 0
+	s.runTicks = 0
+	s.frameCounter = 0
+	s.lastScoreMilestone = 0
+	s.collided = false
+	s.downKeyHeld = false
+	s.pause = false
+
+	// reset stage progression and parameters
+	s.stageIndexActive = 0
+	s.stageIndexTarget = 0
+	s.stageTransitionStart = 0
+	s.speed = stageConfigs[0].Speed * speedFactor
+
+	// 重置分数闪烁状态
+	s.scoreBlinking = false
+	s.scoreBlinkStart = 0
+	s.scoreBlinkVisible = true
+	s.lastBlinkToggle = 0
+
+	// 新的一局从白天开始，夜里重开时天慢慢亮起来
+	s.dayNightCount = 0
+	if s.night {
+		s.night = false
+		s.dayNightSwitch = s.tick
+	}
+}
+
+// Step applies the given actions, advances the world by one tick and
+// returns the resulting state. After a collision the world stays frozen
+// until an ActionRestart is received.
+func (s *Simulation) Step(actions []Action) State {
+	s.sounds = nil
+	if s.collided {
+		if !containsAction(actions, ActionRestart) {
+			return s.State()
+		}
+		s.Restart()
+	}
+
+	for _, a := range actions {
+		s.apply(a)
+	}
+	s.update()
+	if !s.collided {
+		if s.started && !s.pause {
+			s.runTicks++
+		}
+		s.updateScore()
+	} else {
+		// 播放碰撞音效
+		s.playSound(SoundCollision)
+	}
+	s.updateScoreBlink()
+	s.tick++
+	return s.State()
+}
+
+// containsAction reports whether actions includes a
+func containsAction(actions []Action, a Action) bool {
+	for _, x := range actions {
+		if x == a {
+			return true
+		}
+	}
+	return false
+}
+
+// State returns the current state without advancing the simulation
+func (s *Simulation) State() State {
+	return State{
+		Tick:     s.tick,
+		Score:    s.score,
+		Stage:    s.stageIndexActive,
+		Speed:    s.speed,
+		RunTicks: s.runTicks,
+		Started:  s.started,
+		Paused:   s.pause,
+		GameOver: s.collided,
+		Sounds:   s.sounds,
+	}
+}
+
+// apply performs a single input action
+func (s *Simulation) apply(a Action) {
+	switch a {
+	case ActionJump:
+		if !s.started {
+			s.started = true
+			s.groundExtending = true
+		}
+		if s.dino.Jump() {
+			s.playSound(SoundJump)
+		}
+		// cancel duck when jumping
+		s.dino.duckFrames = 0
+		// 跳跃时重置下键状态
+		s.releaseDuck()
+	case ActionDuck:
+		if s.started {
+			// 设置下键被按住的状态
+			s.downKeyHeld = true
+			s.dino.isDownKeyPressed = true
+
+			if s.dino.OnGround() {
+				// 在地面上按下键时蹲下
+				s.dino.Duck()
+			} else if s.dino.FastDrop() {
+				// 在空中按下键时快速下降
+				s.playSound(SoundDrop)
+			}
+		}
+	case ActionDuckRelease:
+		s.releaseDuck()
+	case ActionPause:
+		if s.started {
+			s.pause = !s.pause
+		}
+	}
+}
+
+// releaseDuck clears the held down key state
+func (s *Simulation) releaseDuck() {
+	s.downKeyHeld = false
+	s.dino.isDownKeyPressed = false
+}
+
+// updateScore increments the score while the run is active
+func (s *Simulation) updateScore() {
+	// 只有在分数不闪烁时才增加分数
+	if !s.started || s.pause || s.scoreBlinking {
+		return
+	}
+	// 使用帧计数器来减慢积分累计速度
+	if s.frameCounter%2 == 0 {
+		s.score++
+	}
+	s.frameCounter++
+
+	// 每得到100分播放一次得分音效
+	if s.score/ScoreMilestone > s.lastScoreMilestone {
+		s.lastScoreMilestone = s.score / ScoreMilestone
+		s.playSound(SoundScore)
+	}
+
+	// 每 dayNightInterval 分昼夜交替一次
+	if dayNightInterval > 0 && s.score/dayNightInterval > s.dayNightCount {
+		s.dayNightCount = s.score / dayNightInterval
+		s.night = !s.night
+		s.dayNightSwitch = s.tick
+	}
+}
+
+// darkness returns how dark it is, from 0 (day) to 1 (night). Day and
+// night fade into each other over stageTransitionDuration.
+func (s *Simulation) darkness() float64 {
+	frac := 1.0
+	if s.dayNightSwitch > 0 {
+		frac = math.Min(1, float64(s.tick-s.dayNightSwitch)/float64(ticksFor(stageTransitionDuration)))
+	}
+	if s.night {
+		return frac
+	}
+	return 1 - frac
+}
+
+// updateScoreBlink toggles score visibility while the score is blinking
+func (s *Simulation) updateScoreBlink() {
+	if !s.scoreBlinking {
+		return
+	}
+	// 检查是否需要结束闪烁
+	if s.tick-s.scoreBlinkStart >= ticksFor(ScoreBlinkDuration) {
+		s.scoreBlinking = false
+		s.scoreBlinkVisible = true
+		return
+	}
+	// 检查是否需要切换闪烁状态
+	if s.tick-s.lastBlinkToggle >= ticksFor(ScoreBlinkInterval) {
+		s.scoreBlinkVisible = !s.scoreBlinkVisible
+		s.lastBlinkToggle = s.tick
+	}
+}
+
+// playSound queues a sound event for the current tick
+func (s *Simulation) playSound(name string) {
+	s.sounds = append(s.sounds, name)
+}
+
+// Draw renders the world (clouds, ground, dino and obstacles)
+func (s *Simulation) Draw(r Renderer) {
+	// Draw clouds first (always across the entire sky), with the moon and
+	// stars behind them at night
+	s.cloudManager.Draw(r, s.darkness())
+
+	// ground
+	if !s.started || s.groundExtending {
+		s.drawGroundPartial(r)
+	} else {
+		s.drawGround(r)
+	}
+
+	// dino
+	s.dino.Draw(r)
+
+	// obstacle
+	if s.started {
+		s.obstacleManager.Draw(r)
+	}
+}
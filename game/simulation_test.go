@@ -0,0 +1,452 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// steps applies the actions one tick at a time, nil entries being ticks
+// without input, and returns the last state
+func steps(s *Simulation, ticks ...[]Action) State {
+	st := s.State()
+	for _, actions := range ticks {
+		st = s.Step(actions)
+	}
+	return st
+}
+
+// idle returns n ticks without input
+func idle(n int) [][]Action {
+	return make([][]Action, n)
+}
+
+func TestSimulationStep(t *testing.T) {
+	jump := [][]Action{{ActionJump}}
+	tests := []struct {
+		name  string
+		ticks [][]Action
+		check func(t *testing.T, s *Simulation, st State)
+	}{
+		{"start screen waits", idle(300), func(t *testing.T, s *Simulation, st State) {
+			if st.Started || st.Score != 0 || st.Tick != 300 {
+				t.Errorf("state %+v, want the start screen after 300 ticks", st)
+			}
+		}},
+		{"duck before the start", [][]Action{{ActionDuck}}, func(t *testing.T, s *Simulation, st State) {
+			if st.Started || len(st.Sounds) != 0 {
+				t.Errorf("state %+v, want nothing to happen", st)
+			}
+		}},
+		{"jump starts the run", jump, func(t *testing.T, s *Simulation, st State) {
+			if !st.Started || !slices.Contains(st.Sounds, SoundJump) {
+				t.Errorf("state %+v, want a started run and a jump sound", st)
+			}
+			if s.dino.OnGround() {
+				t.Error("the dino is still on the ground")
+			}
+		}},
+		{"dino lands again", append(jump, idle(200)...), func(t *testing.T, s *Simulation, st State) {
+			if !s.dino.OnGround() {
+				t.Errorf("the dino is still in the air at %v", s.dino.posY)
+			}
+		}},
+		{"score every other tick", append(jump, idle(99)...), func(t *testing.T, s *Simulation, st State) {
+			if st.Score != 50 {
+				t.Errorf("score %d after 100 ticks, want 50", st.Score)
+			}
+		}},
+		{"pause stops the score", append(append(append(jump, idle(99)...), []Action{ActionPause}), idle(500)...), func(t *testing.T, s *Simulation, st State) {
+			if !st.Paused || st.Score != 50 {
+				t.Errorf("state %+v, want paused at score 50", st)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSimulation(1)
+			tt.check(t, s, steps(s, tt.ticks...))
+		})
+	}
+}
+
+// TestSimulationGameOver checks that a run without input ends on an
+// obstacle, after which only a restart moves the simulation on
+func TestSimulationGameOver(t *testing.T) {
+	s := NewSimulation(1)
+	st := s.Step([]Action{ActionJump})
+	for i := 0; i < 10000 && !st.GameOver; i++ {
+		st = s.Step(nil)
+	}
+	if !st.GameOver {
+		t.Fatal("the dino never hit an obstacle")
+	}
+	if !slices.Contains(st.Sounds, SoundCollision) {
+		t.Errorf("sounds %q on the collision tick, want %q", st.Sounds, SoundCollision)
+	}
+
+	over := st
+	if st = steps(s, idle(10)...); st.Tick != over.Tick || st.Score != over.Score || len(st.Sounds) != 0 {
+		t.Errorf("state %+v after the collision, want %+v without sounds", st, over)
+	}
+
+	s.Restart()
+	if st = s.State(); st.GameOver || st.Score != 0 || !st.Started {
+		t.Errorf("state %+v after a restart, want a new run", st)
+	}
+}
+
+// TestSimulationDraw renders the world into a BufferRenderer
+func TestSimulationDraw(t *testing.T) {
+	tests := []struct {
+		name     string
+		ticks    [][]Action
+		airborne bool
+	}{
+		{"start screen", nil, false},
+		{"running", append([][]Action{{ActionJump}}, idle(200)...), false},
+		{"jumping", append([][]Action{{ActionJump}}, idle(10)...), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSimulation(1)
+			steps(s, tt.ticks...)
+			r := NewBufferRenderer(width, height)
+			s.Draw(r)
+
+			rows := strings.Split(r.String(), "\n")
+			if ground := rows[height-1]; strings.TrimSpace(ground) == "" {
+				t.Errorf("no ground in\n%s", r)
+			}
+			// 恐龙的脚在 posY 这一行
+			feet := int(s.dino.posY)
+			if airborne := feet < height-2; airborne != tt.airborne {
+				t.Fatalf("dino at row %d, airborne %v, want %v", feet, airborne, tt.airborne)
+			}
+			if strings.TrimSpace(rows[feet][:min(len(rows[feet]), s.dino.X+4)]) == "" {
+				t.Errorf("no dino on row %d in\n%s", feet, r)
+			}
+		})
+	}
+}
+
+// botActions plays like a simple player: it starts the run, ducks under
+// birds flying at head height, jumps over everything else just ahead of
+// the dino and restarts after a collision
+func botActions(s *Simulation) []Action {
+	st := s.State()
+	switch {
+	case !st.Started:
+		return []Action{ActionJump}
+	case st.GameOver:
+		return []Action{ActionRestart}
+	}
+	for _, o := range s.obstacleManager.GetObstacles() {
+		x, y := o.GetPosition()
+		if ahead := x - float64(s.dino.X); ahead <= 0 || ahead >= 15 || !s.dino.OnGround() {
+			continue
+		}
+		if t := o.GetType(); (t == BirdType || t == BigBirdType) && y < height-3 {
+			return []Action{ActionDuck}
+		}
+		return []Action{ActionJump}
+	}
+	return nil
+}
+
+// obstacleLog returns a function that notes every obstacle the first time
+// it shows up, so two runs can be compared obstacle by obstacle
+func obstacleLog(s *Simulation) (func(), *[]string) {
+	seen := map[IObstacle]bool{}
+	var log []string
+	return func() {
+		for _, o := range s.obstacleManager.GetObstacles() {
+			if !seen[o] {
+				seen[o] = true
+				_, y := o.GetPosition()
+				log = append(log, fmt.Sprintf("%d@%d", o.GetType(), y))
+			}
+		}
+	}, &log
+}
+
+// playRun lets the bot play from the start screen until the first
+// collision and returns the final state and the obstacles it met
+func playRun(s *Simulation) (State, []string) {
+	note, log := obstacleLog(s)
+	st := s.Step(botActions(s))
+	for i := 0; i < 20000 && !st.GameOver; i++ {
+		note()
+		st = s.Step(botActions(s))
+	}
+	return st, *log
+}
+
+func TestStepDeterministic(t *testing.T) {
+	tests := []struct {
+		seed  int64
+		ticks int
+	}{
+		{seed: 1, ticks: 2000},
+		{seed: 42, ticks: 5000},
+		{seed: -7, ticks: 3000},
+		{seed: 1 << 40, ticks: 4000},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.seed), func(t *testing.T) {
+			play := func() ([]State, []string) {
+				s := NewSimulation(tt.seed)
+				r := NewBufferRenderer(width, height+1)
+				var states []State
+				var frames []string
+				for i := 0; i < tt.ticks; i++ {
+					states = append(states, s.Step(botActions(s)))
+					r.Clear()
+					s.Draw(r)
+					frames = append(frames, r.String())
+				}
+				return states, frames
+			}
+			states1, frames1 := play()
+			states2, frames2 := play()
+			for i := range states1 {
+				if !reflect.DeepEqual(states1[i], states2[i]) {
+					t.Fatalf("tick %d: state %+v, then %+v", i, states1[i], states2[i])
+				}
+				if frames1[i] != frames2[i] {
+					t.Fatalf("tick %d: frames differ:\n%s\n%s", i, frames1[i], frames2[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStepSeedsDiffer(t *testing.T) {
+	_, a := playRun(NewSimulation(1))
+	_, b := playRun(NewSimulation(2))
+	if reflect.DeepEqual(a, b) {
+		t.Errorf("seeds 1 and 2 both met obstacles %v", a)
+	}
+}
+
+// TestObstaclesIgnoreStartDelay checks that the time spent on the start
+// screen does not change the obstacles of a run
+func TestObstaclesIgnoreStartDelay(t *testing.T) {
+	play := func(delay int) (State, []string) {
+		s := NewSimulation(42)
+		steps(s, idle(delay)...)
+		return playRun(s)
+	}
+	want, wantLog := play(0)
+	for _, delay := range []int{1, 77, 2000} {
+		t.Run(fmt.Sprint(delay), func(t *testing.T) {
+			got, log := play(delay)
+			if !reflect.DeepEqual(log, wantLog) {
+				t.Errorf("obstacles %v, want %v", log, wantLog)
+			}
+			if got.Score != want.Score {
+				t.Errorf("run ended with score %d, want %d", got.Score, want.Score)
+			}
+		})
+	}
+}
+
+// TestRunSeedReproducesRun checks that the seed shown after a restarted
+// run brings the same run back
+func TestRunSeedReproducesRun(t *testing.T) {
+	for _, seed := range []int64{42, 7, 0} {
+		t.Run(fmt.Sprint(seed), func(t *testing.T) {
+			s := NewSimulation(seed)
+			seeds := map[int64]bool{seed: true}
+			for run := 1; run <= 3; run++ {
+				if _, log := playRun(s); len(log) == 0 {
+					t.Fatalf("run %d met no obstacles", run)
+				}
+				s.Step([]Action{ActionRestart})
+				if seeds[s.Seed()] {
+					t.Fatalf("run %d reuses seed %d", run+1, s.Seed())
+				}
+				seeds[s.Seed()] = true
+			}
+
+			runSeed := s.Seed()
+			got, gotLog := playRun(s)
+			want, wantLog := playRun(NewSimulation(runSeed))
+			if !reflect.DeepEqual(gotLog, wantLog) {
+				t.Errorf("restarted run met %v, seed %d gives %v", gotLog, runSeed, wantLog)
+			}
+			if got.Score != want.Score {
+				t.Errorf("restarted run scored %d, seed %d scores %d", got.Score, runSeed, want.Score)
+			}
+		})
+	}
+}
+
+func TestSimulationResize(t *testing.T) {
+	tests := []struct {
+		name  string
+		ticks int // ticks played before the resize
+		to    int
+		want  int
+	}{
+		{"wider on the start screen", 0, 120, 120},
+		{"narrower while running", 600, 50, 50},
+		{"below the minimum", 600, 10, minWidth},
+		{"same width", 300, 80, 80},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer SetWidth(width)
+			s := NewSimulation(42)
+			for i := 0; i < tt.ticks; i++ {
+				s.Step(botActions(s))
+			}
+			s.Resize(tt.to)
+			if width != tt.want {
+				t.Fatalf("width %d, want %d", width, tt.want)
+			}
+			if s.groundStart < 0 || s.groundEnd > width-1 || s.groundStart > s.groundEnd {
+				t.Errorf("ground %d..%d on a field %d wide", s.groundStart, s.groundEnd, width)
+			}
+			// 尺寸变化后继续运行，画面不超出新的宽度
+			for i := 0; i < 300; i++ {
+				s.Step(botActions(s))
+			}
+			r := NewBufferRenderer(width+10, height)
+			s.Draw(r)
+			for y := 0; y < height; y++ {
+				for x := width; x < width+10; x++ {
+					if ch := r.Cell(x, y); ch != ' ' {
+						t.Fatalf("%q drawn at %d,%d outside the field", ch, x, y)
+					}
+				}
+			}
+		})
+	}
+}
+
+// jumpArc lets the dino jump once and returns how many rows it rose and
+// how many ticks it was in the air
+func jumpArc() (float64, int) {
+	s := NewSimulation(1)
+	s.Step([]Action{ActionJump})
+	top, airborne := s.dino.posY, 1
+	for !s.dino.OnGround() && airborne < 1000 {
+		s.Step(nil)
+		top = min(top, s.dino.posY)
+		airborne++
+	}
+	return float64(height-2) - top, airborne
+}
+
+func TestSetHeight(t *testing.T) {
+	_, airtime := jumpArc()
+	tests := []struct {
+		set, want int
+	}{
+		{10, defaultHeight},
+		{defaultHeight, defaultHeight},
+		{24, 24},
+		{maxHeight, maxHeight},
+		{99, maxHeight},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.set), func(t *testing.T) {
+			defer SetHeight(height)
+			SetHeight(tt.set)
+			if height != tt.want {
+				t.Fatalf("height %d, want %d", height, tt.want)
+			}
+			for _, row := range append(birdFlightRows, bigBirdFlightRow) {
+				if row <= 0 || row >= height-2 {
+					t.Errorf("birds fly on row %d of %d", row, height)
+				}
+			}
+
+			// 跳跃高度随场地缩放，滞空时间不变
+			rise, ticks := jumpArc()
+			if rise < float64(jumpHeight)-1 || rise > float64(jumpHeight)+1 {
+				t.Errorf("the dino rose %.1f rows, want about %d", rise, jumpHeight)
+			}
+			if ticks < airtime-2 || ticks > airtime+2 {
+				t.Errorf("the jump took %d ticks, %d at the default height", ticks, airtime)
+			}
+		})
+	}
+}
+
+func TestDarkness(t *testing.T) {
+	fade := ticksFor(stageTransitionDuration)
+	tests := []struct {
+		name     string
+		night    bool
+		switched int // tick the last switch started on, 0 for none
+		tick     int
+		want     float64
+	}{
+		{"first day", false, 0, 500, 0},
+		{"dusk begins", true, 1000, 1000, 0},
+		{"dusk", true, 1000, 1000 + fade/2, 0.5},
+		{"night", true, 1000, 1000 + fade, 1},
+		{"deep night", true, 1000, 1000 + 10*fade, 1},
+		{"dawn", false, 2000, 2000 + fade/4, 0.75},
+		{"day again", false, 2000, 2000 + fade, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSimulation(1)
+			s.night, s.dayNightSwitch, s.tick = tt.night, tt.switched, tt.tick
+			if got := s.darkness(); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("darkness %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDayNightCycle checks that night falls every dayNightInterval points
+// and that a restart brings the day back
+func TestDayNightCycle(t *testing.T) {
+	saved := dayNightInterval
+	t.Cleanup(func() { dayNightInterval = saved })
+	dayNightInterval = 5
+
+	s := NewSimulation(1)
+	steps(s, []Action{ActionJump})
+	// 每两个 tick 得一分
+	steps(s, idle(7)...)
+	if s.night {
+		t.Fatalf("night fell at score %d", s.score)
+	}
+	steps(s, idle(2)...)
+	if !s.night || s.darkness() >= 0.5 {
+		t.Fatalf("score %d: night %v, darkness %v, want dusk", s.score, s.night, s.darkness())
+	}
+	steps(s, idle(10)...)
+	if s.night {
+		t.Errorf("score %d: still night, want dawn", s.score)
+	}
+	steps(s, idle(10)...)
+	if !s.night {
+		t.Fatalf("score %d: no second night", s.score)
+	}
+
+	s.Restart()
+	if s.night || s.darkness() <= 0.5 {
+		t.Errorf("after a restart: night %v, darkness %v, want dawn", s.night, s.darkness())
+	}
+	s.tick += ticksFor(stageTransitionDuration)
+	if d := s.darkness(); d != 0 {
+		t.Errorf("darkness %v after the fade, want 0", d)
+	}
+
+	dayNightInterval = 0
+	s = NewSimulation(1)
+	steps(s, []Action{ActionJump})
+	steps(s, idle(40)...)
+	if s.night {
+		t.Error("night fell with the cycle turned off")
+	}
+}
@@ -0,0 +1,307 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/jianongHe/term-rex/assets"
+	"github.com/mattn/go-runewidth"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 精灵包（皮肤）：一个目录，里面有 skin.toml 清单和纯文本帧文件。
+// 清单里没列出的精灵保持内置的样子；碰撞检测直接用加载的字形。
+
+// skinManifest is the manifest file of a skin directory
+const skinManifest = "skin.toml"
+
+// maxSpriteWidth is the widest a sprite of a skin may be
+const maxSpriteWidth = minWidth / 2
+
+// skinDino is the [dino] section of skin.toml
+type skinDino struct {
+	Stand []string `toml:"stand"`
+	Duck  []string `toml:"duck"`
+}
+
+// skinObstacles is the [obstacles] section of skin.toml
+type skinObstacles struct {
+	Cactus      []string `toml:"cactus"`
+	ShortCactus []string `toml:"short_cactus"`
+	GroupCactus []string `toml:"group_cactus"`
+	Bird        []string `toml:"bird"`
+	BigBird     []string `toml:"big_bird"`
+}
+
+// skinSky is the [sky] section of skin.toml
+type skinSky struct {
+	Clouds []string `toml:"clouds"`
+	Moon   string   `toml:"moon"`
+}
+
+// skinFile is the layout of skin.toml. Every list names frame files,
+// relative to the skin directory, in animation order. A frame file holds
+// the glyph rows; optional files next to it, with ".mask" or ".pixels"
+// before the extension (stand-1.mask.txt for stand-1.txt), hold the
+// ColorSprite mask and pixel art.
+type skinFile struct {
+	Name      string        `toml:"name"`
+	Dino      skinDino      `toml:"dino"`
+	Obstacles skinObstacles `toml:"obstacles"`
+	Sky       skinSky       `toml:"sky"`
+}
+
+// skinTuning describes the sprites of the loaded skin for
+// TuningFingerprint, because they change collisions; "" for the built-in
+// sprites
+var skinTuning string
+
+// SkinNames returns the names of the skins bundled with the game
+func SkinNames() []string {
+	entries, _ := fs.ReadDir(assets.Skins, "skins")
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findSkin returns the directory of a skin: name itself if it is a path,
+// else skins/<name> in the data directory, else a bundled skin
+func findSkin(name string) (fs.FS, error) {
+	if strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		return os.DirFS(name), nil
+	}
+	if dir, err := DataDir(); err == nil {
+		dir = filepath.Join(dir, "skins", name)
+		if _, err := os.Stat(filepath.Join(dir, skinManifest)); err == nil {
+			return os.DirFS(dir), nil
+		}
+	}
+	if fsys, err := fs.Sub(assets.Skins, path.Join("skins", name)); err == nil {
+		if _, err := fs.Stat(fsys, skinManifest); err == nil {
+			return fsys, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown skin %q (bundled: %s)", name, strings.Join(SkinNames(), ", "))
+}
+
+// LoadSkin loads a skin by name or directory and replaces the built-in
+// sprites with it. The sprites must fit a play field h rows tall (0 means
+// defaultHeight). Nothing is changed unless the whole skin is valid.
+func LoadSkin(name string, h int) error {
+	fsys, err := findSkin(name)
+	if err != nil {
+		return err
+	}
+	data, err := fs.ReadFile(fsys, skinManifest)
+	if err != nil {
+		return fmt.Errorf("skin %s: %v", name, err)
+	}
+	var sf skinFile
+	md, err := toml.Decode(string(data), &sf)
+	if err != nil {
+		return fmt.Errorf("skin %s: %s: %v", name, skinManifest, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("skin %s: %s: unknown setting %q", name, skinManifest, undecoded[0].String())
+	}
+
+	// 按要用的场地高度检查，之后恢复
+	defer SetHeight(height)
+	SetHeight(h)
+	ground := height - 2
+
+	var errs []error
+	load := func(set string, files []string, maxHeight int) []ColorSprite {
+		frames, err := loadFrames(fsys, set, files, maxHeight)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return frames
+	}
+	// 跳到最高点时恐龙不能碰到第 0 行的分数；快速下降时也会在空中画蹲下的帧
+	stand := load("dino.stand", sf.Dino.Stand, ground-jumpHeight)
+	duck := load("dino.duck", sf.Dino.Duck, ground-jumpHeight)
+	obstacles := map[ObstacleType][]ColorSprite{
+		SingleCactusType: load("obstacles.cactus", sf.Obstacles.Cactus, ground),
+		ShortCactusType:  load("obstacles.short_cactus", sf.Obstacles.ShortCactus, ground),
+		GroupCactusType:  load("obstacles.group_cactus", sf.Obstacles.GroupCactus, ground),
+		BirdType:         load("obstacles.bird", sf.Obstacles.Bird, slices.Min(birdFlightRows)),
+		BigBirdType:      load("obstacles.big_bird", sf.Obstacles.BigBird, bigBirdFlightRow),
+	}
+	// 云和月亮要在地面之上
+	clouds := load("sky.clouds", sf.Sky.Clouds, ground-cloudMaxHeight)
+	var moon []ColorSprite
+	if sf.Sky.Moon != "" {
+		moon = load("sky.moon", []string{sf.Sky.Moon}, ground-cloudMinHeight)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("skin %s: %v", name, errors.Join(errs...))
+	}
+
+	if stand != nil {
+		dinoStandFrames = stand
+	}
+	if duck != nil {
+		dinoDuckFrames = duck
+	}
+	for t, frames := range obstacles {
+		if frames != nil {
+			ObstacleFrames[t] = frames
+		}
+	}
+	if clouds != nil {
+		cloudSprites = clouds
+	}
+	if moon != nil {
+		moonSprite = moon[0]
+	}
+	skinTuning = spriteTuning()
+	return nil
+}
+
+// loadFrames reads the frames of one sprite set. They must all be as tall
+// as each other and at most maxHeight rows. It returns nil for no files.
+func loadFrames(fsys fs.FS, set string, files []string, maxHeight int) ([]ColorSprite, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	frames := make([]ColorSprite, len(files))
+	for i, file := range files {
+		frame, err := readFrame(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", set, err)
+		}
+		h, w := len(frame.Glyphs), frame.Glyphs.Width()
+		switch {
+		case i > 0 && h != len(frames[0].Glyphs):
+			return nil, fmt.Errorf("%s: %s is %d rows tall but %s is %d", set, file, h, files[0], len(frames[0].Glyphs))
+		case h > maxHeight:
+			return nil, fmt.Errorf("%s: %s is %d rows tall, at most %d fit a play field of height %d", set, file, h, maxHeight, height)
+		case w > maxSpriteWidth:
+			return nil, fmt.Errorf("%s: %s is %d columns wide, at most %d are allowed", set, file, w, maxSpriteWidth)
+		}
+		frames[i] = frame
+	}
+	return frames, nil
+}
+
+// readFrame reads a frame file and its mask and pixel files if it has them
+func readFrame(fsys fs.FS, file string) (ColorSprite, error) {
+	glyphs, err := readRows(fsys, file)
+	if err != nil {
+		return ColorSprite{}, err
+	}
+	if len(glyphs) == 0 {
+		return ColorSprite{}, fmt.Errorf("%s: the frame is empty", file)
+	}
+	for row, line := range glyphs {
+		for _, ch := range line {
+			if !unicode.IsPrint(ch) || runewidth.RuneWidth(ch) != 1 {
+				return ColorSprite{}, fmt.Errorf("%s:%d: %q does not take exactly one cell", file, row+1, ch)
+			}
+		}
+	}
+
+	frame := ColorSprite{Glyphs: glyphs}
+	ext := path.Ext(file)
+	maskFile := strings.TrimSuffix(file, ext) + ".mask" + ext
+	mask, err := readRows(fsys, maskFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return ColorSprite{}, err
+	case len(mask) > len(glyphs):
+		return ColorSprite{}, fmt.Errorf("%s: %d rows for a frame of %d", maskFile, len(mask), len(glyphs))
+	default:
+		for row, line := range mask {
+			keys := []rune(line)
+			if len(keys) > len([]rune(glyphs[row])) {
+				return ColorSprite{}, fmt.Errorf("%s:%d: the row is longer than the frame's", maskFile, row+1)
+			}
+			for _, key := range keys {
+				if !validMaskKey(key) {
+					return ColorSprite{}, fmt.Errorf("%s:%d: unknown mask key %q", maskFile, row+1, key)
+				}
+			}
+		}
+		frame.Mask = mask
+	}
+
+	// 盲文模式的像素图，每个字符格最多 2x4 个点
+	pixelsFile := strings.TrimSuffix(file, ext) + ".pixels" + ext
+	pixels, err := readRows(fsys, pixelsFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return ColorSprite{}, err
+	case len(pixels) > len(glyphs)*dotsY:
+		return ColorSprite{}, fmt.Errorf("%s: %d rows, at most %d fit a frame of %d", pixelsFile, len(pixels), len(glyphs)*dotsY, len(glyphs))
+	default:
+		for row, line := range pixels {
+			keys := []rune(line)
+			if len(keys) > glyphs.Width()*dotsX {
+				return ColorSprite{}, fmt.Errorf("%s:%d: %d dots, at most %d fit a frame %d columns wide", pixelsFile, row+1, len(keys), glyphs.Width()*dotsX, glyphs.Width())
+			}
+			for _, key := range keys {
+				if !validPixelKey(key) {
+					return ColorSprite{}, fmt.Errorf("%s:%d: unknown pixel key %q", pixelsFile, row+1, key)
+				}
+			}
+		}
+		frame.Pixels = PixelArt(pixels)
+	}
+	return frame, nil
+}
+
+// readRows reads the rows of a text file, without trailing empty rows
+func readRows(fsys fs.FS, file string) (Sprite, error) {
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return nil, nil
+	}
+	return strings.Split(text, "\n"), nil
+}
+
+// validMaskKey reports whether a ColorSprite mask may hold key
+func validMaskKey(key rune) bool {
+	switch key {
+	case maskDefault, maskTransparent, maskUnderline:
+		return true
+	}
+	_, ok := maskTones[unicode.ToLower(key)]
+	return ok
+}
+
+// spriteTuning describes the sprites that affect the simulation: what
+// collides, and the clouds whose number and widths shape the random draws
+func spriteTuning() string {
+	shapes := func(frames []ColorSprite) []Sprite {
+		s := make([]Sprite, len(frames))
+		for i, f := range frames {
+			s[i] = f.Shape()
+		}
+		return s
+	}
+	obstacles := make([][]Sprite, BigBirdType+1)
+	for t := range obstacles {
+		obstacles[t] = shapes(ObstacleFrames[ObstacleType(t)])
+	}
+	return fmt.Sprintf("%q %q %q %q", shapes(dinoStandFrames), shapes(dinoDuckFrames), obstacles, shapes(cloudSprites))
+}
@@ -0,0 +1,234 @@
+package game
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// keepSprites puts back the sprites LoadSkin replaces when the test ends
+func keepSprites(t *testing.T) {
+	stand, duck, obstacles := dinoStandFrames, dinoDuckFrames, maps.Clone(ObstacleFrames)
+	clouds, moon, tuning := cloudSprites, moonSprite, skinTuning
+	t.Cleanup(func() {
+		dinoStandFrames, dinoDuckFrames, ObstacleFrames = stand, duck, obstacles
+		cloudSprites, moonSprite, skinTuning = clouds, moon, tuning
+	})
+}
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  ColorSprite
+		err   string // part of the error, "" for none
+	}{
+		{
+			name:  "no mask",
+			files: map[string]string{"f.txt": " o \n/|\\\n"},
+			want:  ColorSprite{Glyphs: Sprite{" o ", "/|\\"}},
+		},
+		{
+			name:  "with mask",
+			files: map[string]string{"f.txt": "ab\ncd", "f.mask.txt": "E.\n"},
+			want:  ColorSprite{Glyphs: Sprite{"ab", "cd"}, Mask: Sprite{"E."}},
+		},
+		{
+			name:  "windows line ends and trailing rows",
+			files: map[string]string{"f.txt": "ab\r\ncd\r\n\r\n\n"},
+			want:  ColorSprite{Glyphs: Sprite{"ab", "cd"}},
+		},
+		{
+			name:  "block characters",
+			files: map[string]string{"f.txt": "▄█▄", "f.mask.txt": ".bl"},
+			want:  ColorSprite{Glyphs: Sprite{"▄█▄"}, Mask: Sprite{".bl"}},
+		},
+		{name: "missing", files: map[string]string{}, err: "f.txt"},
+		{name: "empty", files: map[string]string{"f.txt": "\n\n"}, err: "f.txt: the frame is empty"},
+		{name: "wide character", files: map[string]string{"f.txt": "ab\n恐龙"}, err: `f.txt:2: '恐' does not take exactly one cell`},
+		{name: "tab", files: map[string]string{"f.txt": "a\tb"}, err: `f.txt:1: '\t' does not take exactly one cell`},
+		{name: "mask too tall", files: map[string]string{"f.txt": "ab", "f.mask.txt": "bb\nbb"}, err: "f.mask.txt: 2 rows for a frame of 1"},
+		{name: "mask too wide", files: map[string]string{"f.txt": "ab", "f.mask.txt": "bbb"}, err: "f.mask.txt:1: the row is longer than the frame's"},
+		{name: "unknown mask key", files: map[string]string{"f.txt": "ab", "f.mask.txt": "bx"}, err: "f.mask.txt:1: unknown mask key 'x'"},
+		{
+			name:  "with pixels",
+			files: map[string]string{"f.txt": "ab", "f.pixels.txt": "..bb\nB.dE\n"},
+			want:  ColorSprite{Glyphs: Sprite{"ab"}, Pixels: PixelArt{"..bb", "B.dE"}},
+		},
+		{name: "pixels too tall", files: map[string]string{"f.txt": "ab", "f.pixels.txt": strings.Repeat("b\n", 5)}, err: "f.pixels.txt: 5 rows, at most 4 fit a frame of 1"},
+		{name: "pixels too wide", files: map[string]string{"f.txt": "ab", "f.pixels.txt": "bbbbb"}, err: "f.pixels.txt:1: 5 dots, at most 4 fit a frame 2 columns wide"},
+		{name: "unknown pixel key", files: map[string]string{"f.txt": "ab", "f.pixels.txt": "b_"}, err: "f.pixels.txt:1: unknown pixel key '_'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for name, data := range tt.files {
+				fsys[name] = &fstest.MapFile{Data: []byte(data)}
+			}
+			got, err := readFrame(fsys, "f.txt")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("frame %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFrames(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":    {Data: []byte("ab\ncd")},
+		"b.txt":    {Data: []byte("ef\ngh")},
+		"tall.txt": {Data: []byte("a\nb\nc")},
+		"wide.txt": {Data: []byte(strings.Repeat("x", maxSpriteWidth+1))},
+	}
+	tests := []struct {
+		name   string
+		files  []string
+		frames int
+		err    string
+	}{
+		{name: "none"},
+		{name: "two frames", files: []string{"a.txt", "b.txt"}, frames: 2},
+		{name: "different heights", files: []string{"a.txt", "tall.txt"}, err: "set: tall.txt is 3 rows tall but a.txt is 2"},
+		{name: "too tall", files: []string{"tall.txt"}, err: "set: tall.txt is 3 rows tall, at most 2 fit"},
+		{name: "too wide", files: []string{"wide.txt"}, err: "set: wide.txt is 21 columns wide, at most 20 are allowed"},
+		{name: "missing file", files: []string{"a.txt", "c.txt"}, err: "set: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, err := loadFrames(fsys, "set", tt.files, 2)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || len(frames) != tt.frames {
+				t.Errorf("%d frames, %v, want %d", len(frames), err, tt.frames)
+			}
+		})
+	}
+}
+
+func TestLoadSkin(t *testing.T) {
+	// writeSkin writes a skin directory and returns its path
+	writeSkin := func(t *testing.T, files map[string]string) string {
+		dir := t.TempDir()
+		for name, data := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+
+	t.Run("bundled", func(t *testing.T) {
+		keepSprites(t)
+		SetDataDir(t.TempDir())
+		t.Cleanup(func() { SetDataDir("") })
+		cactus, tuning := ObstacleFrames[SingleCactusType], TuningFingerprint()
+		if err := LoadSkin("gopher", 0); err != nil {
+			t.Fatal(err)
+		}
+		if len(dinoStandFrames) != 2 || !strings.Contains(dinoStandFrames[0].Glyphs[1], "(o)(o)") {
+			t.Errorf("dino %q, want the gopher", dinoStandFrames)
+		}
+		if !reflect.DeepEqual(ObstacleFrames[SingleCactusType], cactus) {
+			t.Error("a sprite the skin doesn't list was replaced")
+		}
+		if TuningFingerprint() == tuning {
+			t.Error("the tuning fingerprint did not change")
+		}
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		keepSprites(t)
+		dir := writeSkin(t, map[string]string{
+			skinManifest: "name = \"box\"\n[obstacles]\ncactus = [\"c.txt\"]\n[sky]\nmoon = \"m.txt\"\n",
+			"c.txt":      "#\n#",
+			"c.mask.txt": "B",
+			"m.txt":      "()",
+		})
+		if err := LoadSkin(dir, 0); err != nil {
+			t.Fatal(err)
+		}
+		want := []ColorSprite{{Glyphs: Sprite{"#", "#"}, Mask: Sprite{"B"}}}
+		if !reflect.DeepEqual(ObstacleFrames[SingleCactusType], want) {
+			t.Errorf("cactus %q, want %q", ObstacleFrames[SingleCactusType], want)
+		}
+		if !reflect.DeepEqual(moonSprite.Glyphs, Sprite{"()"}) {
+			t.Errorf("moon %q", moonSprite.Glyphs)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		h     int
+		errs  []string
+	}{
+		{name: "no manifest", files: map[string]string{"a.txt": "a"}, errs: []string{skinManifest}},
+		{name: "bad manifest", files: map[string]string{skinManifest: "[dino\n"}, errs: []string{skinManifest}},
+		{name: "unknown setting", files: map[string]string{skinManifest: "[dino]\nfly = [\"a.txt\"]\n"}, errs: []string{`unknown setting "dino.fly"`}},
+		{
+			name: "every problem is reported",
+			files: map[string]string{
+				skinManifest: "[dino]\nstand = [\"a.txt\"]\nduck = [\"none.txt\"]\n[obstacles]\nbird = [\"tall.txt\"]\n",
+				"a.txt":      "ok",
+				"tall.txt":   strings.Repeat("v\n", 30),
+			},
+			errs: []string{"dino.duck: ", "obstacles.bird: tall.txt is 30 rows tall"},
+		},
+		{
+			name: "too tall for a low play field",
+			files: map[string]string{
+				skinManifest: "[obstacles]\ncactus = [\"c.txt\"]\n",
+				"c.txt":      strings.Repeat("#\n", 15),
+			},
+			h:    defaultHeight,
+			errs: []string{"obstacles.cactus: c.txt is 15 rows tall"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keepSprites(t)
+			stand, h := dinoStandFrames, height
+			err := LoadSkin(writeSkin(t, tt.files), tt.h)
+			if err == nil {
+				t.Fatalf("no error, want %q", tt.errs)
+			}
+			for _, want := range tt.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+			// 整个皮肤有效才会生效
+			if !reflect.DeepEqual(dinoStandFrames, stand) {
+				t.Error("an invalid skin replaced the dino")
+			}
+			if height != h {
+				t.Errorf("height %d after loading, want %d", height, h)
+			}
+		})
+	}
+
+	t.Run("unknown skin", func(t *testing.T) {
+		SetDataDir(t.TempDir())
+		t.Cleanup(func() { SetDataDir("") })
+		if err := LoadSkin("nosuchskin", 0); err == nil || !strings.Contains(err.Error(), "gopher") {
+			t.Errorf("error %v, want the bundled skins listed", err)
+		}
+	})
+}
@@ -1,17 +1,138 @@
 package game
 
-import "github.com/nsf/termbox-go"
+import (
+	"github.com/nsf/termbox-go"
+	"unicode"
+	"unicode/utf8"
+)
 
-// Sprite 是一组字符串，表示多行 ASCII 艺术图
+// Sprite 是一组字符串，表示多行 ASCII 艺术图；每个字符（rune）占一格
 type Sprite []string
 
 // Draw 在 (x,y) 处逐字符绘制非空格字符
-func (s Sprite) Draw(x, y int, fg, bg termbox.Attribute) {
+func (s Sprite) Draw(r Renderer, x, y int, fg, bg termbox.Attribute) {
 	for row, line := range s {
-		for col, ch := range line {
+		for col, ch := range []rune(line) {
 			if ch != ' ' {
-				termbox.SetCell(x+col, y+row, ch, fg, bg)
+				r.SetCell(x+col, y+row, ch, fg, bg)
 			}
 		}
 	}
 }
+
+// Width returns the width of the widest row, in cells
+func (s Sprite) Width() int {
+	w := 0
+	for _, line := range s {
+		w = max(w, utf8.RuneCountInString(line))
+	}
+	return w
+}
+
+// ColorSprite is a Sprite with a mask layer: every row of Mask holds one
+// key per glyph, telling how the cell is drawn. Missing keys are ' '.
+//
+//	' '        the sprite's colour; transparent where the glyph is a space
+//	'.'        transparent, whatever the glyph
+//	'b'        the sprite's colour, also for spaces (an opaque cell)
+//	'l', 'd'   lighter and darker, for bellies, highlights and shadows
+//	'e'        much lighter, for eyes
+//	'_'        the sprite's colour, underlined
+//
+// An uppercase key draws its cell bold. Light and dark need more than 8
+// colours; with 8 they are the sprite's colour.
+//
+// Pixels is optional pixel art for the braille mode, at most 2x4 dots per
+// glyph; without it the glyphs are rasterized.
+type ColorSprite struct {
+	Glyphs Sprite
+	Mask   Sprite
+	Pixels PixelArt
+}
+
+// Mask keys with a meaning of their own
+const (
+	maskDefault     = ' '
+	maskTransparent = '.'
+	maskUnderline   = '_'
+)
+
+// maskTones is how much lighter (or darker) each colour key is
+var maskTones = map[rune]float64{
+	'b': 0,
+	'l': 0.35,
+	'd': -0.35,
+	'e': 0.8,
+}
+
+// spriteShading is how much lighter the top row and how much darker the
+// bottom row of a sprite are, so it looks lit from above
+const spriteShading = 0.35
+
+// maskRow returns the mask keys of a row
+func (s ColorSprite) maskRow(row int) []rune {
+	if row >= len(s.Mask) {
+		return nil
+	}
+	return []rune(s.Mask[row])
+}
+
+// maskKey returns the mask key of a cell from its row of keys
+func maskKey(mask []rune, col int) rune {
+	if col >= len(mask) {
+		return maskDefault
+	}
+	return mask[col]
+}
+
+// each calls cell for every cell of the sprite that is drawn, with the
+// colour it is drawn in when the sprite's colour is fg
+func (s ColorSprite) each(fg termbox.Attribute, cell func(col, row int, ch rune, fg termbox.Attribute)) {
+	h := len(s.Glyphs)
+	for row, line := range s.Glyphs {
+		light := 0.0
+		if h > 1 {
+			light = spriteShading * (1 - 2*float64(row)/float64(h-1))
+		}
+		mask := s.maskRow(row)
+		for col, ch := range []rune(line) {
+			key := maskKey(mask, col)
+			if key == maskTransparent || key == maskDefault && ch == ' ' {
+				continue
+			}
+			var attrs termbox.Attribute
+			if unicode.IsUpper(key) {
+				attrs |= termbox.AttrBold
+			}
+			if key == maskUnderline {
+				attrs |= termbox.AttrUnderline
+			}
+			tone := maskTones[unicode.ToLower(key)]
+			cell(col, row, ch, palette.shade(fg, light+tone)|attrs)
+		}
+	}
+}
+
+// Draw draws the sprite at (x,y) in fg
+func (s ColorSprite) Draw(r Renderer, x, y int, fg, bg termbox.Attribute) {
+	s.each(fg, func(col, row int, ch rune, fg termbox.Attribute) {
+		r.SetCell(x+col, y+row, ch, fg, bg)
+	})
+}
+
+// Shape returns the glyphs that are drawn, with transparent cells blanked,
+// for collision detection. Opaque spaces stay spaces.
+func (s ColorSprite) Shape() Sprite {
+	shape := make(Sprite, len(s.Glyphs))
+	for row, line := range s.Glyphs {
+		mask := s.maskRow(row)
+		cells := []rune(line)
+		for col := range cells {
+			if maskKey(mask, col) == maskTransparent {
+				cells[col] = ' '
+			}
+		}
+		shape[row] = string(cells)
+	}
+	return shape
+}
@@ -0,0 +1,140 @@
+package game
+
+import (
+	"fmt"
+	"github.com/nsf/termbox-go"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// spriteCells lists the cells a sprite draws in fg as "col,row:glyph"
+// with their colours
+func spriteCells(s ColorSprite, fg termbox.Attribute) map[string]termbox.Attribute {
+	cells := map[string]termbox.Attribute{}
+	s.each(fg, func(col, row int, ch rune, fg termbox.Attribute) {
+		cells[fmt.Sprintf("%d,%d:%c", col, row, ch)] = fg
+	})
+	return cells
+}
+
+func TestColorSpriteMask(t *testing.T) {
+	// tone is how much lighter a cell is drawn
+	type cell struct {
+		tone  float64
+		attrs termbox.Attribute
+	}
+	tests := []struct {
+		name  string
+		depth int
+		glyph string
+		mask  string
+		want  map[string]cell
+	}{
+		{"no mask", colorsTrue, "a b", "", map[string]cell{"0,0:a": {}, "2,0:b": {}}},
+		{"short mask", colorsTrue, "abc", "..", map[string]cell{"2,0:c": {}}},
+		{"transparent", colorsTrue, "abc", " . ", map[string]cell{"0,0:a": {}, "2,0:c": {}}},
+		{"opaque space", colorsTrue, "a c", "bbb", map[string]cell{"0,0:a": {}, "1,0: ": {}, "2,0:c": {}}},
+		{"tones", colorsTrue, "lde", "lde", map[string]cell{"0,0:l": {0.35, 0}, "1,0:d": {-0.35, 0}, "2,0:e": {0.8, 0}}},
+		{"bold", colorsTrue, "ab", "BL", map[string]cell{"0,0:a": {0, termbox.AttrBold}, "1,0:b": {0.35, termbox.AttrBold}}},
+		{"underline", colorsTrue, "a ", "__", map[string]cell{"0,0:a": {0, termbox.AttrUnderline}, "1,0: ": {0, termbox.AttrUnderline}}},
+		{"block characters", colorsTrue, "▄█▄", "d.B", map[string]cell{"0,0:▄": {-0.35, 0}, "2,0:▄": {0, termbox.AttrBold}}},
+		{"8 colours have no tones", colors8, "ld", "lD", map[string]cell{"0,0:l": {}, "1,0:d": {0, termbox.AttrBold}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useColorDepth(t, tt.depth)
+			s := ColorSprite{Glyphs: Sprite{tt.glyph}}
+			if tt.mask != "" {
+				s.Mask = Sprite{tt.mask}
+			}
+			want := map[string]termbox.Attribute{}
+			for k, c := range tt.want {
+				want[k] = palette.shade(termbox.ColorRed, c.tone) | c.attrs
+			}
+			if got := spriteCells(s, termbox.ColorRed); !reflect.DeepEqual(got, want) {
+				t.Errorf("cells %v, want %v", got, want)
+			}
+			if tt.depth == colors8 {
+				return
+			}
+			for k, c := range tt.want {
+				if c.tone != 0 && want[k]&^attrMask == termbox.ColorRed {
+					t.Errorf("%s is not shaded", k)
+				}
+			}
+		})
+	}
+}
+
+// TestColorSpriteShading checks that sprites are lit from above
+func TestColorSpriteShading(t *testing.T) {
+	useColorDepth(t, colorsTrue)
+	s := ColorSprite{Glyphs: Sprite{"a", "b", "c"}}
+	want := map[string]termbox.Attribute{
+		"0,0:a": palette.shade(termbox.ColorGreen, spriteShading),
+		"0,1:b": termbox.ColorGreen,
+		"0,2:c": palette.shade(termbox.ColorGreen, -spriteShading),
+	}
+	if got := spriteCells(s, termbox.ColorGreen); !reflect.DeepEqual(got, want) {
+		t.Errorf("cells %v, want %v", got, want)
+	}
+}
+
+func TestColorSpriteShape(t *testing.T) {
+	tests := []struct {
+		name   string
+		glyphs Sprite
+		mask   Sprite
+		want   Sprite
+	}{
+		{"no mask", Sprite{"ab", " c"}, nil, Sprite{"ab", " c"}},
+		{"transparent cells are blank", Sprite{"abc", "def"}, Sprite{".b", "l.."}, Sprite{" bc", "d  "}},
+		{"opaque spaces stay spaces", Sprite{"a c"}, Sprite{"bbb"}, Sprite{"a c"}},
+		{"block characters", Sprite{"▄█▄", "▀ ▀"}, Sprite{".b.", "l"}, Sprite{" █ ", "▀ ▀"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := ColorSprite{Glyphs: tt.glyphs, Mask: tt.mask}
+			if got := s.Shape(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("shape %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBuiltinSpriteMasks checks that the masks of the built-in sprites
+// line up with their glyphs and only use known keys
+func TestBuiltinSpriteMasks(t *testing.T) {
+	sprites := map[string]ColorSprite{"moon": moonSprite}
+	for i, s := range dinoStandFrames {
+		sprites[fmt.Sprint("dino stand ", i)] = s
+	}
+	for i, s := range dinoDuckFrames {
+		sprites[fmt.Sprint("dino duck ", i)] = s
+	}
+	for typ, frames := range ObstacleFrames {
+		for i, s := range frames {
+			sprites[fmt.Sprintf("obstacle %d frame %d", typ, i)] = s
+		}
+	}
+	for i, s := range cloudSprites {
+		sprites[fmt.Sprint("cloud ", i)] = s
+	}
+
+	for name, s := range sprites {
+		if len(s.Mask) > len(s.Glyphs) {
+			t.Errorf("%s: %d mask rows for %d glyph rows", name, len(s.Mask), len(s.Glyphs))
+		}
+		for row, keys := range s.Mask {
+			if w := len([]rune(s.Glyphs[row])); len(keys) > w {
+				t.Errorf("%s row %d: mask %q is wider than %q", name, row, keys, s.Glyphs[row])
+			}
+			for _, k := range keys {
+				if !strings.ContainsRune(" ._bldeBLDE", k) {
+					t.Errorf("%s row %d: unknown mask key %q", name, row, k)
+				}
+			}
+		}
+	}
+}
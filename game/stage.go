@@ -1,60 +1,58 @@
 package game
 
-import "time"
-
 // applyStage smoothly transitions parameters based on score threshold crossings.
-func (g *Game) applyStage() {
+func (s *Simulation) applyStage() {
 	// determine target stage for current score
 	target := 0
 	for i := len(stageConfigs) - 1; i >= 0; i-- {
-		if g.score >= stageConfigs[i].ScoreThreshold {
+		if s.score >= stageConfigs[i].ScoreThreshold {
 			target = i
 			break
 		}
 	}
 	// on first crossing, start transition
-	if target != g.stageIndexTarget {
-		g.stageIndexTarget = target
-		g.stageTransitionStart = time.Now()
+	if target != s.stageIndexTarget {
+		s.stageIndexTarget = target
+		s.stageTransitionStart = s.tick
 
 		// 当进入新阶段时，触发分数闪烁效果
-		if target > g.stageIndexActive {
-			g.scoreBlinking = true
-			g.scoreBlinkStart = time.Now()
-			g.scoreBlinkVisible = true
-			g.lastBlinkToggle = time.Now()
+		if target > s.stageIndexActive {
+			s.scoreBlinking = true
+			s.scoreBlinkStart = s.tick
+			s.scoreBlinkVisible = true
+			s.lastBlinkToggle = s.tick
 
 			// 播放得分音效
-			GetAudioManager().PlaySound(SoundScore)
+			s.playSound(SoundScore)
 		}
 	}
 	// if currently transitioning between two stages
-	if g.stageIndexActive != g.stageIndexTarget {
-		elapsed := time.Since(g.stageTransitionStart)
-		frac := float64(elapsed) / float64(stageTransitionDuration)
+	if s.stageIndexActive != s.stageIndexTarget {
+		elapsed := s.tick - s.stageTransitionStart
+		frac := float64(elapsed) / float64(ticksFor(stageTransitionDuration))
 		if frac >= 1 {
 			// finish transition
-			g.stageIndexActive = g.stageIndexTarget
+			s.stageIndexActive = s.stageIndexTarget
 
 			// 设置当前阶段的速度
-			obstacleSpeed = stageConfigs[g.stageIndexActive].Speed * speedFactor
+			s.speed = stageConfigs[s.stageIndexActive].Speed * speedFactor
 
 			// 更新障碍物间距和概率
-			g.obstacleManager.UpdateStageGaps(
-				stageConfigs[g.stageIndexActive].MinGap,
-				stageConfigs[g.stageIndexActive].MaxGap,
-				g.stageIndexActive,
+			s.obstacleManager.UpdateStageGaps(
+				stageConfigs[s.stageIndexActive].MinGap,
+				stageConfigs[s.stageIndexActive].MaxGap,
+				s.stageIndexActive,
 			)
 
-			g.stageTransitionStart = time.Time{}
+			s.stageTransitionStart = 0
 		} else {
 			// interpolate between active and target
-			old := stageConfigs[g.stageIndexActive]
-			next := stageConfigs[g.stageIndexTarget]
+			old := stageConfigs[s.stageIndexActive]
+			next := stageConfigs[s.stageIndexTarget]
 
 			// 平滑过渡速度
 			speed := old.Speed + frac*(next.Speed-old.Speed)
-			obstacleSpeed = speed * speedFactor
+			s.speed = speed * speedFactor
 
 			// 平滑过渡障碍物间距
 			minGap := int(float64(old.MinGap) + frac*float64(next.MinGap-old.MinGap))
@@ -79,23 +77,23 @@ func (g *Game) applyStage() {
 			}
 
 			// 更新障碍物管理器的概率
-			g.obstacleManager.cactusProbability = tempStage.CactusProb
-			g.obstacleManager.singleCactusRatio = tempStage.SingleCactusRatio
-			g.obstacleManager.shortCactusRatio = tempStage.ShortCactusRatio
-			g.obstacleManager.groupCactusRatio = tempStage.GroupCactusRatio
-			g.obstacleManager.smallBirdRatio = tempStage.SmallBirdRatio
-			g.obstacleManager.bigBirdRatio = tempStage.BigBirdRatio
+			s.obstacleManager.cactusProbability = tempStage.CactusProb
+			s.obstacleManager.singleCactusRatio = tempStage.SingleCactusRatio
+			s.obstacleManager.shortCactusRatio = tempStage.ShortCactusRatio
+			s.obstacleManager.groupCactusRatio = tempStage.GroupCactusRatio
+			s.obstacleManager.smallBirdRatio = tempStage.SmallBirdRatio
+			s.obstacleManager.bigBirdRatio = tempStage.BigBirdRatio
 
 			// 更新障碍物间距
-			g.obstacleManager.minGap = minGap
-			g.obstacleManager.maxGap = maxGap
+			s.obstacleManager.minGap = minGap
+			s.obstacleManager.maxGap = maxGap
 		}
 	} else {
 		// no transition: keep active stage values
-		sc := stageConfigs[g.stageIndexActive]
-		obstacleSpeed = sc.Speed * speedFactor
+		sc := stageConfigs[s.stageIndexActive]
+		s.speed = sc.Speed * speedFactor
 
 		// 确保障碍物间距与当前阶段一致
-		g.obstacleManager.UpdateStageGaps(sc.MinGap, sc.MaxGap, g.stageIndexActive)
+		s.obstacleManager.UpdateStageGaps(sc.MinGap, sc.MaxGap, s.stageIndexActive)
 	}
 }
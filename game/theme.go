@@ -0,0 +1,285 @@
+package game
+
+import (
+	"fmt"
+	"github.com/nsf/termbox-go"
+)
+
+// 颜色主题：每个绘制调用都向当前调色板询问颜色，而不是写死 termbox 颜色。
+// 调色板是当前主题在白天和夜晚颜色之间按天色混合的结果。
+
+// Theme is a colour palette for everything the game draws
+type Theme struct {
+	Name       string
+	Dino       termbox.Attribute
+	Cactus     termbox.Attribute
+	SmallBird  termbox.Attribute
+	BigBird    termbox.Attribute
+	Ground     termbox.Attribute // the ground line
+	Decoration termbox.Attribute // pebbles below the ground line
+	Clouds     []termbox.Attribute
+	Text       termbox.Attribute
+	Background termbox.Attribute
+	Pulse      [2]termbox.Attribute // the score alternates between these while it pulses
+	Alert      termbox.Attribute    // the dino while it flashes after a collision
+	Moon       termbox.Attribute    // the moon and stars come out at night
+	Star       termbox.Attribute
+	Light      bool    // made for a light terminal background
+	RGB        *[8]rgb // what the 8 colours look like in this theme, for blending and truecolor; nil uses xterm's
+	Night      *Theme  // colours at night; nil keeps the day colours
+}
+
+// withNight returns t with night colours made by changing a copy of it
+func withNight(t Theme, night func(n *Theme)) Theme {
+	n := t
+	night(&n)
+	t.Night = &n
+	return t
+}
+
+// themes are the built-in themes; the first is the default
+var themes = []Theme{
+	withNight(Theme{
+		Name:       "default",
+		Dino:       termbox.ColorGreen,
+		Cactus:     termbox.ColorRed,
+		SmallBird:  termbox.ColorYellow,
+		BigBird:    termbox.ColorMagenta,
+		Ground:     termbox.ColorWhite,
+		Decoration: termbox.ColorWhite,
+		Clouds:     []termbox.Attribute{termbox.ColorWhite},
+		Text:       termbox.ColorWhite,
+		Background: termbox.ColorDefault,
+		Pulse:      [2]termbox.Attribute{termbox.ColorYellow | termbox.AttrBold, termbox.ColorRed | termbox.AttrBold},
+		Alert:      termbox.ColorRed | termbox.AttrBold,
+		Moon:       termbox.ColorYellow | termbox.AttrBold,
+		Star:       termbox.ColorWhite,
+	}, func(n *Theme) {
+		// 夜里地面和云暗下来
+		n.Ground, n.Decoration = termbox.ColorBlue, termbox.ColorBlue
+		n.Clouds = []termbox.Attribute{termbox.ColorBlue}
+		n.Dino = termbox.ColorCyan
+	}),
+	{
+		// 只用终端默认颜色，靠粗体和反色区分
+		Name:       "monochrome",
+		Dino:       termbox.ColorDefault | termbox.AttrBold,
+		Cactus:     termbox.ColorDefault,
+		SmallBird:  termbox.ColorDefault,
+		BigBird:    termbox.ColorDefault,
+		Ground:     termbox.ColorDefault,
+		Decoration: termbox.ColorDefault,
+		Clouds:     []termbox.Attribute{termbox.ColorDefault},
+		Text:       termbox.ColorDefault,
+		Background: termbox.ColorDefault,
+		Pulse:      [2]termbox.Attribute{termbox.ColorDefault | termbox.AttrBold, termbox.ColorDefault | termbox.AttrReverse},
+		Alert:      termbox.ColorDefault | termbox.AttrReverse,
+		Moon:       termbox.ColorDefault | termbox.AttrBold,
+		Star:       termbox.ColorDefault,
+	},
+	{
+		Name:       "high-contrast",
+		Dino:       termbox.ColorGreen | termbox.AttrBold,
+		Cactus:     termbox.ColorRed | termbox.AttrBold,
+		SmallBird:  termbox.ColorYellow | termbox.AttrBold,
+		BigBird:    termbox.ColorMagenta | termbox.AttrBold,
+		Ground:     termbox.ColorWhite | termbox.AttrBold,
+		Decoration: termbox.ColorWhite,
+		Clouds:     []termbox.Attribute{termbox.ColorWhite},
+		Text:       termbox.ColorWhite | termbox.AttrBold,
+		Background: termbox.ColorBlack,
+		Pulse:      [2]termbox.Attribute{termbox.ColorYellow | termbox.AttrBold, termbox.ColorWhite | termbox.AttrBold},
+		Alert:      termbox.ColorRed | termbox.AttrBold | termbox.AttrReverse,
+		Moon:       termbox.ColorYellow | termbox.AttrBold,
+		Star:       termbox.ColorWhite | termbox.AttrBold,
+	},
+	withNight(Theme{
+		// 对应 solarized 终端配色里的 ANSI 强调色
+		Name:       "solarized",
+		Dino:       termbox.ColorCyan,
+		Cactus:     termbox.ColorGreen,
+		SmallBird:  termbox.ColorYellow,
+		BigBird:    termbox.ColorMagenta,
+		Ground:     termbox.ColorYellow,
+		Decoration: termbox.ColorBlue,
+		Clouds:     []termbox.Attribute{termbox.ColorBlue, termbox.ColorCyan},
+		Text:       termbox.ColorBlue,
+		Background: termbox.ColorDefault,
+		Pulse:      [2]termbox.Attribute{termbox.ColorYellow | termbox.AttrBold, termbox.ColorMagenta | termbox.AttrBold},
+		Alert:      termbox.ColorRed | termbox.AttrBold,
+		Moon:       termbox.ColorYellow,
+		Star:       termbox.ColorCyan,
+		RGB:        &solarizedColors,
+	}, func(n *Theme) {
+		n.Ground = termbox.ColorBlue
+		n.Clouds = []termbox.Attribute{termbox.ColorBlue}
+		n.Text = termbox.ColorCyan
+	}),
+	withNight(Theme{
+		// 浅色背景的终端：白色和黄色几乎看不见，改用深色
+		Name:       "light-background",
+		Dino:       termbox.ColorGreen,
+		Cactus:     termbox.ColorRed,
+		SmallBird:  termbox.ColorBlue,
+		BigBird:    termbox.ColorMagenta,
+		Ground:     termbox.ColorBlack,
+		Decoration: termbox.ColorBlack,
+		Clouds:     []termbox.Attribute{termbox.ColorBlue, termbox.ColorCyan},
+		Text:       termbox.ColorBlack,
+		Background: termbox.ColorDefault,
+		Pulse:      [2]termbox.Attribute{termbox.ColorBlue | termbox.AttrBold, termbox.ColorRed | termbox.AttrBold},
+		Alert:      termbox.ColorRed | termbox.AttrBold,
+		Moon:       termbox.ColorYellow | termbox.AttrBold,
+		Star:       termbox.ColorWhite,
+		Light:      true,
+	}, func(n *Theme) {
+		// 像原版一样，夜里画面反过来：黑底浅色
+		n.Background = termbox.ColorBlack
+		n.Text, n.Ground, n.Decoration = termbox.ColorWhite, termbox.ColorWhite, termbox.ColorWhite
+		n.Clouds = []termbox.Attribute{termbox.ColorWhite}
+		n.SmallBird = termbox.ColorYellow
+		n.Pulse = [2]termbox.Attribute{termbox.ColorYellow | termbox.AttrBold, termbox.ColorRed | termbox.AttrBold}
+	}),
+}
+
+// solarizedColors are the accents of the solarized palette, as a solarized
+// terminal shows the 8 colours
+var solarizedColors = [8]rgb{
+	{7, 54, 66}, {220, 50, 47}, {133, 153, 0}, {181, 137, 0},
+	{38, 139, 210}, {211, 54, 130}, {42, 161, 152}, {238, 232, 213},
+}
+
+// theme is the theme chosen by the player
+var theme = themes[0]
+
+// palette holds the colours of the frame being drawn: the theme, faded
+// towards its night colours as it gets dark, in the terminal's colour mode
+var palette = themes[0]
+
+// ThemeNames returns the names of the built-in themes
+func ThemeNames() []string {
+	names := make([]string, len(themes))
+	for i, t := range themes {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// lookupTheme finds a built-in theme by name
+func lookupTheme(name string) (Theme, bool) {
+	for _, t := range themes {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Theme{}, false
+}
+
+// SetTheme switches to the named theme
+func SetTheme(name string) error {
+	t, ok := lookupTheme(name)
+	if !ok {
+		return fmt.Errorf("unknown theme %q", name)
+	}
+	theme = t
+	setPalette(0)
+	return nil
+}
+
+// setPalette makes the palette the theme at the given darkness, from 0
+// (day) to 1 (night)
+func setPalette(darkness float64) {
+	palette = theme.at(darkness)
+}
+
+// at returns the colours of t at the given darkness
+func (t Theme) at(darkness float64) Theme {
+	n := t
+	if t.Night != nil {
+		n = *t.Night
+	}
+	mix := func(day, night termbox.Attribute) termbox.Attribute {
+		return t.mix(day, night, true, true, darkness)
+	}
+	p := t
+	p.Dino = mix(t.Dino, n.Dino)
+	p.Cactus = mix(t.Cactus, n.Cactus)
+	p.SmallBird = mix(t.SmallBird, n.SmallBird)
+	p.BigBird = mix(t.BigBird, n.BigBird)
+	p.Ground = mix(t.Ground, n.Ground)
+	p.Decoration = mix(t.Decoration, n.Decoration)
+	p.Clouds = make([]termbox.Attribute, cloudShades)
+	for i := range p.Clouds {
+		p.Clouds[i] = mix(t.cloud(i), n.cloud(i))
+	}
+	p.Text = mix(t.Text, n.Text)
+	p.Background = t.mix(t.Background, n.Background, false, false, darkness)
+	p.Pulse = [2]termbox.Attribute{mix(t.Pulse[0], n.Pulse[0]), mix(t.Pulse[1], n.Pulse[1])}
+	p.Alert = mix(t.Alert, n.Alert)
+	// 月亮和星星从背景色里渐渐显现
+	p.Moon = t.mix(t.Background, n.Moon, false, true, darkness)
+	p.Star = t.mix(t.Background, n.Star, false, true, darkness)
+	return p
+}
+
+// skyVisible reports whether the moon and stars show at the given darkness
+func skyVisible(darkness float64) bool {
+	if colorDepth > 8 {
+		return darkness > 0
+	}
+	return darkness >= 0.5
+}
+
+// mix blends the day colour into the night colour and returns it in the
+// terminal's colour mode; dayFg and nightFg tell whether ColorDefault
+// stands for the terminal's text or background colour. Attributes like
+// bold switch halfway. With 8 colours the colours switch halfway too.
+func (t Theme) mix(day, night termbox.Attribute, dayFg, nightFg bool, darkness float64) termbox.Attribute {
+	attrs, fg := day&attrMask, dayFg
+	if darkness >= 0.5 {
+		attrs, fg = night&attrMask, nightFg
+	}
+	if colorDepth == 8 || darkness <= 0 || darkness >= 1 || day&^attrMask == night&^attrMask {
+		c := day &^ attrMask
+		if darkness >= 0.5 {
+			c = night &^ attrMask
+		}
+		return t.output(c, fg) | attrs
+	}
+	a, b := t.rgb(day&^attrMask, dayFg), t.rgb(night&^attrMask, nightFg)
+	var c rgb
+	for i := range c {
+		c[i] = a[i] + (b[i]-a[i])*darkness
+	}
+	return encode(c) | attrs
+}
+
+// output converts one of the theme's 8 colours to the terminal's colour
+// mode. ColorDefault stays the terminal's own colour.
+func (t Theme) output(c termbox.Attribute, fg bool) termbox.Attribute {
+	if colorDepth != colorsTrue || c == termbox.ColorDefault {
+		return c // 256 色模式的前 8 种颜色和 8 色模式相同
+	}
+	return encode(t.rgb(c, fg))
+}
+
+// rgb guesses the RGB value of one of the theme's 8 colours. For
+// ColorDefault it assumes a dark terminal, or a light one if the theme is
+// made for that.
+func (t Theme) rgb(c termbox.Attribute, fg bool) rgb {
+	if c >= termbox.ColorBlack && c <= termbox.ColorWhite {
+		if t.RGB != nil {
+			return t.RGB[c-termbox.ColorBlack]
+		}
+		return ansiColors[c-termbox.ColorBlack]
+	}
+	if fg != t.Light {
+		return rgb{229, 229, 229}
+	}
+	return rgb{0, 0, 0}
+}
+
+// cloud returns the colour of a cloud shade
+func (t Theme) cloud(shade int) termbox.Attribute {
+	return t.Clouds[shade%len(t.Clouds)]
+}
@@ -0,0 +1,120 @@
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+	"testing"
+)
+
+func TestThemes(t *testing.T) {
+	seen := map[string]bool{}
+	for _, th := range themes {
+		if th.Name == "" || seen[th.Name] {
+			t.Errorf("theme name %q is empty or used twice", th.Name)
+		}
+		seen[th.Name] = true
+		if len(th.Clouds) == 0 {
+			t.Errorf("theme %s has no cloud colours", th.Name)
+		}
+	}
+}
+
+func TestSetTheme(t *testing.T) {
+	saved := theme
+	t.Cleanup(func() { theme = saved })
+
+	for _, name := range ThemeNames() {
+		if err := SetTheme(name); err != nil || theme.Name != name {
+			t.Errorf("SetTheme(%q): %v, theme %q", name, err, theme.Name)
+		}
+	}
+	before := theme.Name
+	if err := SetTheme("neon"); err == nil {
+		t.Error("no error for an unknown theme")
+	}
+	if theme.Name != before {
+		t.Errorf("an unknown theme switched to %q", theme.Name)
+	}
+}
+
+// useColorDepth sets colorDepth until the test ends
+func useColorDepth(t *testing.T, depth int) {
+	saved := colorDepth
+	t.Cleanup(func() { colorDepth = saved })
+	colorDepth = depth
+}
+
+func TestThemeMix(t *testing.T) {
+	var dark, light Theme
+	light.Light = true
+	black, white := termbox.ColorBlack, termbox.ColorWhite
+	grey := color256Of(rgb{114.5, 114.5, 114.5})
+	tests := []struct {
+		name     string
+		theme    Theme
+		depth    int
+		day      termbox.Attribute
+		night    termbox.Attribute
+		darkness float64
+		want     termbox.Attribute
+	}{
+		{"day", dark, 256, black, white, 0, black},
+		{"night", dark, 256, black, white, 1, white},
+		{"256 colours blend", dark, 256, black, white, 0.5, grey},
+		{"8 colours keep the day colour", dark, 8, black, white, 0.4, black},
+		{"8 colours switch halfway", dark, 8, black, white, 0.5, white},
+		{"same colour", dark, 256, white, white, 0.5, white},
+		{"bold goes at dusk", dark, 256, black | termbox.AttrBold, white, 0.25, color256Of(rgb{57.25, 57.25, 57.25}) | termbox.AttrBold},
+		{"bold comes at night", dark, 256, black, white | termbox.AttrBold, 0.75, color256Of(rgb{171.75, 171.75, 171.75}) | termbox.AttrBold},
+		{"default text on a dark terminal", dark, 256, termbox.ColorDefault, black, 0.5, grey},
+		{"default text on a light terminal", light, 256, termbox.ColorDefault, black, 0.5, color256Of(rgb{0, 0, 0})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useColorDepth(t, tt.depth)
+			if got := tt.theme.mix(tt.day, tt.night, true, true, tt.darkness); got != tt.want {
+				t.Errorf("mix = %#x, want %#x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThemeAt(t *testing.T) {
+	useColorDepth(t, 8)
+	for _, th := range themes {
+		t.Run(th.Name, func(t *testing.T) {
+			night := th
+			if th.Night != nil {
+				night = *th.Night
+			}
+			if day := th.at(0); day.Dino != th.Dino || day.Text != th.Text {
+				t.Errorf("day dino %#x text %#x, want %#x %#x", day.Dino, day.Text, th.Dino, th.Text)
+			}
+			if n := th.at(1); n.Dino != night.Dino || n.Text != night.Text || n.Moon != night.Moon {
+				t.Errorf("night dino %#x text %#x moon %#x, want %#x %#x %#x", n.Dino, n.Text, n.Moon, night.Dino, night.Text, night.Moon)
+			}
+			if len(th.at(0.5).Clouds) != cloudShades {
+				t.Errorf("%d cloud colours, want %d", len(th.at(0.5).Clouds), cloudShades)
+			}
+		})
+	}
+}
+
+func TestSkyVisible(t *testing.T) {
+	tests := []struct {
+		depth    int
+		darkness float64
+		want     bool
+	}{
+		{8, 0, false},
+		{8, 0.4, false},
+		{8, 0.5, true},
+		{256, 0, false},
+		{256, 0.1, true},
+	}
+	for _, tt := range tests {
+		useColorDepth(t, tt.depth)
+		if got := skyVisible(tt.darkness); got != tt.want {
+			t.Errorf("skyVisible(%v) with %d colours = %v, want %v", tt.darkness, tt.depth, got, tt.want)
+		}
+	}
+}
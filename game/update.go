@@ -1,90 +1,89 @@
 package game
 
 import (
+	"fmt"
 	"github.com/nsf/termbox-go"
-	"math/rand"
-	"os"
 	"time"
 )
 
 // update updates game state
-func (g *Game) update() {
+func (s *Simulation) update() {
 	// 如果下键被按住，确保恐龙知道这一点
-	if g.downKeyHeld {
-		g.dino.isDownKeyPressed = true
+	if s.downKeyHeld {
+		s.dino.isDownKeyPressed = true
 	}
 
 	// Always update clouds regardless of game state or pause state
-	g.cloudManager.Update()
+	s.cloudManager.Update()
 
 	// If game is paused, don't update other game elements
-	if g.pause && g.started && !g.collided {
+	if s.pause && s.started && !s.collided {
 		return
 	}
 
-	g.dino.Update()
+	s.dino.Update()
 
-	if g.started {
-		g.applyStage()
-		g.obstacleManager.Update()
+	if s.started {
+		s.applyStage()
+		s.obstacleManager.Update(s.speed)
 
 		// 即使地面已经完全扩展，也要更新地面装饰的位置
-		g.updateGroundDecorations()
+		s.updateGroundDecorations()
 
-		if g.checkCollision() {
-			g.collided = true
+		if s.checkCollision() {
+			s.collided = true
 		}
-		if g.groundExtending {
-			g.updateGround()
+		if s.groundExtending {
+			s.updateGround()
 			// stop extending once ground fully spans screen
-			if g.groundStart == 0 && g.groundEnd == width-1 {
-				g.groundExtending = false
+			if s.groundStart == 0 && s.groundEnd == width-1 {
+				s.groundExtending = false
 			}
 		}
 	}
 }
 
 // updateGroundDecorations 更新地面装饰的位置，使其随着游戏进行而移动
-func (g *Game) updateGroundDecorations() {
-	if !g.collided {
+func (s *Simulation) updateGroundDecorations() {
+	if !s.collided {
 		// 移动所有地面装饰，速度与障碍物相同
-		for i := range groundDecorations {
-			groundDecorations[i].x -= obstacleSpeed
+		for i := range s.groundDecorations {
+			s.groundDecorations[i].x -= s.speed
 
 			// 如果装饰移出了屏幕左侧，将其移到屏幕右侧重新出现
-			if groundDecorations[i].x < -5 {
-				groundDecorations[i].x += float64(width * 2)
+			if s.groundDecorations[i].x < -5 {
+				s.groundDecorations[i].x += float64(width * 2)
 			}
 		}
 
 		// 同样移动地面线字符
-		for i := range groundLineChars {
-			groundLineChars[i].x -= obstacleSpeed
+		for i := range s.groundLineChars {
+			s.groundLineChars[i].x -= s.speed
 
 			// 如果地面线字符移出了屏幕左侧，将其移到屏幕右侧重新出现
-			if groundLineChars[i].x < -5 {
-				groundLineChars[i].x += float64(width * 2)
+			if s.groundLineChars[i].x < -5 {
+				s.groundLineChars[i].x += float64(width * 2)
 
 				// 默认重置为下划线
-				groundLineChars[i].char = '_'
+				s.groundLineChars[i].char = '_'
 			}
 		}
 
 		// 每隔一段时间添加一个新的特殊字符
 		// 使用静态计数器来控制添加频率
-		g.groundSpecialCharCounter += 1
+		s.groundSpecialCharCounter += 1
 
 		// 每移动约200-300个单位添加一个特殊字符
-		if g.groundSpecialCharCounter >= 200+rand.Intn(100) {
-			g.groundSpecialCharCounter = 0
+		if s.groundSpecialCharCounter >= 200+s.groundRng.Intn(100) {
+			s.groundSpecialCharCounter = 0
 
 			// 在屏幕右侧边缘添加一个特殊字符
-			for i := range groundLineChars {
+			for i := range s.groundLineChars {
 				// 找到一个位于屏幕右侧的字符
-				if int(groundLineChars[i].x) >= width-5 && int(groundLineChars[i].x) <= width {
+				if int(s.groundLineChars[i].x) >= width-5 && int(s.groundLineChars[i].x) <= width {
 					// 选择一个特殊字符
 					var specialChar rune
-					switch rand.Intn(4) {
+					switch s.groundRng.Intn(4) {
 					case 0:
 						specialChar = '='
 					case 1:
@@ -95,7 +94,7 @@ func (g *Game) updateGroundDecorations() {
 						specialChar = '^'
 					}
 
-					groundLineChars[i].char = specialChar
+					s.groundLineChars[i].char = specialChar
 					break // 只修改一个字符
 				}
 			}
@@ -103,83 +102,117 @@ func (g *Game) updateGroundDecorations() {
 	}
 }
 
-// gameOver displays game over screen and waits for restart or quit
-func (g *Game) gameOver() {
-	// 播放碰撞音效
-	GetAudioManager().PlaySound(SoundCollision)
-
-	// 更新最高分并保存
-	if g.score > g.highestScore {
-		g.highestScore = g.score
-		// 保存最高分到文件
-		err := SaveHighScore(g.highestScore)
-		if err != nil {
-			// 如果保存失败，只在控制台打印错误，不影响游戏
-			// 这里不能直接显示在游戏界面，因为会干扰游戏结束画面
-			// fmt.Println("无法保存最高分:", err)
-		}
+// gameOver displays game over screen and waits for restart or quit.
+// It returns false when the player chose to quit.
+func (g *Game) gameOver() bool {
+	// 记录到排行榜（回放不计入），成绩够格时输入名字
+	if g.player == nil && !g.recordRun() {
+		return false
 	}
 
-	PrintCenter("GAME OVER")
-	PrintCenterAt("('R' to retry, 'Q' to quit)", height/2+2)
+	// 每局结束时保存录像，即使之后程序被强行终止也不会丢失
+	if g.recorder != nil {
+		g.recordErr = g.recorder.Save(g.sim.State())
+	}
 
-	// 显示音效控制提示
-	soundMsg := "Press 'm' to toggle sound"
-	if !GetAudioManager().IsEnabled() {
-		soundMsg = "Sound OFF - Press 'm' to enable"
+	if g.player != nil {
+		if g.replayDone() {
+			g.replayFinished()
+			return false
+		}
+		// 回放中的下一局：短暂显示结束画面后继续
+		PrintCenter(g.renderer, "GAME OVER")
+		g.renderer.Flush()
+		time.Sleep(time.Second)
+		return true
 	}
-	PrintCenterAt(soundMsg, height/2+2)
 
-	termbox.Flush()
+	g.drawGameOver()
 	for {
-		ev := <-g.events
+		ev := <-g.keyboard.events
+		if ev.Release {
+			continue
+		}
+		if ev.Type == termbox.EventResize {
+			// 结束画面中也要跟随终端尺寸重绘
+			g.resize(ev.Width, ev.Height)
+			g.draw()
+			g.drawGameOver()
+			continue
+		}
 		if ev.Type == termbox.EventKey {
-			if ev.Ch == KeyRestartRune {
-				// reset game state
-				g.dino = NewDino()
-				g.obstacleManager = NewObstacleManager()
-				// Don't reset clouds, just let them continue
-				g.score = 0
-				// reset stage progression and parameters
-				g.stageIndexActive = 0
-				g.stageIndexTarget = 0
-				g.stageTransitionStart = time.Time{}
-				obstacleSpeed = stageConfigs[0].Speed * speedFactor
-
-				// 重置障碍物管理器
-				g.obstacleManager = NewObstacleManager()
-				return
+			if keyMap.Matches(InputRestart, ev.Event) {
+				// reset game state on the next tick, clouds keep moving
+				g.queue(ActionRestart)
+				return true
 			}
-			if ev.Key == KeyQuit || ev.Ch == KeyQuitRune {
-				termbox.Close()
-				os.Exit(0)
+			if isQuitKey(ev.Event) {
+				return false
 			}
 		}
 	}
 }
 
+// drawGameOver draws the game over overlay on top of the last frame
+func (g *Game) drawGameOver() {
+	if g.tooSmall() {
+		return
+	}
+	PrintCenterAt(g.renderer, fmt.Sprintf("Seed: %d", g.sim.Seed()), height/2-2)
+	PrintCenter(g.renderer, "GAME OVER")
+	if g.recordErr != nil {
+		PrintCenterAt(g.renderer, fmt.Sprintf("Recording not saved: %v", g.recordErr), height/2+1)
+	}
+	PrintCenterAt(g.renderer, fmt.Sprintf("(%s to retry, %s to quit)", keyMap.Hint(InputRestart), keyMap.Hint(InputQuit)), height/2+2)
+
+	// 显示音效控制提示
+	PrintCenterAt(g.renderer, soundHint(), height/2+2)
+
+	g.renderer.Flush()
+}
+
+// replayFinished shows the replayed score next to the recorded one and
+// waits for the player to quit
+func (g *Game) replayFinished() {
+	claimed := g.player.replay.Score
+	result := "score matches the recording"
+	if g.sim.score != claimed {
+		result = fmt.Sprintf("MISMATCH: recording claims %d", claimed)
+	}
+	PrintCenter(g.renderer, fmt.Sprintf("REPLAY FINISHED - score %d", g.sim.score))
+	PrintCenterAt(g.renderer, result, height/2+1)
+	PrintCenterAt(g.renderer, fmt.Sprintf("(%s to quit)", keyMap.Hint(InputQuit)), height/2+2)
+	g.renderer.Flush()
+	for {
+		ev := <-g.keyboard.events
+		if !ev.Release && isQuitKey(ev.Event) {
+			return
+		}
+	}
+}
+
 // updateGround expands the ground boundaries until filling the screen.
-func (g *Game) updateGround() {
-	if g.groundStart > 0 {
+func (s *Simulation) updateGround() {
+	if s.groundStart > 0 {
 		// 使用向下取整的方式将浮点数转换为整数
 		moveAmount := int(groundExtendSpeed)
 		if moveAmount < 1 {
 			moveAmount = 1 // 确保至少移动1个单位
 		}
-		g.groundStart -= moveAmount
-		if g.groundStart < 0 {
-			g.groundStart = 0
+		s.groundStart -= moveAmount
+		if s.groundStart < 0 {
+			s.groundStart = 0
 		}
 	}
-	if g.groundEnd < width-1 {
+	if s.groundEnd < width-1 {
 		// 使用向下取整的方式将浮点数转换为整数
 		moveAmount := int(groundExtendSpeed)
 		if moveAmount < 1 {
 			moveAmount = 1 // 确保至少移动1个单位
 		}
-		g.groundEnd += moveAmount
-		if g.groundEnd > width-1 {
-			g.groundEnd = width - 1
+		s.groundEnd += moveAmount
+		if s.groundEnd > width-1 {
+			s.groundEnd = width - 1
 		}
 	}
 }
@@ -2,8 +2,18 @@ module github.com/jianongHe/term-rex
 
 go 1.22.4
 
-require github.com/nsf/termbox-go v1.1.1
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/ebitengine/oto/v3 v3.3.3
+	github.com/hajimehoshi/go-mp3 v0.3.4
+	github.com/jfreymuth/pulse v0.1.1
+	github.com/mattn/go-runewidth v0.0.9
+	github.com/nsf/termbox-go v1.1.1
+)
 
-require github.com/mattn/go-runewidth v0.0.9 // indirect
+require (
+	github.com/ebitengine/purego v0.8.0 // indirect
+	golang.org/x/sys v0.25.0 // indirect
+)
 
 replace github.com/jianongHe/term-rex => ./
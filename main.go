@@ -1,10 +1,15 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/jianongHe/term-rex/game"
 	"github.com/nsf/termbox-go"
@@ -16,15 +21,123 @@ const (
 	BuildDate = "2025-05-11"
 )
 
+// Exit codes of the verify subcommand
+const (
+	exitValid    = 0 // the replayed score matches the claimed score
+	exitMismatch = 1 // the replay is well formed but the score differs
+	exitCorrupt  = 2 // the file is unreadable, malformed or inconsistent
+	exitUsage    = 3 // wrong command line
+)
+
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(runVerify(os.Args[2:]))
+	}
+
+	showVersion := flag.Bool("version", false, "print version and exit")
+	flag.BoolVar(showVersion, "v", false, "print version and exit (shorthand)")
+	seed := flag.Int64("seed", 0, "seed for obstacles, clouds and ground (default: random)")
+	record := flag.String("record", "", "record the session to a replay `file`")
+	replay := flag.String("replay", "", "play back a recorded replay `file`")
+	fps := flag.Int("fps", 60, "render frame rate (does not change game speed)")
+	heightFlag := flag.String("height", "15", "play-field height in rows (15-40), or \"auto\" to fit the terminal")
+	configPath := flag.String("config", "", "load tunables, stages and keys from a TOML or JSON `file` (default ~/.config/term-rex/config.toml)")
+	dataDir := flag.String("data-dir", "", "keep config and scores in `dir` instead of the XDG directories")
+	keyboard := flag.String("keyboard", "auto", "\"auto\" uses real key releases if the terminal supports the kitty keyboard protocol, \"legacy\" never asks")
+	audio := flag.String("audio", "auto", "audio `backend`: auto, "+strings.Join(game.AudioBackendNames(), ", "))
+	themeName := flag.String("theme", "", "colour `theme`: "+strings.Join(game.ThemeNames(), ", ")+" (default from the config file, else default)")
+	colorMode := flag.String("color", "", "colour `mode`: "+strings.Join(game.ColorModes(), ", ")+" (default from the config file, else auto)")
+	renderMode := flag.String("render", "", "render `mode`: "+strings.Join(game.RenderModes(), ", ")+" (default from the config file, else text)")
+	skin := flag.String("skin", "", "sprite `skin`: a directory, or the name of one in <data-dir>/skins or of a bundled skin ("+strings.Join(game.SkinNames(), ", ")+")")
+	flag.Parse()
+
 	// Check for version flag
-	if len(os.Args) > 1 && (os.Args[1] == "--version" || os.Args[1] == "-v") {
+	if *showVersion {
 		fmt.Printf("Term-Rex v%s (built on %s)\n", Version, BuildDate)
 		os.Exit(0)
 	}
 
-	// Set up signal handler to catch Ctrl+C
-	setupSignalHandler()
+	game.SetDataDir(*dataDir)
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Printf("Invalid config: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Pick a random seed unless one was given, so runs can be reproduced
+	opts := game.Options{Seed: *seed, ConfigPath: config}
+	if !isFlagSet("seed") {
+		opts.Seed = time.Now().UnixNano()
+	}
+	if *fps < 1 || *fps > 240 {
+		fmt.Println("--fps must be between 1 and 240")
+		os.Exit(1)
+	}
+	opts.FPS = *fps
+	if *heightFlag == "auto" {
+		opts.Height = -1
+	} else if h, err := strconv.Atoi(*heightFlag); err == nil && h >= 15 && h <= 40 {
+		opts.Height = h
+	} else {
+		fmt.Println("--height must be a number between 15 and 40, or \"auto\"")
+		os.Exit(1)
+	}
+	switch *keyboard {
+	case "auto":
+	case "legacy":
+		opts.LegacyKeyboard = true
+	default:
+		fmt.Println("--keyboard must be \"auto\" or \"legacy\"")
+		os.Exit(1)
+	}
+	if *audio != "auto" && !slices.Contains(game.AudioBackendNames(), *audio) {
+		fmt.Printf("--audio must be one of auto, %s\n", strings.Join(game.AudioBackendNames(), ", "))
+		os.Exit(1)
+	}
+	opts.Audio = *audio
+	if *themeName != "" && !slices.Contains(game.ThemeNames(), *themeName) {
+		fmt.Printf("--theme must be one of %s\n", strings.Join(game.ThemeNames(), ", "))
+		os.Exit(1)
+	}
+	opts.Theme = *themeName
+	if *colorMode != "" && !slices.Contains(game.ColorModes(), *colorMode) {
+		fmt.Printf("--color must be one of %s\n", strings.Join(game.ColorModes(), ", "))
+		os.Exit(1)
+	}
+	opts.Color = *colorMode
+	if *renderMode != "" && !slices.Contains(game.RenderModes(), *renderMode) {
+		fmt.Printf("--render must be one of %s\n", strings.Join(game.RenderModes(), ", "))
+		os.Exit(1)
+	}
+	opts.Render = *renderMode
+	opts.RecordPath = *record
+	if *replay != "" {
+		rp, err := game.LoadReplay(*replay)
+		if err != nil {
+			fmt.Printf("Failed to load replay: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Replay = rp
+	}
+	if *skin != "" {
+		// 皮肤按实际的场地高度检查；auto 要等终端初始化后才知道，按最小高度检查
+		h := opts.Height
+		if opts.Replay != nil {
+			h = opts.Replay.Height
+		}
+		if err := game.LoadSkin(*skin, h); err != nil {
+			fmt.Printf("Invalid skin: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if opts.Replay != nil {
+		// 录像要用录制时的设置和皮肤回放
+		if err := opts.Replay.CheckTuning(); err != nil {
+			fmt.Printf("Cannot play replay %s: %v\n", *replay, err)
+			os.Exit(1)
+		}
+	}
 
 	// Initialize terminal
 	if err := termbox.Init(); err != nil {
@@ -33,24 +146,95 @@ func main() {
 	}
 	defer termbox.Close()
 
-	// Set game width based on terminal size
-	//w, _ := termbox.Size()
-	//game.SetWidth(w)
+	// Create a new game instance (the play field follows the terminal size)
+	g := game.NewGame(opts)
 
-	// Create a new game instance
-	g := game.NewGame()
+	// Set up signal handler to catch Ctrl+C
+	setupSignalHandler(g)
 
 	// Run the game
-	g.Run()
+	if err := g.Run(); err != nil {
+		termbox.Close()
+		fmt.Printf("Failed to save recording: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runVerify re-simulates a replay file headlessly and checks its score.
+// It returns the process exit code.
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	configPath := fs.String("config", "", "config `file` the replay was recorded with")
+	dataDir := fs.String("data-dir", "", "look for config.toml in `dir`")
+	skin := fs.String("skin", "", "sprite `skin` the replay was recorded with")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: term-rex verify [--config file] [--data-dir dir] [--skin skin] <file>")
+		return exitUsage
+	}
+	args = fs.Args()
+	game.SetDataDir(*dataDir)
+	if _, err := loadConfig(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+		return exitUsage
+	}
+	rp, err := game.LoadReplay(args[0])
+	if err != nil {
+		fmt.Printf("corrupt: %v\n", err)
+		return exitCorrupt
+	}
+	if *skin != "" {
+		if err := game.LoadSkin(*skin, rp.Height); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid skin: %v\n", err)
+			return exitUsage
+		}
+	}
+	res, err := game.VerifyReplay(rp)
+	if err != nil {
+		fmt.Printf("corrupt: %s: %v\n", args[0], err)
+		return exitCorrupt
+	}
+	if !res.Valid() {
+		fmt.Printf("mismatch: claimed score %d, replayed score %d (seed %d, %d ticks)\n",
+			res.Claimed, res.Actual, rp.Seed, res.Ticks)
+		return exitMismatch
+	}
+	fmt.Printf("valid: score %d (seed %d, %d ticks)\n", res.Actual, rp.Seed, res.Ticks)
+	return exitValid
+}
+
+// loadConfig loads the config file given with --config, or the default one
+// if it exists. An explicitly given file must exist. It returns the path
+// key bindings changed in the game are saved to.
+func loadConfig(path string) (string, error) {
+	if path == "" {
+		path = game.DefaultConfigPath()
+		if _, err := os.Stat(path); path == "" || os.IsNotExist(err) {
+			return path, nil
+		}
+	}
+	return path, game.LoadConfig(path)
+}
+
+// isFlagSet reports whether the named flag was given on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
-// setupSignalHandler sets up a signal handler to catch Ctrl+C
-func setupSignalHandler() {
+// setupSignalHandler sets up a signal handler to catch Ctrl+C and restore
+// the terminal, keyboard mode included, before exiting
+func setupSignalHandler(g *game.Game) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		<-c
+		g.Close()
 		termbox.Close()
 		os.Exit(0)
 	}()